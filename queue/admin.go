@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+// Keyword that makes Anerbot reload its configuration when sent by an
+// admin.
+const reloadKeyword = "reload"
+
+// Action sent to the anerbot-response function asking it to reload its
+// configuration.
+const reloadAction = "reload"
+
+// Keyword that makes Anerbot report the features missing documentation
+// when sent by an admin, and the action asking the anerbot-response
+// function for the report.
+const (
+	needsDocsKeyword = "needs-docs"
+	needsDocsAction  = "needs_docs"
+)
+
+// Function to check whether a Slack user is allowed to run admin commands.
+func adminAllowed(userID string) bool {
+	for _, v := range adminUsers {
+		if v == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Struct for the settings used to create the Pub/Sub client and topic.
+// The client is rebuilt when any of them change on reload. The contents
+// of the credentials file are kept as well as its path, so that a key
+// rotated in a mounted file is picked up.
+type pubsubSettings struct {
+	projectID   string
+	topicName   string
+	batchDelay  time.Duration
+	batchCount  int
+	credentials string
+	key         string
+}
+
+// Function to return the settings the Pub/Sub client and topic are
+// currently created with.
+func currentPubsubSettings() pubsubSettings {
+	s := pubsubSettings{
+		projectID:   projectID,
+		topicName:   topicName,
+		batchDelay:  batchDelay,
+		batchCount:  batchCount,
+		credentials: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+	}
+	if s.credentials != "" {
+		// A file that can't be read is left for the Pub/Sub client to
+		// report when it is next created.
+		key, _ := ioutil.ReadFile(s.credentials)
+		s.key = string(key)
+	}
+	return s
+}
+
+// Function to reload the configuration of this instance, reading the
+// secret files again, and ask the anerbot-response function to reload its
+// own, which it confirms at the response URL. The shared Pub/Sub topic is
+// rebuilt if the project, topic, batch settings or credentials changed.
+// A configuration that fails to load is reported without asking
+// anerbot-response to reload. Returns the message sent back to the admin
+// who asked for the reload.
+func reloadConfig(responseUrl string) string {
+	before := currentPubsubSettings()
+	if err := loadConfig(); err != nil {
+		log.Printf("unable to reload configuration: %v", err)
+		return "Unable to reload the queue configuration: " + err.Error()
+	}
+	if currentPubsubSettings() != before {
+		resetTopic()
+	}
+
+	message := queueMessage{
+		ResponseUrl: responseUrl,
+		RequestID:   newRequestID(),
+		Action:      reloadAction,
+	}
+	log.Printf("request %s: reloading configuration", message.RequestID)
+
+	if err := publishMessage(message); err != nil {
+		log.Printf("request %s: unable to publish message: %v", message.RequestID, err)
+		return "Reloaded the queue configuration, but couldn't ask anerbot-response to reload! :cry:"
+	}
+	return "Reloaded the queue configuration, reloading anerbot-response..."
+}
+
+// Function to ask the anerbot-response function for the report of
+// features missing documentation, which it posts to the response URL.
+// Returns the message sent back to the admin who asked for the report.
+func queueNeedsDocs(responseUrl, channelID, userID string) string {
+	message := queueMessage{
+		ResponseUrl: responseUrl,
+		ChannelID:   channelID,
+		RequestID:   newRequestID(),
+		Action:      needsDocsAction,
+		UserID:      userID,
+	}
+	log.Printf("request %s: queueing report of features missing documentation", message.RequestID)
+
+	if err := publishMessage(message); err != nil {
+		log.Printf("request %s: unable to publish message: %v", message.RequestID, err)
+		return failureMessage
+	}
+	text := "Hang tight - looking for features missing documentation."
+	if ackEmoji != "" {
+		text = fmt.Sprintf("%s %s", ackEmoji, text)
+	}
+	return text
+}
+
+// Function to stop the shared Pub/Sub topic and close its client, so that
+// the next message published creates them again with the current settings.
+func resetTopic() {
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+
+	if topic.t != nil {
+		topic.t.Stop()
+	}
+	if topic.client != nil {
+		if err := topic.client.Close(); err != nil {
+			log.Printf("unable to close pubsub client: %v", err)
+		}
+	}
+	topic.client = nil
+	topic.t = nil
+}
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Function to write a secret to a file in a new temporary directory, as
+// a mounted secret would be, returning the path to the file. Call
+// os.RemoveAll on its directory once the test is finished.
+func writeSecret(t *testing.T, secret string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "anerbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetSecret(t *testing.T) {
+	path := writeSecret(t, "from-file")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"env variable", map[string]string{"TEST_SECRET": "from-env", "TEST_SECRET_FILE": ""}, "from-env", false},
+		{"file is read over the env variable", map[string]string{"TEST_SECRET": "from-env", "TEST_SECRET_FILE": path}, "from-file", false},
+		{"missing file", map[string]string{"TEST_SECRET": "from-env", "TEST_SECRET_FILE": path + ".missing"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(tt.env)()
+			got, err := getSecret("TEST_SECRET")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("getSecret() = %q, %v, want %q, error %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReloadReadsRotatedSecrets(t *testing.T) {
+	path := writeSecret(t, testSigSecret)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer useEnv(map[string]string{
+		"SLACK_SIG_SECRET_FILE": path,
+		"SLACK_ADMIN_USERS":     "UADMIN",
+	})()
+	if slackSigSecret != testSigSecret {
+		t.Fatalf("slackSigSecret = %q, want the secret from the file", slackSigSecret)
+	}
+
+	ft := useFakeTopic(t)
+	defer ft.close()
+	if err := ioutil.WriteFile(path, []byte("rotated-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	res := queueCommand(t, signedRequest(slashCommand("reload", "https://hooks.slack.com/commands/1", "C0123456789", "UADMIN")))
+	if !strings.Contains(res.Text, "reloading anerbot-response") {
+		t.Errorf("response = %q, want the reload confirmed", res.Text)
+	}
+	if slackSigSecret != "rotated-secret" {
+		t.Errorf("slackSigSecret = %q, want the rotated secret", slackSigSecret)
+	}
+	messages := ft.messages(t)
+	if len(messages) != 1 || messages[0].Action != reloadAction {
+		t.Errorf("queued %+v, want anerbot-response asked to reload", messages)
+	}
+}
+
+func TestReloadFailure(t *testing.T) {
+	path := writeSecret(t, testSigSecret)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer useEnv(map[string]string{
+		"SLACK_SIG_SECRET_FILE": path,
+		"SLACK_ADMIN_USERS":     "UADMIN",
+	})()
+
+	ft := useFakeTopic(t)
+	defer ft.close()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reloadConfig("https://hooks.slack.com/commands/1"); !strings.Contains(got, "Unable to reload the queue configuration") {
+		t.Errorf("reloadConfig() = %q, want the failure reported", got)
+	}
+	if messages := ft.messages(t); len(messages) != 0 {
+		t.Errorf("queued %d messages, want anerbot-response left alone", len(messages))
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	defer func(s string, c, a []string) { slackSigSecret, slackChannelIDs, adminUsers = s, c, a }(slackSigSecret, slackChannelIDs, adminUsers)
+	slackSigSecret = testSigSecret
+	slackChannelIDs = []string{"C0123456789"}
+	adminUsers = []string{"UADMIN"}
+
+	tests := []struct {
+		name       string
+		text       string
+		userID     string
+		wantAction string
+		wantQuery  string
+	}{
+		{"reload from anyone else is searched for", "reload", "U123", "", "reload"},
+		{"needs-docs from an admin asks for the report", "needs-docs", "UADMIN", needsDocsAction, ""},
+		{"needs-docs from anyone else is searched for", "needs-docs", "U123", "", "needs-docs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := useFakeTopic(t)
+			defer ft.close()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			queueCommand(t, signedRequest(slashCommand(tt.text, slack.URL, "C0123456789", tt.userID)))
+
+			messages := ft.messages(t)
+			if len(messages) != 1 {
+				t.Fatalf("queued %d messages, want 1", len(messages))
+			}
+			if m := messages[0]; m.Action != tt.wantAction || m.Query != tt.wantQuery {
+				t.Errorf("queued action %q query %q, want %q %q", m.Action, m.Query, tt.wantAction, tt.wantQuery)
+			}
+		})
+	}
+}
@@ -2,4 +2,8 @@ module github.com/smfsh/anerbot/queue
 
 go 1.13
 
-require cloud.google.com/go/pubsub v1.6.0
+require (
+	cloud.google.com/go/pubsub v1.6.0
+	google.golang.org/api v0.29.0
+	google.golang.org/grpc v1.30.0
+)
@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInteractionsRespectMaintenanceAndChannels(t *testing.T) {
+	defer func(m bool, c []string) { maintenanceMode, slackChannelIDs = m, c }(maintenanceMode, slackChannelIDs)
+	slackChannelIDs = []string{"C0123456789"}
+
+	actions := []struct {
+		name   string
+		action interactionAction
+	}{
+		{"rerun", interactionAction{ActionID: rerunActionID, Value: "sso"}},
+		{"feature select", interactionAction{ActionID: featureSelectActionID, SelectedOption: &interactionOption{Value: "recAbCdEfGh123456"}}},
+		{"share", interactionAction{ActionID: shareActionID, Value: "sso"}},
+		{"subscribe", interactionAction{ActionID: subscribeActionID, Value: "recAbCdEfGh123456"}},
+		{"field details", interactionAction{ActionID: fieldDetailsActionID, SelectedOption: &interactionOption{Value: `{"id":"recAbCdEfGh123456","field":"Notes"}`}}},
+		{"change page", interactionAction{ActionID: pageActionID, Value: `{"query":"sso","page":2}`}},
+		{"go to page", interactionAction{ActionID: goToPageActionID, BlockID: goToPageBlockPrefix + "sso", Value: "2"}},
+	}
+	tests := []struct {
+		name        string
+		maintenance bool
+		channelID   string
+		wantQueued  bool
+		wantText    string
+	}{
+		{"allowed channel", false, "C0123456789", true, ""},
+		{"maintenance mode", true, "C0123456789", false, maintenanceMessage},
+		{"channel not allowed", false, "C9999999999", false, channelDeniedMessage()},
+	}
+
+	for _, a := range actions {
+		for _, tt := range tests {
+			t.Run(a.name+" in "+tt.name, func(t *testing.T) {
+				maintenanceMode = tt.maintenance
+				ft := useFakeTopic(t)
+				defer ft.close()
+				slack := newFakeSlack()
+				defer slack.Close()
+
+				handleInteraction(httptest.NewRecorder(), testInteraction(t, slack.URL, tt.channelID, a.action))
+
+				if got := len(ft.messages(t)) > 0; got != tt.wantQueued {
+					t.Errorf("queued = %v, want %v", got, tt.wantQueued)
+				}
+				posted := slack.posted()
+				if tt.wantText == "" {
+					if len(posted) > 0 {
+						t.Errorf("posted %+v, want nothing", posted)
+					}
+					return
+				}
+				if len(posted) != 1 || posted[0].Text != tt.wantText {
+					t.Errorf("posted %+v, want %q", posted, tt.wantText)
+				}
+			})
+		}
+	}
+}
+
+func TestLinksAndReportsIgnoreMaintenanceAndChannels(t *testing.T) {
+	defer func(m bool, c []string) { maintenanceMode, slackChannelIDs = m, c }(maintenanceMode, slackChannelIDs)
+	maintenanceMode = true
+	slackChannelIDs = []string{"C0123456789"}
+
+	actions := []struct {
+		name   string
+		action interactionAction
+	}{
+		{"copy link", interactionAction{ActionID: copyLinkActionID, Value: "https://airtable.com/tbl/viw/recAbCdEfGh123456"}},
+		{"report", interactionAction{ActionID: reportActionID, Value: mustJSON(t, reportValue{ID: "recAbCdEfGh123456", Feature: "Single sign-on"})}},
+	}
+	for _, a := range actions {
+		t.Run(a.name, func(t *testing.T) {
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			handleInteraction(httptest.NewRecorder(), testInteraction(t, slack.URL, "C9999999999", a.action))
+
+			if posted := slack.posted(); len(posted) != 1 || posted[0].Text == maintenanceMessage {
+				t.Errorf("posted %+v, want the action answered", posted)
+			}
+		})
+	}
+}
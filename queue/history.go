@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Keyword that makes Anerbot reply with the user's recent searches.
+const historyKeyword = "history"
+
+// Action ID of the buttons that re-run a search from a user's history.
+const rerunActionID = "rerun_search"
+
+// Store of each user's most recent searches, newest first. The history
+// is only kept in memory, so it only lives as long as the GCF instance
+// does and each instance keeps its own.
+var history = struct {
+	mu      sync.Mutex
+	queries map[string][]string
+}{
+	queries: make(map[string][]string),
+}
+
+// Struct for a Slack block in a message sent by Anerbot.
+type messageBlock struct {
+	Type     string        `json:"type"`
+	Text     *textObject   `json:"text,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// Struct for the text of a block or an element.
+type textObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Struct for a button in an actions block.
+type buttonElement struct {
+	Type     string      `json:"type"`
+	ActionID string      `json:"action_id"`
+	Text     *textObject `json:"text"`
+	Value    string      `json:"value"`
+}
+
+// Function to add a search to a user's history. Searching again for a
+// query already in the history moves it to the front, and the oldest
+// searches are dropped once the configured size is reached.
+func recordHistory(userID, query string) {
+	if historySize <= 0 || userID == "" {
+		return
+	}
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	queries := []string{query}
+	for _, q := range history.queries[userID] {
+		if q != query && len(queries) < historySize {
+			queries = append(queries, q)
+		}
+	}
+	history.queries[userID] = queries
+}
+
+// Function to return a user's recent searches, newest first.
+func recentHistory(userID string) []string {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	queries := make([]string, len(history.queries[userID]))
+	copy(queries, history.queries[userID])
+	return queries
+}
+
+// Function to render a user's recent searches as a message with a button
+// to re-run each of them.
+func renderHistory(queries []string) queueResponse {
+	res := queueResponse{
+		ResponseType: "ephemeral",
+	}
+	if len(queries) == 0 {
+		res.Text = "You haven't searched for anything recently! :mag:"
+		return res
+	}
+
+	res.Text = "Here are your recent searches, click one to search again."
+	var buttons []interface{}
+	for _, q := range queries {
+		buttons = append(buttons, buttonElement{
+			Type:     "button",
+			ActionID: rerunActionID,
+			Text:     &textObject{Type: "plain_text", Text: truncate(q, 75)},
+			Value:    q,
+		})
+	}
+	res.Blocks = []messageBlock{
+		{
+			Type: "section",
+			Text: &textObject{Type: "mrkdwn", Text: res.Text},
+		},
+		{
+			Type:     "actions",
+			Elements: buttons,
+		},
+	}
+	return res
+}
+
+// Function to shorten a string to at most n characters, such as to fit
+// the text of a button.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return fmt.Sprintf("%s…", string(r[:n-1]))
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Function to forget every user's history, so each test starts without
+// any recent searches.
+func resetHistory() {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	history.queries = make(map[string][]string)
+}
+
+func TestRecordHistory(t *testing.T) {
+	defer func(s int) { historySize = s }(historySize)
+	defer resetHistory()
+
+	tests := []struct {
+		name     string
+		size     int
+		userID   string
+		searches []string
+		want     []string
+	}{
+		{"newest first", 3, "U123", []string{"sso", "billing"}, []string{"billing", "sso"}},
+		{"oldest dropped at the limit", 3, "U123", []string{"a", "b", "c", "d"}, []string{"d", "c", "b"}},
+		{"repeated search moves to the front", 3, "U123", []string{"a", "b", "c", "a"}, []string{"a", "c", "b"}},
+		{"history turned off", 0, "U123", []string{"sso"}, []string{}},
+		{"no user", 3, "", []string{"sso"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetHistory()
+			historySize = tt.size
+			for _, q := range tt.searches {
+				recordHistory(tt.userID, q)
+			}
+			if got := recentHistory(tt.userID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recentHistory() = %q, want %q", got, tt.want)
+			}
+			if got := recentHistory("U999"); len(got) != 0 {
+				t.Errorf("another user's history = %q, want it empty", got)
+			}
+		})
+	}
+}
+
+func TestHistoryKeyword(t *testing.T) {
+	defer useEnv(map[string]string{
+		"SLACK_SIG_SECRET":   testSigSecret,
+		"SLACK_CHANNEL_ID":   "C0123456789",
+		"QUERY_HISTORY_SIZE": "",
+	})()
+	defer resetHistory()
+	resetHistory()
+	ft := useFakeTopic(t)
+	defer ft.close()
+
+	res := queueCommand(t, signedRequest(slashCommand("History", "https://hooks.slack.com/x", "C0123456789", "U123")))
+	if res.Text != "You haven't searched for anything recently! :mag:" || len(res.Blocks) != 0 {
+		t.Errorf("empty history = %+v, want a message saying so", res)
+	}
+
+	for _, q := range []string{"sso", "billing"} {
+		queueCommand(t, signedRequest(slashCommand(q, "https://hooks.slack.com/x", "C0123456789", "U123")))
+	}
+	res = queueCommand(t, signedRequest(slashCommand("history", "https://hooks.slack.com/x", "C0123456789", "U123")))
+	if len(res.Blocks) != 2 || res.Blocks[1].Type != "actions" {
+		t.Fatalf("history = %+v, want a section and a row of buttons", res)
+	}
+	var got []string
+	for _, e := range res.Blocks[1].Elements {
+		b := e.(map[string]interface{})
+		if b["action_id"] != rerunActionID {
+			t.Errorf("button action = %v, want %s", b["action_id"], rerunActionID)
+		}
+		got = append(got, b["value"].(string))
+	}
+	if want := []string{"billing", "sso"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buttons re-run %q, want %q", got, want)
+	}
+	if messages := ft.messages(t); len(messages) != 2 {
+		t.Errorf("queued %d messages, want only the two searches", len(messages))
+	}
+}
+
+func TestRenderHistoryTruncatesButtons(t *testing.T) {
+	long := "single sign on for every workspace in the organization including the ones added later"
+	res := renderHistory([]string{long})
+	b := res.Blocks[1].Elements[0].(buttonElement)
+	if got := []rune(b.Text.Text); len(got) != 75 || got[74] != '…' {
+		t.Errorf("button text = %q, want it cut to 75 characters", b.Text.Text)
+	}
+	if b.Value != long {
+		t.Errorf("button value = %q, want the whole query", b.Value)
+	}
+}
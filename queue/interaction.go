@@ -0,0 +1,415 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// Action IDs of the interactive components added to results by the
+// anerbot-response function.
+const (
+	copyLinkActionID      = "copy_link"
+	featureSelectActionID = "feature_select"
+	reportActionID        = "report_incorrect"
+	shareActionID         = "share_results"
+	subscribeActionID     = "subscribe_updates"
+	fieldDetailsActionID  = "field_details"
+)
+
+// Actions sent to the anerbot-response function when a user picks a
+// feature from the feature select menu, subscribes to updates on a
+// feature or picks one of a feature's collapsed fields.
+const (
+	lookupAction       = "lookup"
+	subscribeAction    = "subscribe"
+	fieldDetailsAction = "field_details"
+)
+
+// Pattern matching an Airtable record ID, the value of each option in the
+// feature select menu.
+var recordIDPattern = regexp.MustCompile(`^rec[A-Za-z0-9]{14}$`)
+
+// Struct for the value of a "report incorrect data" button, identifying
+// the feature that was reported.
+type reportValue struct {
+	ID      string `json:"id"`
+	Feature string `json:"feature"`
+	Link    string `json:"link"`
+}
+
+// Struct for a message posted to the feedback channel's incoming webhook.
+type feedbackMessage struct {
+	Text string `json:"text"`
+}
+
+// Interaction types Anerbot handles. Slack sends "block_actions" when a
+// user clicks a button or picks an option in a menu.
+const (
+	blockActionsType = "block_actions"
+)
+
+// Struct for the payload Slack sends when a user interacts with one of
+// the components in a message. Buttons send their value directly, while
+// select menus send the option that was picked.
+type interactionPayload struct {
+	Type        string               `json:"type"`
+	Token       string               `json:"token"`
+	TriggerID   string               `json:"trigger_id"`
+	ResponseUrl string               `json:"response_url"`
+	User        interactionUser      `json:"user"`
+	Team        interactionTeam      `json:"team"`
+	Enterprise  *interactionTeam     `json:"enterprise"`
+	Channel     interactionChannel   `json:"channel"`
+	Container   interactionContainer `json:"container"`
+	Actions     []interactionAction  `json:"actions"`
+}
+
+// Struct for the user who interacted with a component.
+type interactionUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	TeamID   string `json:"team_id"`
+}
+
+// Struct for the workspace the interaction happened in, also used for the
+// Enterprise Grid organization the workspace belongs to.
+type interactionTeam struct {
+	ID     string `json:"id"`
+	Domain string `json:"domain"`
+}
+
+// Struct for the channel the interaction happened in.
+type interactionChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Struct for the message containing the component that was used.
+type interactionContainer struct {
+	Type        string `json:"type"`
+	MessageTs   string `json:"message_ts"`
+	ChannelID   string `json:"channel_id"`
+	IsEphemeral bool   `json:"is_ephemeral"`
+}
+
+// Struct for a single action taken by the user. Buttons set the value,
+// while menus set the selected option.
+type interactionAction struct {
+	Type           string             `json:"type"`
+	ActionID       string             `json:"action_id"`
+	BlockID        string             `json:"block_id"`
+	Value          string             `json:"value"`
+	SelectedOption *interactionOption `json:"selected_option"`
+	ActionTs       string             `json:"action_ts"`
+}
+
+// Struct for an option picked from a menu.
+type interactionOption struct {
+	Text struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"text"`
+	Value string `json:"value"`
+}
+
+// Function to parse the JSON sent by Slack in the payload field of an
+// interaction request. Only block actions are supported.
+func parseInteraction(payload string) (interactionPayload, error) {
+	var p interactionPayload
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return p, fmt.Errorf("unable to parse interaction payload: %v", err)
+	}
+	if p.Type != blockActionsType {
+		return p, fmt.Errorf("unsupported interaction type %q", p.Type)
+	}
+	return p, nil
+}
+
+// Function to return the value chosen by an action, whether it was a
+// button's value or the option picked from a menu.
+func (a interactionAction) selectedValue() string {
+	if a.SelectedOption != nil {
+		return a.SelectedOption.Value
+	}
+	return a.Value
+}
+
+// Function to handle a user interacting with a component in one of
+// Anerbot's messages. Any reply is posted to the response URL from the
+// payload, and the interaction itself is acknowledged with an empty 200.
+// The request's signature must already have been verified. Every call
+// made while handling it gives up well within the three seconds Slack
+// waits for the acknowledgement.
+func handleInteraction(w http.ResponseWriter, payload string) {
+	p, err := parseInteraction(payload)
+	if err != nil {
+		log.Printf("%v", err)
+		http.Error(w, "Couldn't parse payload", 400)
+		return
+	}
+	if !verifyToken(p.Token) {
+		log.Printf("unable to validate interaction: verification token did not match")
+		http.Error(w, "Invalid verification token", 401)
+		return
+	}
+
+	for _, a := range p.Actions {
+		switch a.ActionID {
+		case copyLinkActionID:
+			// Post the link on its own as plain text so it's easy to
+			// copy, especially on mobile. Nothing is searched, so the
+			// link is posted even in maintenance mode or outside of the
+			// allowed channels.
+			err := postToSlack(p.ResponseUrl, queueResponse{
+				ResponseType: "ephemeral",
+				Text:         a.selectedValue(),
+			})
+			if err != nil {
+				log.Printf("unable to send link to Slack: %v", err)
+			}
+		case featureSelectActionID:
+			if a.SelectedOption != nil && interactionAllowed(p) {
+				queueLookup(p, a.SelectedOption.Value)
+			}
+		case reportActionID:
+			// Reports are passed on to the feedback channel without
+			// searching, so they're taken even in maintenance mode or
+			// outside of the allowed channels, when incorrect data is
+			// still worth hearing about.
+			reportIncorrectData(p, a.selectedValue())
+		case rerunActionID:
+			if interactionAllowed(p) && queueInteractionSearch(p, a.selectedValue(), false, 0) {
+				recordHistory(p.User.ID, a.selectedValue())
+			}
+		case shareActionID:
+			if interactionAllowed(p) {
+				queueInteractionSearch(p, a.selectedValue(), true, 0)
+			}
+		case subscribeActionID:
+			if interactionAllowed(p) {
+				queueSubscription(p, a.selectedValue())
+			}
+		case pageActionID:
+			if interactionAllowed(p) {
+				changePage(p, a.selectedValue())
+			}
+		case goToPageActionID:
+			if interactionAllowed(p) {
+				goToPage(p, a)
+			}
+		case fieldDetailsActionID:
+			if interactionAllowed(p) {
+				queueFieldDetails(p, a.selectedValue())
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Function to check that an interaction may start a search, making the
+// same checks as a slash command. When it may not, such as in maintenance
+// mode or outside of the allowed channels, the reason is posted to the
+// response URL of the interaction instead.
+func interactionAllowed(p interactionPayload) bool {
+	var enterpriseID string
+	if p.Enterprise != nil {
+		enterpriseID = p.Enterprise.ID
+	}
+	text := searchBlocked(p.Channel.ID, p.Team.ID, enterpriseID)
+	if text == "" {
+		return true
+	}
+
+	err := postToSlack(p.ResponseUrl, queueResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+	})
+	if err != nil {
+		log.Printf("unable to send message to Slack: %v", err)
+	}
+	return false
+}
+
+// Function to show the feature picked from the feature select menu. The
+// option's value is the feature's record ID, which the anerbot-response
+// function looks up so exactly that feature is shown. Menus posted before
+// options carried record IDs have the feature's name as their value, so
+// those are searched for by the exact name instead.
+func queueLookup(p interactionPayload, value string) {
+	message := queueMessage{
+		ResponseUrl: p.ResponseUrl,
+		ChannelID:   p.Channel.ID,
+		Action:      lookupAction,
+		Value:       value,
+		RequestID:   newRequestID(),
+	}
+	if !recordIDPattern.MatchString(value) {
+		message = queueMessage{
+			Query:       fmt.Sprintf(`"%s"`, value),
+			ResponseUrl: p.ResponseUrl,
+			ChannelID:   p.Channel.ID,
+			RequestID:   message.RequestID,
+		}
+	}
+	log.Printf("request %s: queueing lookup of %s", message.RequestID, logQuery(value))
+
+	if err := publishMessage(message); err != nil {
+		log.Printf("unable to publish message: %v", err)
+		err = postToSlack(p.ResponseUrl, queueResponse{
+			ResponseType: "ephemeral",
+			Text:         "Anerbot couldn't look up that feature, try again! :cry:",
+		})
+		if err != nil {
+			log.Printf("unable to send failure message to Slack: %v", err)
+		}
+	}
+}
+
+// Function to queue a search started by an interaction, such as a search
+// run again from the user's history or results shared with the channel.
+// The results are posted to the response URL of the interaction. Shared
+// results are posted to the whole channel. The page is the page of
+// results to show, or 0 for the first page. Reports whether the search
+// was queued.
+func queueInteractionSearch(p interactionPayload, query string, shared bool, page int) bool {
+	message := queueMessage{
+		Query:       query,
+		ResponseUrl: p.ResponseUrl,
+		ChannelID:   p.Channel.ID,
+		RequestID:   newRequestID(),
+		Shared:      shared,
+		UserID:      p.User.ID,
+		Page:        page,
+	}
+	log.Printf("request %s: queueing search from an interaction for %s", message.RequestID, logQuery(query))
+
+	if err := publishMessage(message); err != nil {
+		log.Printf("request %s: unable to publish message: %v", message.RequestID, err)
+		err = postToSlack(p.ResponseUrl, queueResponse{
+			ResponseType: "ephemeral",
+			Text:         failureMessage,
+		})
+		if err != nil {
+			log.Printf("unable to send failure message to Slack: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// Function to pass a subscription to updates on a feature on to the
+// anerbot-response function, which keeps the subscriptions and confirms
+// the subscription at the response URL of the interaction. The value of
+// the button is passed on as it is.
+func queueSubscription(p interactionPayload, value string) {
+	message := queueMessage{
+		ResponseUrl: p.ResponseUrl,
+		ChannelID:   p.Channel.ID,
+		RequestID:   newRequestID(),
+		Action:      subscribeAction,
+		UserID:      p.User.ID,
+		Value:       value,
+	}
+	log.Printf("request %s: queueing subscription for %s", message.RequestID, p.User.ID)
+
+	if err := publishMessage(message); err != nil {
+		log.Printf("request %s: unable to publish message: %v", message.RequestID, err)
+		err = postToSlack(p.ResponseUrl, queueResponse{
+			ResponseType: "ephemeral",
+			Text:         failureMessage,
+		})
+		if err != nil {
+			log.Printf("unable to send failure message to Slack: %v", err)
+		}
+	}
+}
+
+// Function to show the full value of a collapsed field picked from the
+// overflow menu beside a feature, which only has room for the start of
+// it. The anerbot-response function looks the field up and shows it to
+// the user who picked it. The value of the option is passed on as it is.
+func queueFieldDetails(p interactionPayload, value string) {
+	message := queueMessage{
+		ResponseUrl: p.ResponseUrl,
+		ChannelID:   p.Channel.ID,
+		RequestID:   newRequestID(),
+		Action:      fieldDetailsAction,
+		UserID:      p.User.ID,
+		Value:       value,
+	}
+	log.Printf("request %s: queueing field details for %s", message.RequestID, p.User.ID)
+
+	if err := publishMessage(message); err != nil {
+		log.Printf("request %s: unable to publish message: %v", message.RequestID, err)
+		err = postToSlack(p.ResponseUrl, queueResponse{
+			ResponseType: "ephemeral",
+			Text:         failureMessage,
+		})
+		if err != nil {
+			log.Printf("unable to send failure message to Slack: %v", err)
+		}
+	}
+}
+
+// Function to pass a report of incorrect data on to the feedback channel
+// and thank the user who reported it. Reports are logged when no feedback
+// channel is configured so they aren't lost.
+func reportIncorrectData(p interactionPayload, value string) {
+	var r reportValue
+	if err := json.Unmarshal([]byte(value), &r); err != nil {
+		log.Printf("unable to parse report value: %v", err)
+		return
+	}
+
+	text := feedbackText(p.User, r)
+	if feedbackWebhookURL == "" {
+		log.Printf("incorrect data reported: %s", text)
+	} else if err := postToSlack(feedbackWebhookURL, feedbackMessage{Text: text}); err != nil {
+		log.Printf("unable to send report to the feedback channel: %v", err)
+		return
+	}
+
+	err := postToSlack(p.ResponseUrl, queueResponse{
+		ResponseType: "ephemeral",
+		Text:         fmt.Sprintf(`Thanks for letting us know that "%s" looks wrong! :pray:`, r.Feature),
+	})
+	if err != nil {
+		log.Printf("unable to send report confirmation to Slack: %v", err)
+	}
+}
+
+// Function to render the message describing a report of incorrect data,
+// naming both the feature and the user who reported it.
+func feedbackText(user interactionUser, r reportValue) string {
+	return fmt.Sprintf("<@%s> reported incorrect data on <%s|%s> (%s)", user.ID, r.Link, r.Feature, r.ID)
+}
+
+// Function to post a message to Slack at a response URL, giving up once
+// the Slack timeout has passed.
+func postToSlack(url string, message interface{}) error {
+	// Marshal the message into JSON and prepare the request to be sent
+	// to the URL passed into this function.
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("unable to convert slack message to JSON: %v", err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("unable to build new HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Perform the request (posting our message to Slack,) and
+	// close out the response body sent back.
+	resp, err := slackClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send message to Slack: %v", err)
+	}
+	defer resp.Body.Close()
+	return nil
+}
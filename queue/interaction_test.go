@@ -0,0 +1,288 @@
+package queue
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyLinkPostsTheLink(t *testing.T) {
+	tests := []struct {
+		name   string
+		action interactionAction
+		want   string
+	}{
+		{"button value", interactionAction{ActionID: copyLinkActionID, Value: "https://airtable.com/tbl/viw/recAbCdEfGh123456"}, "https://airtable.com/tbl/viw/recAbCdEfGh123456"},
+		{"selected option", interactionAction{ActionID: copyLinkActionID, SelectedOption: &interactionOption{Value: "https://airtable.com/tbl/viw/recOther0000000000"}}, "https://airtable.com/tbl/viw/recOther0000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := useFakeTopic(t)
+			defer ft.close()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			w := httptest.NewRecorder()
+			handleInteraction(w, testInteraction(t, slack.URL, "C0123456789", tt.action))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			posted := slack.posted()
+			if len(posted) != 1 || posted[0].ResponseType != "ephemeral" || posted[0].Text != tt.want {
+				t.Errorf("posted %+v, want the link %q on its own", posted, tt.want)
+			}
+			if got := len(ft.messages(t)); got != 0 {
+				t.Errorf("queued %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestInteractionAnsweredWhileSlackIsSlow(t *testing.T) {
+	// Slack waits three seconds for an interaction to be acknowledged, so
+	// a response URL or feedback webhook that never answers mustn't hold
+	// the handler up.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(3 * time.Second)
+	}))
+	defer slow.Close()
+	defer useEnv(map[string]string{"SLACK_FEEDBACK_WEBHOOK_URL": slow.URL})()
+	ft := useFakeTopic(t)
+	defer ft.close()
+
+	tests := []struct {
+		name   string
+		action interactionAction
+	}{
+		{"copy link", interactionAction{ActionID: copyLinkActionID, Value: "https://airtable.com/tbl/viw/recAbCdEfGh123456"}},
+		{"report incorrect data", interactionAction{ActionID: reportActionID, Value: mustJSON(t, reportValue{ID: "recAbCdEfGh123456", Feature: "Single sign-on"})}},
+		{"share results", interactionAction{ActionID: shareActionID, Value: "sso"}},
+		{"subscribe", interactionAction{ActionID: subscribeActionID, Value: "recAbCdEfGh123456"}},
+		{"field details", interactionAction{ActionID: fieldDetailsActionID, SelectedOption: &interactionOption{Value: `{"id":"recAbCdEfGh123456","field":"Notes"}`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			w := httptest.NewRecorder()
+			handleInteraction(w, testInteraction(t, slow.URL, "C0123456789", tt.action))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("interaction answered after %v, want well within three seconds", elapsed)
+			}
+		})
+	}
+}
+
+func TestFeatureSelectQueuesLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		wantAction string
+		wantValue  string
+		wantQuery  string
+	}{
+		{"record ID is looked up exactly", "recAbCdEfGh123456", lookupAction, "recAbCdEfGh123456", ""},
+		{"feature name is searched for exactly", "Single sign-on", "", "", `"Single sign-on"`},
+	}
+
+	defer func(c []string) { slackChannelIDs = c }(slackChannelIDs)
+	slackChannelIDs = []string{"C0123456789"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := useFakeTopic(t)
+			defer ft.close()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			payload := testInteraction(t, slack.URL, "C0123456789", interactionAction{
+				ActionID:       featureSelectActionID,
+				SelectedOption: &interactionOption{Value: tt.value},
+			})
+			w := httptest.NewRecorder()
+			handleInteraction(w, payload)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			messages := ft.messages(t)
+			if len(messages) != 1 {
+				t.Fatalf("published %d messages, want 1", len(messages))
+			}
+			m := messages[0]
+			if m.Action != tt.wantAction || m.Value != tt.wantValue || m.Query != tt.wantQuery {
+				t.Errorf("published action %q value %q query %q, want %q %q %q", m.Action, m.Value, m.Query, tt.wantAction, tt.wantValue, tt.wantQuery)
+			}
+			if m.ResponseUrl != slack.URL || m.ChannelID != "C0123456789" {
+				t.Errorf("published message %+v doesn't reply to the interaction", m)
+			}
+		})
+	}
+}
+
+// Block actions payload as Slack sends it when a button is clicked.
+const sampleBlockActions = `{
+	"type": "block_actions",
+	"token": "legacy-token",
+	"trigger_id": "123.456.abc",
+	"response_url": "https://hooks.slack.com/actions/T0123/456/xyz",
+	"user": {"id": "U123", "username": "sam", "team_id": "T0123"},
+	"team": {"id": "T0123", "domain": "snyk"},
+	"enterprise": {"id": "E0123", "domain": "snyk-grid"},
+	"channel": {"id": "C0123456789", "name": "product"},
+	"container": {"type": "message", "message_ts": "1600000000.000100", "channel_id": "C0123456789", "is_ephemeral": true},
+	"actions": [{
+		"type": "button",
+		"action_id": "share_results",
+		"block_id": "abc",
+		"value": "sso",
+		"action_ts": "1600000001.000200"
+	}]
+}`
+
+func TestParseInteraction(t *testing.T) {
+	p, err := parseInteraction(sampleBlockActions)
+	if err != nil {
+		t.Fatalf("parseInteraction() error = %v", err)
+	}
+	if p.ResponseUrl != "https://hooks.slack.com/actions/T0123/456/xyz" || p.User.ID != "U123" || p.Team.ID != "T0123" ||
+		p.Enterprise == nil || p.Enterprise.ID != "E0123" || p.Channel.ID != "C0123456789" || !p.Container.IsEphemeral {
+		t.Errorf("parseInteraction() = %+v, want the details of the interaction", p)
+	}
+	if len(p.Actions) != 1 || p.Actions[0].ActionID != shareActionID || p.Actions[0].selectedValue() != "sso" {
+		t.Errorf("actions = %+v, want the share button", p.Actions)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"not JSON", "payload"},
+		{"other interaction type", `{"type":"view_submission"}`},
+	}
+	for _, tt := range tests {
+		if _, err := parseInteraction(tt.payload); err == nil {
+			t.Errorf("parseInteraction() of %s succeeded, want an error", tt.name)
+		}
+		w := httptest.NewRecorder()
+		handleInteraction(w, tt.payload)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("handleInteraction() of %s status = %d, want %d", tt.name, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestQueueRoutesSignedInteractions(t *testing.T) {
+	defer useEnv(map[string]string{"SLACK_SIG_SECRET": testSigSecret, "SLACK_CHANNEL_ID": "C0123456789"})()
+	ft := useFakeTopic(t)
+	defer ft.close()
+	slack := newFakeSlack()
+	defer slack.Close()
+
+	payload := testInteraction(t, slack.URL, "C0123456789", interactionAction{ActionID: shareActionID, Value: "sso"})
+	w := httptest.NewRecorder()
+	Queue(w, signedRequest(url.Values{"payload": {payload}}))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want an empty 200", w.Code, w.Body.String())
+	}
+	if messages := ft.messages(t); len(messages) != 1 || !messages[0].Shared || messages[0].Query != "sso" {
+		t.Errorf("queued %+v, want the shared search", messages)
+	}
+}
+
+func TestReportIncorrectData(t *testing.T) {
+	const want = "<@U123> reported incorrect data on <https://airtable.com/tblFeatures/viwAll/recSso00000000001|Single sign-on> (recSso00000000001)"
+	value := mustJSON(t, reportValue{ID: "recSso00000000001", Feature: "Single sign-on", Link: "https://airtable.com/tblFeatures/viwAll/recSso00000000001"})
+
+	for _, configured := range []bool{true, false} {
+		feedback := newFakeSlack()
+		slack := newFakeSlack()
+		webhook := ""
+		if configured {
+			webhook = feedback.URL
+		}
+		restore := useEnv(map[string]string{"SLACK_FEEDBACK_WEBHOOK_URL": webhook})
+
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		w := httptest.NewRecorder()
+		handleInteraction(w, testInteraction(t, slack.URL, "C0123456789", interactionAction{ActionID: reportActionID, Value: value}))
+		log.SetOutput(os.Stderr)
+		restore()
+		feedback.Close()
+		slack.Close()
+
+		if w.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if configured {
+			if got := feedback.posted(); len(got) != 1 || got[0].Text != want {
+				t.Errorf("feedback channel got %+v, want %q", got, want)
+			}
+		} else if !strings.Contains(buf.String(), want) {
+			t.Errorf("logs = %q, want the report logged", buf.String())
+		}
+		if got := slack.posted(); len(got) != 1 || got[0].Text != `Thanks for letting us know that "Single sign-on" looks wrong! :pray:` {
+			t.Errorf("reporter got %+v, want a thank you", got)
+		}
+	}
+}
+
+func TestFieldDetailsQueued(t *testing.T) {
+	tests := []struct {
+		name       string
+		channelID  string
+		wantQueued bool
+	}{
+		{"allowed channel queues the field", "C0123456789", true},
+		{"other channel is turned away", "C0999999999", false},
+	}
+
+	defer func(c []string) { slackChannelIDs = c }(slackChannelIDs)
+	slackChannelIDs = []string{"C0123456789"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := useFakeTopic(t)
+			defer ft.close()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			value := `{"id":"recAbCdEfGh123456","field":"Notes"}`
+			payload := testInteraction(t, slack.URL, tt.channelID, interactionAction{
+				ActionID:       fieldDetailsActionID,
+				SelectedOption: &interactionOption{Value: value},
+			})
+			w := httptest.NewRecorder()
+			handleInteraction(w, payload)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			messages := ft.messages(t)
+			if !tt.wantQueued {
+				if len(messages) != 0 {
+					t.Errorf("published %d messages, want none", len(messages))
+				}
+				if posted := slack.posted(); len(posted) != 1 || posted[0].Text != channelDeniedMessage() {
+					t.Errorf("posted %+v, want %q", posted, channelDeniedMessage())
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("published %d messages, want 1", len(messages))
+			}
+			if m := messages[0]; m.Action != fieldDetailsAction || m.Value != value || m.UserID != "U123" {
+				t.Errorf("published %+v, want action %q with value %q", m, fieldDetailsAction, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Action IDs of the components for moving between pages of results, and
+// the prefixes of the block ID of the "go to page" input, which are
+// followed by the query or the action of the admin report being paged
+// through.
+const (
+	pageActionID              = "change_page"
+	goToPageActionID          = "go_to_page"
+	goToPageBlockPrefix       = "go_to_page:"
+	goToReportPageBlockPrefix = "go_to_report_page:"
+)
+
+// Struct for the value of a "previous" or "next" page button, identifying
+// the search or admin report being paged through and the page to show.
+type pageValue struct {
+	Query  string `json:"query"`
+	Page   int    `json:"page"`
+	Report string `json:"report,omitempty"`
+}
+
+// Function to show another page of results when the user clicks the
+// "previous" or "next" button.
+func changePage(p interactionPayload, value string) {
+	var v pageValue
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		log.Printf("unable to parse page value: %v", err)
+		return
+	}
+	if v.Report != "" {
+		queueReportPage(p, v.Report, v.Page)
+		return
+	}
+	queueInteractionSearch(p, v.Query, !p.Container.IsEphemeral, v.Page)
+}
+
+// Function to show the page of results typed into the "go to page" input.
+// Anything other than a whole number is rejected with a reply explaining
+// what was expected. Pages past either end of the results are clamped to
+// them by the anerbot-response function, which knows how many there are.
+func goToPage(p interactionPayload, a interactionAction) {
+	page, err := strconv.Atoi(strings.TrimSpace(a.Value))
+	if err != nil {
+		err := postToSlack(p.ResponseUrl, queueResponse{
+			ResponseType: "ephemeral",
+			Text:         fmt.Sprintf(`"%s" isn't a page number, try a whole number such as 2.`, strings.TrimSpace(a.Value)),
+		})
+		if err != nil {
+			log.Printf("unable to send page number message to Slack: %v", err)
+		}
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if strings.HasPrefix(a.BlockID, goToReportPageBlockPrefix) {
+		queueReportPage(p, strings.TrimPrefix(a.BlockID, goToReportPageBlockPrefix), page)
+		return
+	}
+	queueInteractionSearch(p, strings.TrimPrefix(a.BlockID, goToPageBlockPrefix), !p.Container.IsEphemeral, page)
+}
+
+// Function to ask the anerbot-response function for another page of an
+// admin report, which runs the report again rather than searching for its
+// query. Only the needs-docs report can be paged through, and only by an
+// admin; anything else is logged and ignored.
+func queueReportPage(p interactionPayload, report string, page int) {
+	if report != needsDocsAction || !adminAllowed(p.User.ID) {
+		log.Printf("ignoring page %d of report %q from %s", page, report, p.User.ID)
+		return
+	}
+	message := queueMessage{
+		ResponseUrl: p.ResponseUrl,
+		ChannelID:   p.Channel.ID,
+		RequestID:   newRequestID(),
+		Action:      report,
+		UserID:      p.User.ID,
+		Page:        page,
+	}
+	log.Printf("request %s: queueing page %d of report %s", message.RequestID, page, report)
+
+	if err := publishMessage(message); err != nil {
+		log.Printf("request %s: unable to publish message: %v", message.RequestID, err)
+		err = postToSlack(p.ResponseUrl, queueResponse{
+			ResponseType: "ephemeral",
+			Text:         failureMessage,
+		})
+		if err != nil {
+			log.Printf("unable to send failure message to Slack: %v", err)
+		}
+	}
+}
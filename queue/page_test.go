@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageInteractions(t *testing.T) {
+	defer func(c []string) { slackChannelIDs = c }(slackChannelIDs)
+	slackChannelIDs = []string{"C0123456789"}
+
+	tests := []struct {
+		name       string
+		action     interactionAction
+		ephemeral  bool
+		wantQueued bool
+		wantQuery  string
+		wantPage   int
+		wantShared bool
+	}{
+		{"next page", interactionAction{ActionID: pageActionID, Value: `{"query":"sso","page":2}`}, true, true, "sso", 2, false},
+		{"next page of shared results", interactionAction{ActionID: pageActionID, Value: `{"query":"sso","page":3}`}, false, true, "sso", 3, true},
+		{"malformed page value", interactionAction{ActionID: pageActionID, Value: "2"}, true, false, "", 0, false},
+		{"go to page", interactionAction{ActionID: goToPageActionID, BlockID: goToPageBlockPrefix + "sso billing", Value: " 4 "}, true, true, "sso billing", 4, false},
+		{"go to page before the first", interactionAction{ActionID: goToPageActionID, BlockID: goToPageBlockPrefix + "sso", Value: "-3"}, true, true, "sso", 1, false},
+		{"go to a page that isn't a number", interactionAction{ActionID: goToPageActionID, BlockID: goToPageBlockPrefix + "sso", Value: "two"}, true, false, "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := useFakeTopic(t)
+			defer ft.close()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			p := interactionPayload{
+				Type:        blockActionsType,
+				ResponseUrl: slack.URL,
+				Channel:     interactionChannel{ID: "C0123456789"},
+				Container:   interactionContainer{IsEphemeral: tt.ephemeral},
+				Actions:     []interactionAction{tt.action},
+			}
+			handleInteraction(httptest.NewRecorder(), mustJSON(t, p))
+
+			messages := ft.messages(t)
+			if !tt.wantQueued {
+				if len(messages) > 0 {
+					t.Errorf("queued %+v, want nothing", messages)
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("queued %d messages, want 1", len(messages))
+			}
+			m := messages[0]
+			if m.Query != tt.wantQuery || m.Page != tt.wantPage || m.Shared != tt.wantShared {
+				t.Errorf("queued query %q page %d shared %v, want %q %d %v", m.Query, m.Page, m.Shared, tt.wantQuery, tt.wantPage, tt.wantShared)
+			}
+		})
+	}
+}
+
+func TestReportPageInteractions(t *testing.T) {
+	defer func(c, a []string) { slackChannelIDs, adminUsers = c, a }(slackChannelIDs, adminUsers)
+	slackChannelIDs = []string{"C0123456789"}
+	adminUsers = []string{"UADMIN"}
+
+	tests := []struct {
+		name       string
+		action     interactionAction
+		userID     string
+		wantQueued bool
+		wantPage   int
+	}{
+		{"next page of the report", interactionAction{ActionID: pageActionID, Value: `{"query":"brief documented:false","page":2,"report":"needs_docs"}`}, "UADMIN", true, 2},
+		{"go to page of the report", interactionAction{ActionID: goToPageActionID, BlockID: goToReportPageBlockPrefix + needsDocsAction, Value: "3"}, "UADMIN", true, 3},
+		{"report paged by anyone else", interactionAction{ActionID: pageActionID, Value: `{"query":"brief documented:false","page":2,"report":"needs_docs"}`}, "U123", false, 0},
+		{"report that can't be paged", interactionAction{ActionID: pageActionID, Value: `{"query":"sso","page":2,"report":"reload"}`}, "UADMIN", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := useFakeTopic(t)
+			defer ft.close()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			p := interactionPayload{
+				Type:        blockActionsType,
+				ResponseUrl: slack.URL,
+				User:        interactionUser{ID: tt.userID},
+				Channel:     interactionChannel{ID: "C0123456789"},
+				Container:   interactionContainer{IsEphemeral: true},
+				Actions:     []interactionAction{tt.action},
+			}
+			handleInteraction(httptest.NewRecorder(), mustJSON(t, p))
+
+			messages := ft.messages(t)
+			if !tt.wantQueued {
+				if len(messages) > 0 {
+					t.Errorf("queued %+v, want nothing", messages)
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("queued %d messages, want 1", len(messages))
+			}
+			if m := messages[0]; m.Action != needsDocsAction || m.Query != "" || m.Page != tt.wantPage {
+				t.Errorf("queued action %q query %q page %d, want the report re-run at page %d", m.Action, m.Query, m.Page, tt.wantPage)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPermalink(t *testing.T) {
+	defer func(u, s string) { permalinkURL, permalinkSecret = u, s }(permalinkURL, permalinkSecret)
+	permalinkURL = "https://example.com/anerbot-search"
+
+	tests := []struct {
+		name    string
+		secret  string
+		query   string
+		wantSig string
+	}{
+		{"unsigned without a secret", "", "sso", ""},
+		{"signed with the secret", "s3cret", "sso", permalinkSignature("sso", "s3cret")},
+		{"signature covers the query", "s3cret", "sso billing", permalinkSignature("sso billing", "s3cret")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permalinkSecret = tt.secret
+			link := permalink(tt.query)
+			if !strings.HasPrefix(link, permalinkURL+"?") {
+				t.Fatalf("permalink(%q) = %s, want it under %s", tt.query, link, permalinkURL)
+			}
+			u, err := url.Parse(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Query().Get("q"); got != tt.query {
+				t.Errorf("query = %q, want %q", got, tt.query)
+			}
+			if got := u.Query().Get("sig"); got != tt.wantSig {
+				t.Errorf("sig = %q, want %q", got, tt.wantSig)
+			}
+		})
+	}
+
+	if permalinkSignature("sso", "a") == permalinkSignature("sso", "b") {
+		t.Error("signatures with different secrets match")
+	}
+}
+
+func TestLinkKeyword(t *testing.T) {
+	defer useEnv(map[string]string{
+		"SLACK_SIG_SECRET": testSigSecret,
+		"SLACK_CHANNEL_ID": "C0123456789",
+		"PERMALINK_URL":    "https://example.com/anerbot-search",
+		"PERMALINK_SECRET": "s3cret",
+	})()
+
+	tests := []struct {
+		name  string
+		text  string
+		query string
+	}{
+		{"single word", "link sso", "sso"},
+		{"quotes and operators", `link "single sign-on" OR saml -beta`, `"single sign-on" OR saml -beta`},
+		{"characters escaped in URLs", "search link plan:team & more?", "plan:team & more?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			res := queueCommand(t, signedRequest(slashCommand(tt.text, "https://hooks.slack.com/x", "C0123456789", "U123")))
+			if got := len(ft.messages(t)); got != 0 {
+				t.Errorf("queued %d messages, want none", got)
+			}
+			i := strings.Index(res.Text, permalinkURL)
+			if i < 0 {
+				t.Fatalf("response = %q, want a permalink", res.Text)
+			}
+			u, err := url.Parse(res.Text[i:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Query().Get("q"); got != tt.query {
+				t.Errorf("permalink query = %q, want %q", got, tt.query)
+			}
+			if got := u.Query().Get("sig"); got != permalinkSignature(tt.query, "s3cret") {
+				t.Errorf("permalink sig = %q, want it signed", got)
+			}
+		})
+	}
+}
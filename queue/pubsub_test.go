@@ -0,0 +1,206 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+// Struct for a fake Pub/Sub topic served from memory, which every
+// message published by the function is sent to while it is in use.
+type fakeTopic struct {
+	srv    *pstest.Server
+	client *pubsub.Client
+}
+
+// Function to point the shared topic at an in-memory Pub/Sub server,
+// returning the fake topic. Call close once the test is finished to put
+// the real topic back.
+func useFakeTopic(t *testing.T) *fakeTopic {
+	t.Helper()
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unable to connect to fake pubsub: %v", err)
+	}
+	client, err := pubsub.NewClient(ctx, "anerbot-test", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("unable to create fake pubsub client: %v", err)
+	}
+	pt, err := client.CreateTopic(ctx, "anerbot")
+	if err != nil {
+		t.Fatalf("unable to create fake topic: %v", err)
+	}
+
+	topic.mu.Lock()
+	topic.client, topic.t = client, pt
+	topic.mu.Unlock()
+	return &fakeTopic{srv: srv, client: client}
+}
+
+// Function to put the real topic back and stop the fake server.
+func (f *fakeTopic) close() {
+	topic.mu.Lock()
+	topic.t.Stop()
+	topic.client, topic.t = nil, nil
+	topic.mu.Unlock()
+	f.client.Close()
+	f.srv.Close()
+}
+
+// Function to delete the fake topic, so every message published to it
+// from then on fails.
+func (f *fakeTopic) fail(t *testing.T) {
+	t.Helper()
+	if err := f.client.Topic("anerbot").Delete(context.Background()); err != nil {
+		t.Fatalf("unable to delete fake topic: %v", err)
+	}
+}
+
+// Function to return every message published to the fake topic so far.
+func (f *fakeTopic) messages(t *testing.T) []queueMessage {
+	t.Helper()
+	var messages []queueMessage
+	for _, m := range f.srv.Messages() {
+		var message queueMessage
+		if err := json.Unmarshal(m.Data, &message); err != nil {
+			t.Fatalf("unable to parse published message: %v", err)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// Struct for a fake Slack endpoint, keeping the body of every message
+// posted to it.
+type fakeSlack struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+}
+
+// Function to start a fake Slack endpoint. Call Close once the test is
+// finished.
+func newFakeSlack() *fakeSlack {
+	s := &fakeSlack{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(b))
+		s.mu.Unlock()
+	}))
+	return s
+}
+
+// Function to return the messages posted to the fake Slack endpoint.
+func (s *fakeSlack) posted() []queueResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var posted []queueResponse
+	for _, b := range s.bodies {
+		var r queueResponse
+		json.Unmarshal([]byte(b), &r)
+		posted = append(posted, r)
+	}
+	return posted
+}
+
+// Signing secret used to sign the requests sent to the function in tests.
+const testSigSecret = "test-signing-secret"
+
+// Function to build a request signed with the test signing secret as
+// Slack would sign it, such as a slash command. Set slackSigSecret to
+// testSigSecret before sending it.
+func signedRequest(form url.Values) *http.Request {
+	body := form.Encode()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := getSignature([]byte(fmt.Sprintf("%s:%s:%s", version, ts, body)), []byte(testSigSecret))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set(slackRequestTimestampHeader, ts)
+	r.Header.Set(slackSignatureHeader, fmt.Sprintf("%s=%x", version, sig))
+	return r
+}
+
+// Function to build the form Slack sends for a slash command.
+func slashCommand(text, responseURL, channelID, userID string) url.Values {
+	return url.Values{
+		"text":         {text},
+		"response_url": {responseURL},
+		"channel_id":   {channelID},
+		"user_id":      {userID},
+	}
+}
+
+// Function to marshal a value into JSON, failing the test if it can't be.
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// Function to build the payload Slack sends when a user takes an action
+// in one of Anerbot's messages.
+func testInteraction(t *testing.T, responseURL, channelID string, a interactionAction) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]interface{}{
+		"type":         blockActionsType,
+		"response_url": responseURL,
+		"user":         map[string]string{"id": "U123"},
+		"channel":      map[string]string{"id": channelID},
+		"actions":      []interactionAction{a},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestApplyBatchSettings(t *testing.T) {
+	defer func(d time.Duration, c int) { batchDelay, batchCount = d, c }(batchDelay, batchCount)
+
+	tests := []struct {
+		name      string
+		delay     string
+		count     string
+		wantDelay time.Duration
+		wantCount int
+	}{
+		{"defaults are kept when unset", "", "", pubsub.DefaultPublishSettings.DelayThreshold, pubsub.DefaultPublishSettings.CountThreshold},
+		{"configured delay and count", "250ms", "50", 250 * time.Millisecond, 50},
+		{"unparsable values keep the defaults", "soon", "many", pubsub.DefaultPublishSettings.DelayThreshold, pubsub.DefaultPublishSettings.CountThreshold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{"PUBSUB_BATCH_DELAY": tt.delay, "PUBSUB_BATCH_COUNT": tt.count})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			pt := ft.client.Topic("anerbot")
+			defer pt.Stop()
+			applyBatchSettings(pt)
+			if pt.PublishSettings.DelayThreshold != tt.wantDelay || pt.PublishSettings.CountThreshold != tt.wantCount {
+				t.Errorf("publish settings delay %v count %d, want %v %d", pt.PublishSettings.DelayThreshold, pt.PublishSettings.CountThreshold, tt.wantDelay, tt.wantCount)
+			}
+		})
+	}
+}
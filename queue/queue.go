@@ -4,17 +4,22 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"cloud.google.com/go/pubsub"
 )
@@ -26,39 +31,228 @@ const (
 	slackSignatureHeader        = "X-Slack-Signature"
 )
 
-// Variables used for the GCP Pub/Sub connection.
+// Version of Anerbot running, set at build time with
+// `-ldflags "-X github.com/smfsh/anerbot/queue.Version=v2.1.0"`.
+var Version = "dev"
+
+// Variables used for the GCP Pub/Sub connection. Batch settings
+// control how long, and for how many messages, the client waits to
+// publish messages together.
+var (
+	projectID  string
+	topicName  string
+	batchDelay time.Duration
+	batchCount int
+)
+
+// Topic used to publish messages, shared across requests, along with
+// the client it was created with.
+var topic struct {
+	mu     sync.Mutex
+	client *pubsub.Client
+	t      *pubsub.Topic
+}
+
+// Variables used for Slack validation. The verification token is Slack's
+// legacy token, only checked when it has been configured.
+var (
+	slackSigSecret    string
+	slackChannelIDs   []string
+	verificationToken string
+)
+
+// Variables used for the messages sent back to Slack. The channel
+// message template replaces "{channels}" with links to each of the
+// channels Anerbot is allowed to run in, unless the allowed channels are
+// hidden from users outside of them. The acknowledgement emoji prefixes
+// the message sent while a search is running.
 var (
-	projectID string
-	topicName string
+	channelMessage string
+	hideChannels   bool
+	failureMessage string
+	ackEmoji       string
 )
 
-// Variables used for Slack validation.
+// Variables used for maintenance mode. While in maintenance mode no
+// searches are published and the maintenance message is sent instead.
 var (
-	slackSigSecret string
-	slackChannelID string
+	maintenanceMode    bool
+	maintenanceMessage string
 )
 
+// Variables used for logging. When queries are redacted, only a hash
+// of each query is logged.
+var (
+	redactQueries bool
+)
+
+// Variables used for reports of incorrect data. The feedback webhook is
+// a Slack incoming webhook posting to the channel that reviews reports.
+var (
+	feedbackWebhookURL string
+)
+
+// Variables used for parsing queries. When the search prefix is stripped,
+// a leading "search" is removed from each query.
+var (
+	stripSearchPrefix bool
+)
+
+// Word removed from the start of queries for backwards compatibility with
+// Anerbot 1.0.
+const searchPrefix = "search"
+
+// Variables used for each user's search history. The history size is
+// the number of recent searches remembered for each user.
+var (
+	historySize int
+)
+
+// Variables used for replying to pings. When the ping status is enabled,
+// pings are answered with the status of the instance.
+var (
+	pingStatus bool
+	startedAt  = time.Now()
+)
+
+// Variables used for generating permalinks to searches. The permalink
+// URL is the URL of the anerbot-search function, and the permalink secret
+// is shared with it to sign each permalink.
+var (
+	permalinkURL    string
+	permalinkSecret string
+)
+
+// Variables used for admin commands. Admin users are the Slack user IDs
+// allowed to run them.
+var (
+	adminUsers []string
+)
+
+// Keyword that makes Anerbot reply with a permalink to a search.
+const linkKeyword = "link"
+
+// Default message sent when a search is requested in maintenance mode.
+const defaultMaintenanceMessage = "Anerbot is down for maintenance right now, try again a little later! :construction:"
+
+// Default message sent when a search could not be sent to the queue.
+const defaultFailureMessage = "Anerbot couldn't start your search, try again! :cry:"
+
+// Default message sent when Anerbot is used outside of an allowed channel.
+const defaultChannelMessage = "Anerbot needs to run in {channels}, try again there! :broken_heart:"
+
+// Timeouts for the calls made while answering a request from Slack,
+// which must be answered within three seconds. A request makes at most
+// two of these calls in turn, such as publishing a message and then
+// telling the user it couldn't be published, so each is kept to a
+// second.
+const (
+	publishTimeout = time.Second
+	slackTimeout   = time.Second
+)
+
+// Client used for every message posted to Slack, shared across requests.
+var slackClient = &http.Client{Timeout: slackTimeout}
+
 // Struct for the message to be sent to the GCP Pub/Sub engine.
 type queueMessage struct {
 	Query       string `json:"query"`
 	ResponseUrl string `json:"response_url"`
+	ChannelID   string `json:"channel_id"`
+	Action      string `json:"action,omitempty"`
+	Value       string `json:"value,omitempty"`
+	RequestID   string `json:"request_id"`
+	Shared      bool   `json:"shared,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+	Page        int    `json:"page,omitempty"`
+}
+
+// Struct for the status of the instance sent in reply to a ping.
+type pingResponse struct {
+	Status        string `json:"status"`
+	Uptime        string `json:"uptime"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Maintenance   bool   `json:"maintenance"`
+	Version       string `json:"version"`
 }
 
 // Struct for the message to be sent back to Slack after the
 // initial contact.
 type queueResponse struct {
-	ResponseType string `json:"response_type"`
-	Text         string `json:"text"`
+	ResponseType string         `json:"response_type"`
+	Text         string         `json:"text"`
+	Blocks       []messageBlock `json:"blocks,omitempty"`
 }
 
 // init() runs at the beginning of our GCF and sets the variables needed
 // for the queue process from the env variables set in the GCF.
 func init() {
+	log.Printf("anerbot-queue %s starting", Version)
+
+	if err := loadConfig(); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// Function to read the configuration of the queue process from the env
+// variables set in the GCF. It runs at startup and again whenever an
+// admin asks for the configuration to be reloaded, so every setting is
+// reset before it is read rather than added to what was read last time.
+func loadConfig() error {
 	projectID = os.Getenv("GCP_PROJECT_ID")
 	topicName = os.Getenv("GCP_TOPIC_NAME")
+	batchDelay = parseDuration(os.Getenv("PUBSUB_BATCH_DELAY"), 0)
+	batchCount = parseInt(os.Getenv("PUBSUB_BATCH_COUNT"), 0)
+
+	var err error
+	if slackSigSecret, err = getSecret("SLACK_SIG_SECRET"); err != nil {
+		return err
+	}
+	if verificationToken, err = getSecret("SLACK_VERIFICATION_TOKEN"); err != nil {
+		return err
+	}
+	slackChannelIDs = nil
+	for _, v := range parseList(os.Getenv("SLACK_CHANNEL_ID")) {
+		slackChannelIDs = append(slackChannelIDs, normalizeID(v))
+	}
+
+	permalinkURL = os.Getenv("PERMALINK_URL")
+	if permalinkSecret, err = getSecret("PERMALINK_SECRET"); err != nil {
+		return err
+	}
+	if permalinkURL != "" && permalinkSecret == "" {
+		log.Printf("warning: PERMALINK_URL is set but PERMALINK_SECRET isn't, so anerbot-search will reject every permalink")
+	}
+	if feedbackWebhookURL, err = getSecret("SLACK_FEEDBACK_WEBHOOK_URL"); err != nil {
+		return err
+	}
+	pingStatus = parseBool(os.Getenv("PING_STATUS"))
+	historySize = parseInt(os.Getenv("QUERY_HISTORY_SIZE"), 5)
+	stripSearchPrefix = true
+	if v, ok := os.LookupEnv("QUERY_STRIP_SEARCH_PREFIX"); ok {
+		stripSearchPrefix = parseBool(v)
+	}
+	redactQueries = parseBool(os.Getenv("LOG_REDACT_QUERIES"))
+	adminUsers = parseList(os.Getenv("SLACK_ADMIN_USERS"))
+
+	channelMessage = os.Getenv("SLACK_CHANNEL_MESSAGE")
+	if channelMessage == "" {
+		channelMessage = defaultChannelMessage
+	}
+	hideChannels = parseBool(os.Getenv("SLACK_HIDE_ALLOWED_CHANNELS"))
+	failureMessage = os.Getenv("SLACK_FAILURE_MESSAGE")
+	if failureMessage == "" {
+		failureMessage = defaultFailureMessage
+	}
+	ackEmoji = strings.TrimSpace(os.Getenv("SLACK_ACK_EMOJI"))
 
-	slackSigSecret = os.Getenv("SLACK_SIG_SECRET")
-	slackChannelID = os.Getenv("SLACK_CHANNEL_ID")
+	maintenanceMode = parseBool(os.Getenv("MAINTENANCE_MODE"))
+	maintenanceMessage = os.Getenv("MAINTENANCE_MESSAGE")
+	if maintenanceMessage == "" {
+		maintenanceMessage = defaultMaintenanceMessage
+	}
+
+	return nil
 }
 
 // main() does not run in GCF. It is left here strictly for testing
@@ -79,8 +273,16 @@ func main() {
 func Queue(w http.ResponseWriter, r *http.Request) {
 	// Immediately reply if query string "ping" is not empty.
 	// This can be used by an external caller to keep the
-	// GCF warm for responses.
+	// GCF warm for responses, and optionally replies with the
+	// status of the instance for monitors.
 	if r.URL.Query().Get("ping") != "" {
+		if pingStatus {
+			w.Header().Set("Content-Type", "application/json")
+			err := json.NewEncoder(w).Encode(currentStatus())
+			if err != nil {
+				log.Printf("json.Marshal: %v", err)
+			}
+		}
 		return
 	}
 
@@ -110,6 +312,23 @@ func Queue(w http.ResponseWriter, r *http.Request) {
 	// the io.ReadCloser.
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 
+	// Acknowledge the request when the signing secret hasn't been
+	// configured yet, such as while the Slack app is still being set up,
+	// rather than failing to validate every request.
+	if slackSigSecret == "" {
+		log.Printf("SLACK_SIG_SECRET is not set, unable to validate requests")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(queueResponse{
+			ResponseType: "ephemeral",
+			Text:         "Anerbot isn't ready yet: an admin needs to configure the Slack signing secret. :construction:",
+		})
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		return
+	}
+
 	// Validate that our request is legitimate and actually came
 	// from Snyk's Slack.
 	ok, err := verifyWebHook(r, slackSigSecret)
@@ -120,6 +339,21 @@ func Queue(w http.ResponseWriter, r *http.Request) {
 		log.Fatalf("unable to validate request: signatures did not match")
 	}
 
+	// Interactive components, such as the buttons on results, send
+	// their details as JSON in the payload field instead of a command.
+	if payload := r.Form.Get("payload"); payload != "" {
+		handleInteraction(w, payload)
+		return
+	}
+
+	// Check the legacy verification token as well as the signature when
+	// it has been configured.
+	if !verifyToken(r.Form.Get("token")) {
+		log.Printf("unable to validate request: verification token did not match")
+		http.Error(w, "Invalid verification token", 401)
+		return
+	}
+
 	// Validate that the entire form is actually present.
 	if len(r.Form["text"]) == 0 {
 		log.Fatalf("empty text in form")
@@ -136,9 +370,46 @@ func Queue(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	// Validate that the request came from the restricted Slack channel ID.
-	if r.Form["channel_id"][0] != slackChannelID {
-		res.Text = fmt.Sprintf("Anerbot needs to run in <#%s>, try again there! :broken_heart:", slackChannelID)
+	// Reload the configuration when an admin asks for it. This is handled
+	// before anything else so that an admin can still reload while in
+	// maintenance mode or outside of the allowed channels.
+	if strings.ToLower(strings.TrimSpace(r.Form["text"][0])) == reloadKeyword && adminAllowed(r.Form.Get("user_id")) {
+		res.Text = reloadConfig(r.Form["response_url"][0])
+		// Marshal our response struct into JSON and send it back to Slack.
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		return
+	}
+
+	// Let the user know Anerbot is paused, such as during an Airtable
+	// migration, without publishing anything to the queue.
+	if maintenanceMode {
+		res.Text = maintenanceMessage
+		// Marshal our response struct into JSON and send it back to Slack.
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		return
+	}
+
+	// Report the features missing documentation when an admin asks for
+	// it, from any channel since the report is only shown to them.
+	if strings.ToLower(strings.TrimSpace(r.Form["text"][0])) == needsDocsKeyword && adminAllowed(r.Form.Get("user_id")) {
+		res.Text = queueNeedsDocs(r.Form["response_url"][0], r.Form.Get("channel_id"), r.Form.Get("user_id"))
+		// Marshal our response struct into JSON and send it back to Slack.
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		return
+	}
+
+	// Validate that the request came from one of the restricted Slack channel IDs.
+	if !channelAllowed(r.Form.Get("channel_id"), r.Form.Get("team_id"), r.Form.Get("enterprise_id")) {
+		res.Text = channelDeniedMessage()
 		// Marshal our response struct into JSON and send it back to Slack.
 		err = json.NewEncoder(w).Encode(res)
 		if err != nil {
@@ -160,29 +431,73 @@ func Queue(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	if strings.HasPrefix(queryText, "search") {
-		queryText = strings.TrimPrefix(queryText, "search ")
+	queryText = trimSearchPrefix(queryText)
+
+	// Reply with a permalink that re-runs the search when the query
+	// starts with the "link" keyword, rather than running it now.
+	if fields := strings.Fields(queryText); permalinkURL != "" && len(fields) > 1 && strings.ToLower(fields[0]) == linkKeyword {
+		linkQuery := strings.Join(fields[1:], " ")
+		res.Text = fmt.Sprintf(`Permalink for "%s": %s`, linkQuery, permalink(linkQuery))
+		// Marshal our response struct into JSON and send it back to Slack.
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		return
 	}
 
-	// Prepare the message to the queue made up of two
-	// components: the query from the user, and the URL that
-	// Slack will be listening on for additional messages.
+	// Reply with the user's recent searches when the query is only the
+	// "history" keyword.
+	if strings.ToLower(strings.TrimSpace(queryText)) == historyKeyword && historySize > 0 {
+		res = renderHistory(recentHistory(r.Form.Get("user_id")))
+		// Marshal our response struct into JSON and send it back to Slack.
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		return
+	}
+
+	// Prepare the message to the queue made up of the query
+	// from the user, the URL that Slack will be listening on
+	// for additional messages, the channel the search was
+	// requested in, the user who requested it, and an ID
+	// correlating the logs of both functions for this search.
 	message := queueMessage{
 		Query:       queryText,
 		ResponseUrl: r.Form["response_url"][0],
+		ChannelID:   r.Form.Get("channel_id"),
+		RequestID:   newRequestID(),
+		UserID:      r.Form.Get("user_id"),
 	}
+	log.Printf("request %s: queueing search for %s", message.RequestID, logQuery(queryText))
 
 	// Send the message (publish) to the GCP Pub/Sub engine.
 	// As soon as a message is received, the GCF anerbot-response
-	// function is kicked off and operates on the message.
+	// function is kicked off and operates on the message. If the
+	// message couldn't be published, let the user know so they can
+	// try again rather than waiting on results that will never arrive.
 	err = publishMessage(message)
 	if err != nil {
-		log.Fatalf("unable to publish message: %v", err)
+		log.Printf("request %s: unable to publish message: %v", message.RequestID, err)
+		res.Text = failureMessage
+		// Marshal our response struct into JSON and send it back to Slack.
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			log.Fatalf("json.Marshal: %v", err)
+		}
+		return
 	}
 
+	// Remember the search so the user can run it again later.
+	recordHistory(r.Form.Get("user_id"), queryText)
+
 	// Prepare the message to be immediately sent back to Slack
 	// in an attempt to beat their three second timeout.
 	res.Text = fmt.Sprintf(`Hang tight - gathering results for "%s".`, queryText)
+	if ackEmoji != "" {
+		res.Text = fmt.Sprintf("%s %s", ackEmoji, res.Text)
+	}
 
 	// Marshal our response struct into JSON and send it back to Slack.
 	err = json.NewEncoder(w).Encode(res)
@@ -191,6 +506,151 @@ func Queue(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Function to remove the word "search" from the start of a query, in any
+// casing, to maintain backwards compatibility with Anerbot 1.0. The word
+// is only removed when it stands on its own and something follows it, so
+// "searchable" and a query of just "search" are searched as they are.
+// Nothing is removed when stripping the prefix is turned off.
+func trimSearchPrefix(query string) string {
+	if !stripSearchPrefix {
+		return query
+	}
+	trimmed := strings.TrimLeftFunc(query, unicode.IsSpace)
+	words := strings.Fields(trimmed)
+	if len(words) < 2 || !strings.EqualFold(words[0], searchPrefix) {
+		return query
+	}
+	return strings.TrimLeftFunc(trimmed[len(words[0]):], unicode.IsSpace)
+}
+
+// Function to gather the status of the instance, such as how long it has
+// been running, to reply to a ping with.
+func currentStatus() pingResponse {
+	uptime := time.Since(startedAt)
+	return pingResponse{
+		Status:        "ok",
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime / time.Second),
+		Maintenance:   maintenanceMode,
+		Version:       Version,
+	}
+}
+
+// Function to check whether a channel ID is one of the channels Anerbot
+// is allowed to run in. In Enterprise Grid, channel IDs can collide across
+// workspaces, so allowed channels can be prefixed with the team or
+// enterprise ID they belong to, such as "T0001/C2147483705", in which case
+// the request must also come from that team or enterprise.
+func channelAllowed(channelID, teamID, enterpriseID string) bool {
+	channelID = normalizeID(channelID)
+	teamID = normalizeID(teamID)
+	enterpriseID = normalizeID(enterpriseID)
+	for _, v := range slackChannelIDs {
+		owner, channel := splitChannelEntry(v)
+		if channel != channelID {
+			continue
+		}
+		if owner == "" || owner == teamID || (enterpriseID != "" && owner == enterpriseID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to return why a search can't be started from a channel, the
+// same checks made of slash commands: nothing is searched in maintenance
+// mode or outside of the allowed channels. An empty string is returned
+// when the search can go ahead.
+func searchBlocked(channelID, teamID, enterpriseID string) string {
+	if maintenanceMode {
+		return maintenanceMessage
+	}
+	if !channelAllowed(channelID, teamID, enterpriseID) {
+		return channelDeniedMessage()
+	}
+	return ""
+}
+
+// Function to render the message sent when Anerbot is used outside of an
+// allowed channel, naming the allowed channels unless they are hidden.
+func channelDeniedMessage() string {
+	allowed := slackChannelIDs
+	if hideChannels {
+		allowed = nil
+	}
+	return renderChannelMessage(channelMessage, allowed)
+}
+
+// Function to split an allowed channel into the team or enterprise ID it
+// is prefixed with, if any, and the channel ID itself.
+func splitChannelEntry(entry string) (owner string, channelID string) {
+	if i := strings.Index(entry, "/"); i >= 0 {
+		return entry[:i], entry[i+1:]
+	}
+	return "", entry
+}
+
+// Function to normalize a Slack ID for comparison. Slack IDs are always
+// uppercase, but configuration may not be.
+func normalizeID(id string) string {
+	return strings.ToUpper(strings.TrimSpace(id))
+}
+
+// Function to render the message sent when Anerbot is used outside of an
+// allowed channel, replacing "{channels}" in the template with a Slack
+// link to each of the allowed channels. Without any channels, such as when
+// they are hidden, no channel is named.
+func renderChannelMessage(template string, channelIDs []string) string {
+	var links []string
+	for _, v := range channelIDs {
+		_, channelID := splitChannelEntry(v)
+		links = append(links, fmt.Sprintf("<#%s>", channelID))
+	}
+	if len(links) == 0 {
+		return strings.Replace(template, "{channels}", "an allowed channel", -1)
+	}
+	return strings.Replace(template, "{channels}", strings.Join(links, " or "), -1)
+}
+
+// Function to generate a permalink that re-runs a search for the query
+// passed in when followed. The permalink is signed with the permalink
+// secret so anerbot-search only runs searches Anerbot has linked to.
+func permalink(query string) string {
+	values := url.Values{"q": {query}}
+	if permalinkSecret != "" {
+		values.Set("sig", permalinkSignature(query, permalinkSecret))
+	}
+	return fmt.Sprintf("%s?%s", permalinkURL, values.Encode())
+}
+
+// Function to sign the query of a permalink with the permalink secret.
+func permalinkSignature(query, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(query))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Function to generate a random ID used to correlate the logs of both
+// functions for a single search.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Function to render a query for logging. When redaction is enabled only
+// a short hash of the query is logged, so identical queries can still be
+// grouped without revealing what was searched.
+func logQuery(query string) string {
+	if !redactQueries {
+		return fmt.Sprintf("%q", query)
+	}
+	sum := sha256.Sum256([]byte(query))
+	return fmt.Sprintf("[redacted %s]", hex.EncodeToString(sum[:])[:12])
+}
+
 // Function to send our message to the GCP Pub/Sub Engine.
 func publishMessage(message queueMessage) error {
 	// Marshal our message struct into JSON.
@@ -199,6 +659,40 @@ func publishMessage(message queueMessage) error {
 		return fmt.Errorf("unable to convert message to json: %v", err)
 	}
 
+	// Publish the message to the shared topic.
+	ctx := context.Background()
+	t, err := pubsubTopic(ctx)
+	if err != nil {
+		return err
+	}
+	result := t.Publish(ctx, &pubsub.Message{
+		Data: m,
+	})
+
+	// Ensure the publishing was successful. Throw away the result. Stop
+	// waiting once the publish timeout has passed so Slack is still
+	// answered in time.
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	_, err = result.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get published result: %v", err)
+	}
+
+	return nil
+}
+
+// Function to return the Pub/Sub topic messages are published to. The
+// topic is created once and shared by every request the instance serves
+// so that messages published close together can be batched.
+func pubsubTopic(ctx context.Context) (*pubsub.Topic, error) {
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+
+	if topic.t != nil {
+		return topic.t, nil
+	}
+
 	// Create a new Pub/Sub client that will allow further operations.
 	// The client automatically pulls authentication credentials
 	// from the Service Account running to anerbot-queue Cloud
@@ -206,26 +700,40 @@ func publishMessage(message queueMessage) error {
 	// testing purposes, the `GOOGLE_APPLICATION_CREDENTIALS` env
 	// variable must be set and pointing to a GCP JSON credential
 	// file for the anerbot Service Account.
-	ctx := context.Background()
 	client, err := pubsub.NewClient(ctx, projectID)
 	if err != nil {
-		return fmt.Errorf("unable to create pubsub client: %v", err)
+		return nil, fmt.Errorf("unable to create pubsub client: %v", err)
 	}
 
 	// Set the Topic to be used, usually "anerbot" but configurable
-	// in the GCF environment variables, and publish the message.
+	// in the GCF environment variables.
 	t := client.Topic(topicName)
-	result := t.Publish(ctx, &pubsub.Message{
-		Data: m,
-	})
+	applyBatchSettings(t)
+	topic.client = client
+	topic.t = t
 
-	// Ensure the publishing was successful. Throw away the result.
-	_, err = result.Get(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to get published result: %v", err)
+	return t, nil
+}
+
+// Function to apply the configured batch settings to a topic. Settings
+// that haven't been configured keep the Pub/Sub client's defaults.
+func applyBatchSettings(t *pubsub.Topic) {
+	if batchDelay > 0 {
+		t.PublishSettings.DelayThreshold = batchDelay
+	}
+	if batchCount > 0 {
+		t.PublishSettings.CountThreshold = batchCount
 	}
+}
 
-	return nil
+// Function to check the legacy verification token sent by Slack against
+// the configured token. Every token is accepted when no token has been
+// configured, leaving the signature as the only check.
+func verifyToken(token string) bool {
+	if verificationToken == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(verificationToken)) == 1
 }
 
 // Function to validate that the request we received was actually from Slack.
@@ -295,3 +803,63 @@ func getSignature(base []byte, secret []byte) []byte {
 
 	return h.Sum(nil)
 }
+
+// Function to read a secret, such as a signing secret or API key. When
+// the NAME_FILE env variable names a file, such as a secret from Secret
+// Manager mounted as a volume, the secret is read from it rather than
+// from the env variable itself. The file is read every time the
+// configuration is loaded, so a secret rotated in the file is picked up
+// on reload, which the env variables of a running function never are.
+func getSecret(name string) (string, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name), nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s_FILE: %v", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Function to split a comma-separated env variable into a slice of
+// trimmed values. Empty values are dropped.
+func parseList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Function to parse an integer env variable, returning the default value
+// passed in when the variable is unset or unparsable.
+func parseInt(s string, def int) int {
+	i, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return def
+	}
+	return i
+}
+
+// Function to parse a duration env variable such as "50ms", returning the
+// default value passed in when the variable is unset or unparsable.
+func parseDuration(s string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// Function to parse a boolean env variable. Unset or unparsable values
+// are treated as false.
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	return b
+}
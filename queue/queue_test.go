@@ -0,0 +1,501 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to send a request to the function, returning the ephemeral
+// response Slack would show to the user.
+func queueCommand(t *testing.T, r *http.Request) queueResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Queue(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var res queueResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unable to parse response %q: %v", w.Body.String(), err)
+	}
+	return res
+}
+
+// Function to set env variables and load the configuration from them,
+// returning a function to put the variables back as they were and load
+// the configuration again.
+func useEnv(env map[string]string) func() {
+	type saved struct {
+		value string
+		ok    bool
+	}
+	old := make(map[string]saved)
+	for k, v := range env {
+		value, ok := os.LookupEnv(k)
+		old[k] = saved{value, ok}
+		os.Setenv(k, v)
+	}
+	loadConfig()
+	return func() {
+		for k, v := range old {
+			if v.ok {
+				os.Setenv(k, v.value)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+		loadConfig()
+	}
+}
+
+func TestQueueWithoutSigningSecret(t *testing.T) {
+	defer func(s string, c []string) { slackSigSecret, slackChannelIDs = s, c }(slackSigSecret, slackChannelIDs)
+	slackChannelIDs = []string{"C0123456789"}
+
+	tests := []struct {
+		name       string
+		secret     string
+		wantQueued int
+		wantText   string
+	}{
+		{"unset secret asks for setup", "", 0, "an admin needs to configure the Slack signing secret"},
+		{"configured secret searches", testSigSecret, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slackSigSecret = tt.secret
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			res := queueCommand(t, signedRequest(slashCommand("sso", "https://hooks.slack.com/x", "C0123456789", "U123")))
+			if tt.wantText != "" && !strings.Contains(res.Text, tt.wantText) {
+				t.Errorf("response = %q, want it to contain %q", res.Text, tt.wantText)
+			}
+			if got := len(ft.messages(t)); got != tt.wantQueued {
+				t.Errorf("queued %d messages, want %d", got, tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestChannelMessageTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		channels string
+		want     string
+	}{
+		{"default template", "", "C0123456789", "Anerbot needs to run in <#C0123456789>, try again there! :broken_heart:"},
+		{"custom template", "Please use {channels} for Anerbot.", "c0123456789,T0123/C0987654321", "Please use <#C0123456789> or <#C0987654321> for Anerbot."},
+		{"template without placeholder", "Not here!", "C0123456789", "Not here!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET":      testSigSecret,
+				"SLACK_CHANNEL_ID":      tt.channels,
+				"SLACK_CHANNEL_MESSAGE": tt.template,
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			res := queueCommand(t, signedRequest(slashCommand("sso", "https://hooks.slack.com/x", "C0000000000", "U123")))
+			if res.Text != tt.want {
+				t.Errorf("response = %q, want %q", res.Text, tt.want)
+			}
+			if got := len(ft.messages(t)); got != 0 {
+				t.Errorf("queued %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestChannelAllowedIgnoresCase(t *testing.T) {
+	tests := []struct {
+		name      string
+		channels  string
+		channelID string
+		want      bool
+	}{
+		{"lowercase config matches uppercase ID", "c0123456789", "C0123456789", true},
+		{"padded config matches", " C0123456789 ,C0987654321", "C0123456789", true},
+		{"lowercase incoming ID matches", "C0123456789", "c0123456789", true},
+		{"other channel is denied", "c0123456789", "C0987654321", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{"SLACK_CHANNEL_ID": tt.channels})()
+			if got := channelAllowed(tt.channelID, "", ""); got != tt.want {
+				t.Errorf("channelAllowed(%q) with %q = %t, want %t", tt.channelID, tt.channels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryLogRedaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		redact  string
+		wantRaw bool
+	}{
+		{"queries logged by default", "", true},
+		{"queries hashed when redacted", "true", false},
+	}
+	const query = "secret project codename"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET":   testSigSecret,
+				"SLACK_CHANNEL_ID":   "C0123456789",
+				"LOG_REDACT_QUERIES": tt.redact,
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			queueCommand(t, signedRequest(slashCommand(query, "https://hooks.slack.com/x", "C0123456789", "U123")))
+			log.SetOutput(os.Stderr)
+
+			logs := buf.String()
+			if got := strings.Contains(logs, query); got != tt.wantRaw {
+				t.Errorf("logs contain the query = %t, want %t:\n%s", got, tt.wantRaw, logs)
+			}
+			redactQueries = true
+			if got := strings.Contains(logs, logQuery(query)); got == tt.wantRaw {
+				t.Errorf("logs contain the hash of the query = %t, want %t:\n%s", got, !tt.wantRaw, logs)
+			}
+			if messages := ft.messages(t); len(messages) != 1 || messages[0].Query != query {
+				t.Errorf("queued %+v, want the query itself passed on", messages)
+			}
+		})
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		maintenance string
+		message     string
+		wantQueued  int
+		wantText    string
+	}{
+		{"searches run normally", "", "", 1, ""},
+		{"default message", "true", "", 0, defaultMaintenanceMessage},
+		{"configured message", "true", "Back after the migration!", 0, "Back after the migration!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET":    testSigSecret,
+				"SLACK_CHANNEL_ID":    "C0123456789",
+				"MAINTENANCE_MODE":    tt.maintenance,
+				"MAINTENANCE_MESSAGE": tt.message,
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			res := queueCommand(t, signedRequest(slashCommand("sso", "https://hooks.slack.com/x", "C0123456789", "U123")))
+			if tt.wantText != "" && res.Text != tt.wantText {
+				t.Errorf("response = %q, want %q", res.Text, tt.wantText)
+			}
+			if got := len(ft.messages(t)); got != tt.wantQueued {
+				t.Errorf("queued %d messages, want %d", got, tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestPublishFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"default message", "", defaultFailureMessage},
+		{"configured message", "Anerbot is having a moment, try again soon.", "Anerbot is having a moment, try again soon."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET":      testSigSecret,
+				"SLACK_CHANNEL_ID":      "C0123456789",
+				"SLACK_FAILURE_MESSAGE": tt.message,
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+			ft.fail(t)
+
+			res := queueCommand(t, signedRequest(slashCommand("sso", "https://hooks.slack.com/x", "C0123456789", "U123")))
+			if res.Text != tt.want || res.ResponseType != "ephemeral" {
+				t.Errorf("response = %+v, want the ephemeral failure message %q", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimSearchPrefix(t *testing.T) {
+	defer func(s bool) { stripSearchPrefix = s }(stripSearchPrefix)
+
+	tests := []struct {
+		name  string
+		strip bool
+		query string
+		want  string
+	}{
+		{"lowercase", true, "search sso", "sso"},
+		{"title case", true, "Search sso", "sso"},
+		{"uppercase", true, "SEARCH sso", "sso"},
+		{"mixed case with extra space", true, "  sEaRcH   single sign on", "single sign on"},
+		{"part of a word", true, "searchable fields", "searchable fields"},
+		{"prefix on its own", true, "Search", "Search"},
+		{"prefix on its own with spaces", true, "  search  ", "  search  "},
+		{"prefix before a single word", true, "search api", "api"},
+		{"prefix before a search for searching", true, "search for searchable", "for searchable"},
+		{"searching for the word search", true, "search search", "search"},
+		{"prefix followed by a tab", true, "search\tapi", "api"},
+		{"stripping turned off", false, "Search sso", "Search sso"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripSearchPrefix = tt.strip
+			if got := trimSearchPrefix(tt.query); got != tt.want {
+				t.Errorf("trimSearchPrefix(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnterpriseGridChannels(t *testing.T) {
+	defer useEnv(map[string]string{
+		"SLACK_SIG_SECRET": testSigSecret,
+		"SLACK_CHANNEL_ID": "C0123456789,t0001/C0222222222,E0001/C0333333333",
+	})()
+
+	tests := []struct {
+		name         string
+		channelID    string
+		teamID       string
+		enterpriseID string
+		want         bool
+	}{
+		{"unprefixed channel from any team", "C0123456789", "T0999", "", true},
+		{"matching team", "C0222222222", "T0001", "", true},
+		{"mismatching team", "C0222222222", "T0999", "", false},
+		{"missing team", "C0222222222", "", "", false},
+		{"matching enterprise", "C0333333333", "T0999", "E0001", true},
+		{"mismatching enterprise", "C0333333333", "T0999", "E0999", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := channelAllowed(tt.channelID, tt.teamID, tt.enterpriseID); got != tt.want {
+				t.Errorf("channelAllowed(%q, %q, %q) = %t, want %t", tt.channelID, tt.teamID, tt.enterpriseID, got, tt.want)
+			}
+
+			ft := useFakeTopic(t)
+			defer ft.close()
+			form := slashCommand("sso", "https://hooks.slack.com/x", tt.channelID, "U123")
+			form.Set("team_id", tt.teamID)
+			form.Set("enterprise_id", tt.enterpriseID)
+			res := queueCommand(t, signedRequest(form))
+			if got := len(ft.messages(t)) == 1; got != tt.want {
+				t.Errorf("queued = %t, want %t (response %q)", got, tt.want, res.Text)
+			}
+		})
+	}
+}
+
+func TestPingStatus(t *testing.T) {
+	defer func(s time.Time, v string) { startedAt, Version = s, v }(startedAt, Version)
+	startedAt, Version = time.Now().Add(-90*time.Second), "v2.1.0"
+
+	tests := []struct {
+		name        string
+		status      string
+		maintenance string
+		want        *pingResponse
+	}{
+		{"empty reply by default", "", "", nil},
+		{"status", "true", "", &pingResponse{Status: "ok", Uptime: "1m30s", UptimeSeconds: 90, Version: "v2.1.0"}},
+		{"status in maintenance", "true", "true", &pingResponse{Status: "ok", Uptime: "1m30s", UptimeSeconds: 90, Maintenance: true, Version: "v2.1.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{"PING_STATUS": tt.status, "MAINTENANCE_MODE": tt.maintenance})()
+			w := httptest.NewRecorder()
+			Queue(w, httptest.NewRequest("POST", "/?ping=1", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if tt.want == nil {
+				if w.Body.Len() != 0 {
+					t.Errorf("body = %q, want it empty", w.Body.String())
+				}
+				return
+			}
+			var got pingResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q isn't JSON: %v", w.Body.String(), err)
+			}
+			if got != *tt.want || w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("ping = %+v (%s), want %+v as JSON", got, w.Header().Get("Content-Type"), *tt.want)
+			}
+		})
+	}
+}
+
+func TestAckEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		emoji string
+		text  string
+		want  string
+	}{
+		{"no emoji", "", "sso", `Hang tight - gathering results for "sso".`},
+		{"emoji", ":mag:", "sso", `:mag: Hang tight - gathering results for "sso".`},
+		{"padded emoji", " :mag: ", "sso", `:mag: Hang tight - gathering results for "sso".`},
+		{"admin report", ":mag:", "needs-docs", ":mag: Hang tight - looking for features missing documentation."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET":  testSigSecret,
+				"SLACK_CHANNEL_ID":  "C0123456789",
+				"SLACK_ADMIN_USERS": "UADMIN",
+				"SLACK_ACK_EMOJI":   tt.emoji,
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			res := queueCommand(t, signedRequest(slashCommand(tt.text, "https://hooks.slack.com/x", "C0123456789", "UADMIN")))
+			if res.Text != tt.want {
+				t.Errorf("response = %q, want %q", res.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerificationToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		want       int
+	}{
+		{"not configured", "", "anything", http.StatusOK},
+		{"not configured or sent", "", "", http.StatusOK},
+		{"matching token", "tok123", "tok123", http.StatusOK},
+		{"mismatching token", "tok123", "tok456", http.StatusUnauthorized},
+		{"missing token", "tok123", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET":         testSigSecret,
+				"SLACK_CHANNEL_ID":         "C0123456789",
+				"SLACK_VERIFICATION_TOKEN": tt.configured,
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			form := slashCommand("sso", "https://hooks.slack.com/x", "C0123456789", "U123")
+			form.Set("token", tt.sent)
+			w := httptest.NewRecorder()
+			Queue(w, signedRequest(form))
+			if w.Code != tt.want {
+				t.Errorf("slash command status = %d, want %d", w.Code, tt.want)
+			}
+
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(testInteraction(t, "https://hooks.slack.com/x", "C0123456789", interactionAction{ActionID: shareActionID, Value: "sso"})), &payload); err != nil {
+				t.Fatal(err)
+			}
+			payload["token"] = tt.sent
+			w = httptest.NewRecorder()
+			Queue(w, signedRequest(url.Values{"payload": {mustJSON(t, payload)}}))
+			if w.Code != tt.want {
+				t.Errorf("interaction status = %d, want %d", w.Code, tt.want)
+			}
+
+			wantQueued := 0
+			if tt.want == http.StatusOK {
+				wantQueued = 2
+			}
+			if got := len(ft.messages(t)); got != wantQueued {
+				t.Errorf("queued %d messages, want %d", got, wantQueued)
+			}
+		})
+	}
+}
+
+func TestHideAllowedChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		hide     string
+		template string
+		want     string
+	}{
+		{"channels shown", "", "", "Anerbot needs to run in <#C0123456789>, try again there! :broken_heart:"},
+		{"channels hidden", "true", "", "Anerbot needs to run in an allowed channel, try again there! :broken_heart:"},
+		{"channels hidden in a custom template", "true", "Please use {channels} for Anerbot.", "Please use an allowed channel for Anerbot."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET":            testSigSecret,
+				"SLACK_CHANNEL_ID":            "C0123456789",
+				"SLACK_CHANNEL_MESSAGE":       tt.template,
+				"SLACK_HIDE_ALLOWED_CHANNELS": tt.hide,
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			res := queueCommand(t, signedRequest(slashCommand("sso", "https://hooks.slack.com/x", "C0000000000", "U123")))
+			if res.Text != tt.want {
+				t.Errorf("response = %q, want %q", res.Text, tt.want)
+			}
+			if tt.hide != "" && strings.Contains(res.Text, "C0123456789") {
+				t.Errorf("response %q names the allowed channel", res.Text)
+			}
+			if got := len(ft.messages(t)); got != 0 {
+				t.Errorf("queued %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestSearchPrefixQueued(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"search api", "api"},
+		{"search for searchable", "for searchable"},
+		{"search", "search"},
+		{"searchable", "searchable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			defer useEnv(map[string]string{
+				"SLACK_SIG_SECRET": testSigSecret,
+				"SLACK_CHANNEL_ID": "C0123456789",
+			})()
+			ft := useFakeTopic(t)
+			defer ft.close()
+
+			queueCommand(t, signedRequest(slashCommand(tt.text, "https://hooks.slack.com/x", "C0123456789", "U123")))
+			messages := ft.messages(t)
+			if len(messages) != 1 || messages[0].Query != tt.want {
+				t.Errorf("queued %+v, want the query %q", messages, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package response
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Name used for features without a team in a breakdown.
+const unassignedTeam = "Unassigned"
+
+// Struct for the number of matching features owned by a single team.
+type teamCount struct {
+	Team  string
+	Count int
+}
+
+// Function to count how many features belong to each team. A feature
+// owned by several comma-separated teams counts towards each of them.
+// Teams are ordered by count, largest first, then by name.
+func countByTeam(f []feature) []teamCount {
+	counts := make(map[string]int)
+	for _, v := range f {
+		teams := splitTeams(v.Fields.TeamResponsible)
+		if len(teams) == 0 {
+			teams = []string{unassignedTeam}
+		}
+		for _, t := range teams {
+			counts[t]++
+		}
+	}
+
+	var breakdown []teamCount
+	for t, c := range counts {
+		breakdown = append(breakdown, teamCount{Team: t, Count: c})
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Count != breakdown[j].Count {
+			return breakdown[i].Count > breakdown[j].Count
+		}
+		return breakdown[i].Team < breakdown[j].Team
+	})
+
+	return breakdown
+}
+
+// Function to split a Team responsible value into each of its teams.
+func splitTeams(value string) []string {
+	var teams []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			teams = append(teams, t)
+		}
+	}
+	return teams
+}
+
+// Function to build the response to a breakdown request, listing the
+// number of matching features per team.
+func buildBreakdownResponse(f []feature, search searchRequest) *slackResponse {
+	res := &slackResponse{
+		ReplaceOriginal: strconv.FormatBool(true),
+		ResponseType:    "ephemeral",
+	}
+	applyVisibility(res, len(f), search)
+	if len(f) == 0 {
+		res.Text = styleHeader("No items found, try another search term")
+		return res
+	}
+
+	var value string
+	for _, c := range countByTeam(f) {
+		value += fmt.Sprintf("• *%s:* %d%s", c.Team, c.Count, lineDelimiter)
+	}
+
+	res.Text = styleHeader(fmt.Sprintf(`Found %d items for "%s"! Here's how they break down by team.`, len(f), search.text()))
+	res.Attachments = []attachment{
+		{
+			Fallback: value,
+			Fields: []attachmentField{
+				{
+					Title: "",
+					Value: value,
+				},
+			},
+		},
+	}
+	return res
+}
@@ -0,0 +1,34 @@
+package response
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountByTeam(t *testing.T) {
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recAudit000000000", "fields": map[string]interface{}{"Feature": "Audit log", "Team responsible": "Identity"}},
+		map[string]interface{}{"id": "recBill0000000000", "fields": map[string]interface{}{"Feature": "Billing", "Team responsible": "Payments, Identity"}},
+		map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "Single sign-on", "Team responsible": []string{"Identity", "Platform"}}},
+		map[string]interface{}{"id": "recZapier00000000", "fields": map[string]interface{}{"Feature": "Zapier"}},
+	)
+	want := []teamCount{{"Identity", 3}, {"Payments", 1}, {"Platform", 1}, {unassignedTeam, 1}}
+	if got := countByTeam(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("countByTeam() = %+v, want %+v", got, want)
+	}
+
+	res, err := buildSlackResponse(f, parseQuery("breakdown sso"))
+	if err != nil {
+		t.Fatalf("buildSlackResponse() error = %v", err)
+	}
+	if !strings.Contains(res.Text, `Found 4 items for "sso"`) {
+		t.Errorf("header = %q, want the number of features found", res.Text)
+	}
+	value := res.Attachments[0].Fallback
+	for _, line := range []string{"• *Identity:* 3", "• *Payments:* 1", "• *Platform:* 1", "• *Unassigned:* 1"} {
+		if !strings.Contains(value, line) {
+			t.Errorf("breakdown = %q, want it to contain %q", value, line)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package response
+
+import "testing"
+
+func TestBriefResultsLeaveOutMetadata(t *testing.T) {
+	defer func(c, m bool, tbl, v string, s map[string]string) {
+		copyLinkButton, showMatches, airtableTableID, airtableViewID, sourceNames = c, m, tbl, v, s
+	}(copyLinkButton, showMatches, airtableTableID, airtableViewID, sourceNames)
+	copyLinkButton, showMatches, airtableTableID, airtableViewID = true, true, "tblFeatures", "viwAll"
+	sourceNames = map[string]string{airtableBaseID: "Product", airtableTableID: "Features"}
+
+	f := testFeatures(t, map[string]interface{}{
+		"id":     "recSso00000000001",
+		"fields": map[string]interface{}{"Feature": "SSO", "Roadmap": "SSO for everyone"},
+	})
+	tests := []struct {
+		query      string
+		wantFields bool
+		wantFooter bool
+		wantBlocks bool
+	}{
+		{"sso", true, true, true},
+		{"brief sso", false, false, false},
+		{"BRIEF sso", false, false, false},
+	}
+	for _, tt := range tests {
+		res, err := buildSlackResponse(f, parseQuery(tt.query))
+		if err != nil {
+			t.Fatalf("buildSlackResponse(%q) error = %v", tt.query, err)
+		}
+		var found bool
+		for _, a := range res.Attachments {
+			if a.TitleLink == "" {
+				continue
+			}
+			found = true
+			if got := len(a.Fields) > 0; got != tt.wantFields {
+				t.Errorf("%q fields = %+v, want metadata fields %v", tt.query, a.Fields, tt.wantFields)
+			}
+			if a.Title != "SSO" || a.TitleLink != titleLink("recSso00000000001", tt.query) {
+				t.Errorf("%q title = %q linking %q, want the linked feature name", tt.query, a.Title, a.TitleLink)
+			}
+			if got := a.Footer != ""; got != tt.wantFooter {
+				t.Errorf("%q footer = %q, want footer %v", tt.query, a.Footer, tt.wantFooter)
+			}
+			if got := len(a.Blocks) > 0; got != tt.wantBlocks {
+				t.Errorf("%q blocks = %+v, want blocks %v", tt.query, a.Blocks, tt.wantBlocks)
+			}
+		}
+		if !found {
+			t.Errorf("%q has no feature attachment", tt.query)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package response
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Cache of every feature name in the Airtable view, along with the record
+// ID of each feature. Names are fetched the first time they are needed
+// and refreshed once they are older than the configured refresh interval.
+var nameCache struct {
+	mu        sync.RWMutex
+	names     []featureName
+	fetchedAt time.Time
+}
+
+// Struct for the name of a feature and the record ID it belongs to.
+type featureName struct {
+	ID   string
+	Name string
+}
+
+// Function to return the name of every feature in the Airtable view.
+func featureNames() ([]string, error) {
+	list, err := featureNameList()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(list))
+	for i, v := range list {
+		names[i] = v.Name
+	}
+	return names, nil
+}
+
+// Function to return the name and record ID of every feature in the
+// Airtable view. The cached names are served while they are fresh,
+// otherwise they are fetched again from Airtable. Stale names are still
+// served if the refresh fails so a brief Airtable outage doesn't break
+// suggestions. This is safe to call from multiple goroutines.
+func featureNameList() ([]featureName, error) {
+	nameCache.mu.RLock()
+	names, fresh := nameCache.names, nameCacheFresh()
+	nameCache.mu.RUnlock()
+	if fresh {
+		return names, nil
+	}
+
+	nameCache.mu.Lock()
+	defer nameCache.mu.Unlock()
+
+	// Another caller may have refreshed the names while waiting on the lock.
+	if nameCacheFresh() {
+		return nameCache.names, nil
+	}
+
+	names, err := fetchFeatureNames()
+	if err != nil {
+		if nameCache.names != nil {
+			log.Printf("unable to refresh feature names, serving stale names: %v", err)
+			return nameCache.names, nil
+		}
+		return nil, err
+	}
+	nameCache.names = names
+	nameCache.fetchedAt = time.Now()
+
+	return names, nil
+}
+
+// Function to report whether the Airtable view has no features at all,
+// as opposed to a search simply matching none of them. The view is only
+// reported as empty when its feature names were fetched successfully.
+func viewEmpty() bool {
+	names, err := featureNames()
+	return err == nil && len(names) == 0
+}
+
+// Function to report whether the cached feature names can be served
+// without a refresh. The caller must hold a lock on nameCache.
+func nameCacheFresh() bool {
+	return nameCache.names != nil && time.Since(nameCache.fetchedAt) < nameRefreshInterval
+}
+
+// Function to fetch the name and record ID of every feature in the
+// Airtable view.
+func fetchFeatureNames() ([]featureName, error) {
+	client, err := newLister()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new airtable client: %v", err)
+	}
+
+	// Only the feature name is needed, so request nothing else.
+	var features []feature
+	err = client.ListRecords(airtableTableID, &features, airtable.ListParameters{
+		CellFormat: "string",
+		Fields:     []string{"Feature"},
+		TimeZone:   airtableTimeZone,
+		UserLocale: "en-US",
+		View:       airtableViewID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]featureName, 0, len(features))
+	for _, v := range features {
+		if v.Fields.Feature != "" {
+			names = append(names, featureName{ID: v.AirtableID, Name: v.Fields.Feature})
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,122 @@
+package response
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFeatureNamesCache(t *testing.T) {
+	defer func(i time.Duration) { nameRefreshInterval = i }(nameRefreshInterval)
+	defer resetNameCache()
+
+	tests := []struct {
+		name        string
+		interval    time.Duration
+		age         time.Duration
+		failRefresh bool
+		want        []string
+		wantFetches int
+	}{
+		{"fresh names are served from the cache", time.Hour, time.Minute, false, []string{"Audit log"}, 0},
+		{"stale names are refreshed", time.Hour, 2 * time.Hour, false, []string{"Audit log", "Billing"}, 1},
+		{"stale names are served when the refresh fails", time.Hour, 2 * time.Hour, true, []string{"Audit log"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nameRefreshInterval = tt.interval
+			a := newFakeAirtable(map[string]map[string]interface{}{
+				"recAudit000000000": {"Feature": "Audit log"},
+				"recBill0000000000": {"Feature": "Billing"},
+			})
+			defer useFakeAirtable(a)()
+			if tt.failRefresh {
+				newLister = func() (recordLister, error) { return nil, errors.New("unavailable") }
+			}
+
+			nameCache.mu.Lock()
+			nameCache.names = []featureName{{ID: "recAudit000000000", Name: "Audit log"}}
+			nameCache.fetchedAt = time.Now().Add(-tt.age)
+			nameCache.mu.Unlock()
+
+			got, err := featureNames()
+			if err != nil {
+				t.Fatalf("featureNames() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("featureNames() = %v, want %v", got, tt.want)
+			}
+			if len(a.queries) != tt.wantFetches {
+				t.Errorf("fetched names %d times, want %d", len(a.queries), tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestFeatureNamesFetchedOnce(t *testing.T) {
+	defer func(i time.Duration) { nameRefreshInterval = i }(nameRefreshInterval)
+	nameRefreshInterval = time.Hour
+	resetNameCache()
+	defer resetNameCache()
+
+	a := newFakeAirtable(map[string]map[string]interface{}{"recAudit000000000": {"Feature": "Audit log"}})
+	defer useFakeAirtable(a)()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if names, err := featureNames(); err != nil || len(names) != 1 {
+				t.Errorf("featureNames() = %v, %v, want one name", names, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(a.queries) != 1 {
+		t.Errorf("fetched names %d times, want 1", len(a.queries))
+	}
+}
+
+func TestEmptyViewWarning(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  map[string]map[string]interface{}
+		listErr  error
+		want     string
+		wantWarn bool
+	}{
+		{"view without any features", nil, nil, "Anerbot couldn't find any features in Airtable at all", true},
+		{"query matching nothing", map[string]map[string]interface{}{"recBilling": {"Feature": "Billing"}}, nil, "No items found, try another search term", false},
+		{"feature names unavailable", nil, errors.New("connection refused"), "No items found, try another search term", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"AIRTABLE_TABLE_ID": "tblFeatures", "AIRTABLE_VIEW_ID": "viwAll", "SLACK_SEARCH_TIPS": ""})()
+			resetNameCache()
+			defer resetNameCache()
+			defer useFakeAirtable(newFakeAirtable(tt.records))()
+			if tt.listErr != nil {
+				newLister = func() (recordLister, error) { return failingLister{tt.listErr}, nil }
+			}
+
+			var res *slackResponse
+			logs := captureLogs(func() {
+				var err error
+				if res, err = buildSlackResponse(nil, parseQuery("zzz")); err != nil {
+					t.Fatalf("buildSlackResponse() error = %v", err)
+				}
+			})
+			if !strings.Contains(res.Text, tt.want) {
+				t.Errorf("header = %q, want it to contain %q", res.Text, tt.want)
+			}
+			warned := strings.Contains(logs, "warning: Airtable view viwAll in table tblFeatures has no features")
+			if warned != tt.wantWarn {
+				t.Errorf("maintainers warned = %t, want %t:\n%s", warned, tt.wantWarn, logs)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package response
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChannelSettingsNormalizeIDs(t *testing.T) {
+	defer func(s, h map[string][]string, c string) {
+		channelScopes, channelHiddenFields, searchColumn = s, h, c
+	}(channelScopes, channelHiddenFields, searchColumn)
+	channelScopes = parseScopes(" c2147483705 =plan|entitlements")
+	channelHiddenFields = parseScopes("c2147483705=flag")
+	searchColumn = ""
+
+	tests := []struct {
+		channelID  string
+		wantScope  []string
+		wantHidden bool
+	}{
+		{"C2147483705", []string{"Plan", "Entitlements"}, true},
+		{" c2147483705 ", []string{"Plan", "Entitlements"}, true},
+		{"C0000000000", searchFields, false},
+	}
+	for _, tt := range tests {
+		if got := defaultScope(tt.channelID); !reflect.DeepEqual(got, tt.wantScope) {
+			t.Errorf("defaultScope(%q) = %v, want %v", tt.channelID, got, tt.wantScope)
+		}
+		hidden := true
+		for _, d := range visibleFields(tt.channelID) {
+			if d.Name == "Feature flag" {
+				hidden = false
+			}
+		}
+		if hidden != tt.wantHidden {
+			t.Errorf("visibleFields(%q) hides the feature flag = %v, want %v", tt.channelID, hidden, tt.wantHidden)
+		}
+	}
+}
+
+func TestChannelDefaultScopes(t *testing.T) {
+	defer useEnv(t, map[string]string{
+		"CHANNEL_DEFAULT_SCOPES": "CSALES00000=plan,CDOCS000000=docs|feature",
+		"AIRTABLE_SEARCH_COLUMN": "",
+		"QUERY_CACHE_TTL":        "",
+	})()
+
+	tests := []struct {
+		name      string
+		channelID string
+		query     string
+		want      string
+	}{
+		{"sales channel searches plans", "CSALES00000", "sso", `OR(SEARCH('sso', LOWER({Plan})) > 0)`},
+		{"docs channel searches docs and names", "CDOCS000000", "sso", `OR(SEARCH('sso', LOWER({External documentation})) > 0, SEARCH('sso', LOWER({Feature})) > 0)`},
+		{"scoped terms keep their field", "CSALES00000", "roadmap:sso", `OR(SEARCH('sso', LOWER({Roadmap})) > 0)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newFakeAirtable(nil)
+			defer useFakeAirtable(a)()
+
+			search := parseQuery(tt.query)
+			search.ChannelID = tt.channelID
+			if _, err := queryAirtable(search); err != nil {
+				t.Fatalf("queryAirtable() error = %v", err)
+			}
+			if len(a.queries) != 1 || a.queries[0].FilterByFormula != tt.want {
+				t.Errorf("queries = %+v, want the formula %s", a.queries, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelHiddenFields(t *testing.T) {
+	defer useEnv(t, map[string]string{
+		"CHANNEL_HIDDEN_FIELDS": "CEXTERNAL00=flag|team,CDOCS000000=docs",
+		"SLACK_COMPACT_FIELDS":  "true",
+		"SLACK_COLLAPSE_FIELDS": "",
+		"DISPLAY_FIELDS":        "",
+	})()
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature":                "SSO",
+		"Team responsible":       "Identity",
+		"Plan":                   "Enterprise",
+		"Feature flag":           "sso-beta",
+		"External documentation": "https://docs.example.com/sso",
+	}})
+
+	tests := []struct {
+		name      string
+		channelID string
+		want      []string
+	}{
+		{"external channel hides internal fields", "CEXTERNAL00", []string{"Plan", "External Documentation"}},
+		{"docs channel hides documentation", "cdocs000000", []string{"Team(s)", "Plan", "Feature Flag"}},
+		{"other channels see every field", "C0123456789", []string{"Team(s)", "Plan", "Feature Flag", "External Documentation"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := parseQuery("sso")
+			search.ChannelID = tt.channelID
+			res, err := buildSlackResponse(f, search)
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			var got []string
+			for _, a := range res.Attachments {
+				for _, field := range a.Fields {
+					got = append(got, field.Title[strings.Index(field.Title, " ")+1:])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rendered fields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package response
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Maximum number of attachments Slack accepts in a single message.
+const maxAttachments = 100
+
+// Function to split a response into several messages when it is too
+// large to send to Slack in one. Attachments are kept in order and
+// packed into each message until adding another would exceed the
+// configured payload size or Slack's attachment limit. The first message
+// replaces the original "Hang tight" message while the rest follow it.
+func chunkResponse(res *slackResponse) ([]*slackResponse, error) {
+	// Measure the size of the response without any attachments, which
+	// every message will carry.
+	first := *res
+	first.Attachments = nil
+	base, err := json.Marshal(first)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []*slackResponse{&first}
+	current, size := &first, len(base)
+	for _, a := range res.Attachments {
+		b, err := json.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+
+		// Start a new message when this attachment doesn't fit, unless
+		// the current message is empty and it would never fit anyway.
+		full := size+len(b) > maxPayloadBytes || len(current.Attachments) >= maxAttachments
+		if full && len(current.Attachments) > 0 {
+			current = &slackResponse{
+				ReplaceOriginal: strconv.FormatBool(false),
+				ResponseType:    res.ResponseType,
+			}
+			chunks = append(chunks, current)
+			size = len(base)
+		}
+
+		current.Attachments = append(current.Attachments, a)
+		size += len(b) + 1
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,80 @@
+package response
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Function to build a response with n attachments of roughly the size
+// passed in.
+func testResponse(n, size int) *slackResponse {
+	res := &slackResponse{ReplaceOriginal: "true", ResponseType: responseEphemeral, Text: "Found some items!"}
+	for i := 0; i < n; i++ {
+		res.Attachments = append(res.Attachments, attachment{Title: strings.Repeat("x", size)})
+	}
+	return res
+}
+
+func TestChunkResponse(t *testing.T) {
+	defer func(m int) { maxPayloadBytes = m }(maxPayloadBytes)
+
+	tests := []struct {
+		name        string
+		maxBytes    int
+		attachments int
+		size        int
+		want        int
+	}{
+		{"fits in one message", 10000, 5, 100, 1},
+		{"split by size", 1000, 10, 300, 5},
+		{"split by attachment count", 1 << 20, 150, 10, 2},
+		{"oversized attachment is sent on its own", 200, 2, 500, 2},
+		{"no attachments", 1000, 0, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxPayloadBytes = tt.maxBytes
+			res := testResponse(tt.attachments, tt.size)
+			chunks, err := chunkResponse(res)
+			if err != nil {
+				t.Fatalf("chunkResponse() error = %v", err)
+			}
+			if len(chunks) != tt.want {
+				t.Fatalf("chunkResponse() = %d messages, want %d", len(chunks), tt.want)
+			}
+
+			var total int
+			for i, c := range chunks {
+				total += len(c.Attachments)
+				if want := i == 0; (c.ReplaceOriginal == "true") != want {
+					t.Errorf("message %d replaces the original = %s, want %t", i, c.ReplaceOriginal, want)
+				}
+				if b, _ := json.Marshal(c); len(c.Attachments) > 1 && len(b) > tt.maxBytes {
+					t.Errorf("message %d is %d bytes, want at most %d", i, len(b), tt.maxBytes)
+				}
+			}
+			if total != tt.attachments {
+				t.Errorf("sent %d attachments, want %d", total, tt.attachments)
+			}
+		})
+	}
+}
+
+func TestPostResultsSendsEveryChunk(t *testing.T) {
+	defer func(b, m int) { maxPayloadBytes, maxResponseMessages = b, m }(maxPayloadBytes, maxResponseMessages)
+	maxPayloadBytes, maxResponseMessages = 1000, 0
+
+	slack := newFakeSlack()
+	defer slack.Close()
+	if err := postResults(queueMessage{ResponseUrl: slack.URL, RequestID: "test"}, testResponse(10, 300), 10); err != nil {
+		t.Fatalf("postResults() error = %v", err)
+	}
+	posted := slack.posted()
+	if len(posted) != 5 {
+		t.Fatalf("posted %d messages, want 5", len(posted))
+	}
+	if posted[0].Text == "" {
+		t.Errorf("first message has no header, want the header of the results")
+	}
+}
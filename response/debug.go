@@ -0,0 +1,129 @@
+package response
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Function to add an explanation of how the query was parsed to the end
+// of a response when the search request is in debug mode. The time taken
+// to fetch the results from Airtable is added to the response header.
+func appendDebug(res *slackResponse, search searchRequest) {
+	if !search.Debug {
+		return
+	}
+
+	if search.Elapsed > 0 {
+		res.Text += fmt.Sprintf(" (fetched in %s)", search.Elapsed.Round(time.Millisecond))
+	}
+
+	explanation := explainQuery(search)
+	res.Attachments = append(res.Attachments, attachment{
+		Title:    "How your query was parsed",
+		Fallback: explanation,
+		Fields: []attachmentField{
+			{
+				Title: "",
+				Value: explanation,
+			},
+		},
+	})
+}
+
+// Function to append a single explanation of how every query of a digest
+// was parsed, when any of them asked for debugging.
+func appendDigestDebug(res *slackResponse, searches []searchRequest) {
+	var explanations []string
+	for _, s := range searches {
+		if s.Debug {
+			explanations = append(explanations, fmt.Sprintf("*Query:* %s%s%s", s.Query, lineDelimiter, explainQuery(s)))
+		}
+	}
+	if len(explanations) == 0 {
+		return
+	}
+
+	explanation := strings.Join(explanations, lineDelimiter+lineDelimiter)
+	res.Attachments = append(res.Attachments, attachment{
+		Title:    "How your queries were parsed",
+		Fallback: explanation,
+		Fields: []attachmentField{
+			{
+				Title: "",
+				Value: explanation,
+			},
+		},
+	})
+}
+
+// Function to explain, in Slack markdown, how a query was parsed into a
+// search request: the keyword, how terms are combined, which fields each
+// term is searched in, any exclusions and any flags, along with the
+// version of Anerbot that answered.
+func explainQuery(search searchRequest) string {
+	var lines []string
+	if search.Keyword != "" {
+		lines = append(lines, fmt.Sprintf("*Keyword:* %s", search.Keyword))
+	}
+
+	switch search.Operator {
+	case operatorAnd:
+		lines = append(lines, "*Operator:* AND, every term must match")
+	case operatorOr:
+		lines = append(lines, "*Operator:* OR, any term may match")
+	default:
+		lines = append(lines, "*Operator:* none, words are searched as a single phrase")
+	}
+
+	scope := defaultScope(search.ChannelID)
+	for _, t := range search.Terms {
+		lines = append(lines, fmt.Sprintf("*Term:* %s", explainTerm(t, scope)))
+	}
+	for _, t := range search.Exclusions {
+		lines = append(lines, fmt.Sprintf("*Excluded:* %s", explainTerm(t, scope)))
+	}
+
+	if search.Flagged != nil {
+		lines = append(lines, fmt.Sprintf("*Filter:* %s:%t", flaggedFilter, *search.Flagged))
+	}
+	if search.Documented != nil {
+		lines = append(lines, fmt.Sprintf("*Filter:* %s:%t", documentedFilter, *search.Documented))
+	}
+	if search.Team != "" {
+		lines = append(lines, fmt.Sprintf("*Filter:* team:%s", search.Team))
+	}
+	if search.Quarter != "" {
+		lines = append(lines, fmt.Sprintf("*Filter:* %s:%s", quarterFilter, search.Quarter))
+	}
+
+	var flags []string
+	if search.WholeWord {
+		flags = append(flags, wholeWordFlag)
+	}
+	if search.Debug {
+		flags = append(flags, debugFlag)
+	}
+	if search.Language != "" {
+		flags = append(flags, languageFlag+search.Language)
+	}
+	if len(flags) > 0 {
+		lines = append(lines, fmt.Sprintf("*Flags:* %s", strings.Join(flags, ", ")))
+	}
+
+	if search.Truncated {
+		lines = append(lines, fmt.Sprintf("*Truncated:* only the first %d words were searched", maxQueryTokens))
+	}
+
+	lines = append(lines, fmt.Sprintf("*Version:* %s", Version))
+
+	return strings.Join(lines, lineDelimiter)
+}
+
+// Function to explain a single term and the fields it is searched in.
+func explainTerm(t searchTerm, scope []string) string {
+	if t.Field != "" {
+		scope = []string{t.Field}
+	}
+	return fmt.Sprintf(`"%s" in %s`, t.Text, strings.Join(scope, ", "))
+}
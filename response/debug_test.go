@@ -0,0 +1,103 @@
+package response
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainQuery(t *testing.T) {
+	defer func(d string, f []string, c string, v string) {
+		lineDelimiter, searchFields, searchColumn, Version = d, f, c, v
+	}(lineDelimiter, searchFields, searchColumn, Version)
+	lineDelimiter, searchFields, searchColumn, Version = "\n", []string{"Feature", "Plan"}, "", "1.2.3"
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"phrase", "single sign on", []string{
+			"*Operator:* none, words are searched as a single phrase",
+			`*Term:* "single sign on" in Feature, Plan`,
+			"*Version:* 1.2.3",
+		}},
+		{"operators, scopes, exclusions and flags", `breakdown sso OR plan:"team plan" -beta flagged:true --word --debug`, []string{
+			"*Keyword:* breakdown",
+			"*Operator:* OR, any term may match",
+			`*Term:* "sso" in Feature, Plan`,
+			`*Term:* "team plan" in Plan`,
+			`*Excluded:* "beta" in Feature, Plan`,
+			"*Filter:* flagged:true",
+			"*Flags:* --word, --debug",
+			"*Version:* 1.2.3",
+		}},
+		{"filters only", "documented:false quarter:Q3-2024", []string{
+			"*Operator:* none, words are searched as a single phrase",
+			"*Filter:* documented:false",
+			"*Filter:* quarter:Q3 2024",
+			"*Version:* 1.2.3",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := explainQuery(parseQuery(tt.query)), strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("explainQuery() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestDebugAttachment(t *testing.T) {
+	for _, query := range []string{"sso", "sso --debug"} {
+		search := parseQuery(query)
+		res := &slackResponse{}
+		appendDebug(res, search)
+		if got := len(res.Attachments) == 1 && res.Attachments[0].Title == "How your query was parsed"; got != search.Debug {
+			t.Errorf("appendDebug(%q) added an explanation = %t, want %t", query, got, search.Debug)
+		}
+	}
+}
+
+func TestDebugTiming(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		elapsed time.Duration
+		want    string
+	}{
+		{"debug mode", "sso --debug", 820*time.Millisecond + 400*time.Microsecond, "Results (fetched in 820ms)"},
+		{"not debugging", "sso", 820 * time.Millisecond, "Results"},
+		{"nothing fetched", "sso --debug", 0, "Results"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := parseQuery(tt.query)
+			search.Elapsed = tt.elapsed
+			res := &slackResponse{Text: "Results"}
+			appendDebug(res, search)
+			if res.Text != tt.want {
+				t.Errorf("header = %q, want %q", res.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionInDebugResponses(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+	Version = "v2.1.0"
+	defer useEnv(t, map[string]string{"QUERY_CACHE_TTL": ""})()
+	defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{"recSso": {"Feature": "SSO"}}))()
+
+	for _, query := range []string{"sso --debug", "sso"} {
+		slack := newFakeSlack()
+		if err := respond(t, queueMessage{Query: query, ResponseUrl: slack.URL, RequestID: "req1"}); err != nil {
+			t.Fatalf("Response(%q) error = %v", query, err)
+		}
+		slack.Close()
+		want := strings.HasSuffix(query, "--debug")
+		if got := strings.Contains(slack.text(), "*Version:* v2.1.0"); got != want {
+			t.Errorf("Response(%q) shows the version = %t, want %t", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package response
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Separator placed between the queries of a digest, such as "sso; billing".
+const digestSeparator = ";"
+
+// Function to split a query into the queries of a digest. Queries
+// without a separator are returned as the only query of the digest.
+func splitDigest(query string) []string {
+	var queries []string
+	for _, q := range strings.Split(query, digestSeparator) {
+		if q = strings.TrimSpace(q); q != "" {
+			queries = append(queries, q)
+		}
+	}
+	return queries
+}
+
+// Function to run each query of a digest on its own and post a single
+// response with a section for each of them. Only the configured number of
+// queries are run, so a long digest can't send a flood of requests to
+// Airtable, and the user is told about any that were left out. Everything
+// around the results, such as the share button, is added once for the
+// whole digest.
+func respondWithDigest(message queueMessage, queries []string) error {
+	var skipped int
+	if len(queries) > maxDigestQueries {
+		skipped = len(queries) - maxDigestQueries
+		queries = queries[:maxDigestQueries]
+	}
+
+	var total int
+	var sections []attachment
+	var searches []searchRequest
+	for _, q := range queries {
+		search := parseQuery(q)
+		search.ChannelID = message.ChannelID
+		search.Shared = message.Shared
+		search.Section = true
+		search.scopeToUser(message.UserID)
+		searches = append(searches, search)
+
+		f, err := queryAirtable(search)
+		if err != nil {
+			ce := airtableError(message.RequestID, err)
+			sendFailureMessage(message.ResponseUrl, ce.Code)
+			return ce
+		}
+		section, err := buildSlackResponse(f, search)
+		if err != nil {
+			sendFailureMessage(message.ResponseUrl, errRender)
+			return codeErrorf(errRender, "request %s: unable to build slack response: %v", message.RequestID, err)
+		}
+
+		sections = append(sections, groupHeader(fmt.Sprintf(`"%s": %d items`, q, len(f))))
+		sections = append(sections, section.Attachments...)
+		total += len(f)
+	}
+
+	text := fmt.Sprintf("Found %d items across %d searches!", total, len(queries))
+	if skipped > 0 {
+		text += fmt.Sprintf(" Only the first %d searches were run, so %d were left out.", len(queries), skipped)
+	}
+	res := &slackResponse{
+		ReplaceOriginal: strconv.FormatBool(true),
+		ResponseType:    responseEphemeral,
+		Text:            styleHeader(text),
+		Attachments:     sections,
+	}
+	digest := searchRequest{Query: message.Query, ChannelID: message.ChannelID, Shared: message.Shared}
+	applyVisibility(res, total, digest)
+	appendChrome(res, digest, total, total == 0 && searchTips)
+	appendDigestDebug(res, searches)
+
+	// Split the digest across messages like any other response, linking
+	// to Airtable instead when it needs more messages than are allowed.
+	return postResults(message, res, total)
+}
@@ -0,0 +1,143 @@
+package response
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDigestAddsChromeOnce(t *testing.T) {
+	defer func(fs, sb, vb bool, cta string, d, m int) {
+		featureSelect, shareButton, viewAllButton, callToAction, maxDigestQueries, maxResponseMessages = fs, sb, vb, cta, d, m
+	}(featureSelect, shareButton, viewAllButton, callToAction, maxDigestQueries, maxResponseMessages)
+	featureSelect, shareButton, viewAllButton = true, true, true
+	callToAction = "Questions? Ask in #product"
+	maxDigestQueries, maxResponseMessages = 5, 5
+
+	defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{
+		"recSso00000000001": {"Feature": "SSO"},
+		"recBill0000000001": {"Feature": "Billing"},
+	}))()
+	slack := newFakeSlack()
+	defer slack.Close()
+
+	query := "sso --debug; billing --debug; audit"
+	if err := respondWithDigest(queueMessage{Query: query, ResponseUrl: slack.URL, RequestID: "test"}, splitDigest(query)); err != nil {
+		t.Fatal(err)
+	}
+
+	text := slack.text()
+	tests := []struct {
+		name string
+		want string
+		n    int
+	}{
+		{"feature select", fmt.Sprintf(`"action_id":%q`, featureSelectActionID), 1},
+		{"share button", fmt.Sprintf(`"action_id":%q`, shareActionID), 1},
+		{"view button", fmt.Sprintf(`"action_id":%q`, viewAllActionID), 1},
+		{"call to action", fmt.Sprintf(`"fallback":%q`, callToAction), 1},
+		{"debug explanation", `"title":"How your queries were parsed"`, 1},
+		{"single query explanations", `"title":"How your query was parsed"`, 0},
+	}
+	for _, tt := range tests {
+		if got := strings.Count(text, tt.want); got != tt.n {
+			t.Errorf("%s appears %d times, want %d", tt.name, got, tt.n)
+		}
+	}
+
+	// The share button shares the whole digest again.
+	if !strings.Contains(text, fmt.Sprintf(`"value":%q`, query)) {
+		t.Errorf("share button doesn't share the digest query %q", query)
+	}
+}
+
+func TestOversizedDigestLinksToAirtable(t *testing.T) {
+	defer func(d, m int) { maxDigestQueries, maxResponseMessages = d, m }(maxDigestQueries, maxResponseMessages)
+	maxDigestQueries, maxResponseMessages = 5, 1
+
+	records := make(map[string]map[string]interface{})
+	for i := 0; i < maxAttachments; i++ {
+		records[fmt.Sprintf("rec%014d", i)] = map[string]interface{}{"Feature": fmt.Sprintf("Feature %d", i)}
+	}
+	defer useFakeAirtable(newFakeAirtable(records))()
+	slack := newFakeSlack()
+	defer slack.Close()
+
+	query := "feature; more"
+	if err := respondWithDigest(queueMessage{Query: query, ResponseUrl: slack.URL, RequestID: "test"}, splitDigest(query)); err != nil {
+		t.Fatal(err)
+	}
+	posted := slack.posted()
+	if len(posted) != 1 {
+		t.Fatalf("posted %d messages, want 1", len(posted))
+	}
+	if !strings.Contains(posted[0].Text, "too many to show in Slack") || len(posted[0].Attachments) != 0 {
+		t.Errorf("posted %+v, want a link to Airtable instead of the results", posted[0])
+	}
+}
+
+func TestSplitDigest(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sso", []string{"sso"}},
+		{"sso; billing", []string{"sso", "billing"}},
+		{" sso ;;billing; ", []string{"sso", "billing"}},
+		{";", nil},
+	}
+	for _, tt := range tests {
+		if got := splitDigest(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitDigest(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDigestSections(t *testing.T) {
+	tests := []struct {
+		name        string
+		max         string
+		query       string
+		wantText    string
+		wantQueries int
+		wantHeaders []string
+	}{
+		{"two searches", "", "sso; billing", "Found 2 items across 2 searches!", 2, []string{`"sso": 1 items`, `"billing": 1 items`}},
+		{"bounded", "2", "sso; billing; audit", "Found 2 items across 2 searches! Only the first 2 searches were run, so 1 were left out.", 2, []string{`"sso": 1 items`, `"billing": 1 items`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"QUERY_MAX_DIGEST": tt.max, "QUERY_CACHE_TTL": ""})()
+			a := newFakeAirtable(map[string]map[string]interface{}{
+				"recSso00000000001": {"Feature": "SSO"},
+			})
+			defer useFakeAirtable(a)()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			if err := respondWithDigest(queueMessage{Query: tt.query, ResponseUrl: slack.URL, RequestID: "test"}, splitDigest(tt.query)); err != nil {
+				t.Fatal(err)
+			}
+			posted := slack.posted()
+			if len(posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(posted))
+			}
+			if !strings.Contains(posted[0].Text, tt.wantText) {
+				t.Errorf("text = %q, want %q", posted[0].Text, tt.wantText)
+			}
+			var headers []string
+			for _, at := range posted[0].Attachments {
+				if strings.HasPrefix(at.Title, `"`) {
+					headers = append(headers, at.Title)
+				}
+			}
+			if !reflect.DeepEqual(headers, tt.wantHeaders) {
+				t.Errorf("sections = %q, want %q", headers, tt.wantHeaders)
+			}
+			if got := len(a.queries); got != tt.wantQueries {
+				t.Errorf("ran %d queries, want %d", got, tt.wantQueries)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package response
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modes that can be configured for handling features sharing the same
+// name. An empty mode shows every feature as it is.
+const (
+	duplicatesDedupe = "dedupe"
+	duplicatesMark   = "mark"
+)
+
+// Function to handle features sharing the same name, ignoring case and
+// surrounding whitespace, using the configured mode. Deduping keeps only
+// the first feature with each name, while marking labels every feature
+// after the first as a duplicate. The slice passed in is left untouched.
+func handleDuplicates(f []feature) []feature {
+	if duplicateMode != duplicatesDedupe && duplicateMode != duplicatesMark {
+		return f
+	}
+
+	seen := make(map[string]bool)
+	var handled []feature
+	for _, v := range f {
+		name := foldCase(strings.TrimSpace(v.Fields.Feature))
+		if seen[name] {
+			if duplicateMode == duplicatesDedupe {
+				continue
+			}
+			v.Fields.Feature = fmt.Sprintf("%s (duplicate)", v.Fields.Feature)
+		}
+		seen[name] = true
+		handled = append(handled, v)
+	}
+	return handled
+}
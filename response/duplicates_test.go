@@ -0,0 +1,52 @@
+package response
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHandleDuplicates(t *testing.T) {
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recA", "fields": map[string]interface{}{"Feature": "SSO"}},
+		map[string]interface{}{"id": "recB", "fields": map[string]interface{}{"Feature": "Billing"}},
+		map[string]interface{}{"id": "recC", "fields": map[string]interface{}{"Feature": " sso "}},
+		map[string]interface{}{"id": "recD", "fields": map[string]interface{}{"Feature": "SSO"}},
+	)
+
+	tests := []struct {
+		name      string
+		mode      string
+		want      []string
+		wantIDs   []string
+		wantCount string
+	}{
+		{"shown as they are", "", []string{"SSO", "Billing", " sso ", "SSO"}, []string{"recA", "recB", "recC", "recD"}, "Found 4 items!"},
+		{"deduped keeping the first", "Dedupe", []string{"SSO", "Billing"}, []string{"recA", "recB"}, "Found 2 items!"},
+		{"duplicates marked", "mark", []string{"SSO", "Billing", " sso  (duplicate)", "SSO (duplicate)"}, []string{"recA", "recB", "recC", "recD"}, "Found 4 items!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"DUPLICATE_FEATURES": tt.mode})()
+			got := handleDuplicates(f)
+			var ids []string
+			for _, v := range got {
+				ids = append(ids, v.AirtableID)
+			}
+			if names := featureNamesOf(got); !reflect.DeepEqual(names, tt.want) || !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("handleDuplicates() = %q %v, want %q %v", names, ids, tt.want, tt.wantIDs)
+			}
+			if featureNamesOf(f)[2] != " sso " {
+				t.Errorf("features passed in were changed to %q", featureNamesOf(f))
+			}
+
+			res, err := buildSlackResponse(f, parseQuery("sso"))
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			if !strings.Contains(res.Text, tt.wantCount) {
+				t.Errorf("header = %q, want it to count %q", res.Text, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Type for a short, machine-readable code categorizing why a response
+// failed, so dashboards can group failures by their cause.
+type errorCode string
+
+// Codes for each of the ways a response can fail.
+const (
+	errInvalidMessage errorCode = "invalid_message"
+	errAirtable       errorCode = "airtable_unavailable"
+	errAirtableAuth   errorCode = "airtable_auth_failed"
+	errRender         errorCode = "render_failed"
+	errSlack          errorCode = "slack_unavailable"
+)
+
+// Messages sent to the user in Slack for each failure they are told about.
+var failureMessages = map[errorCode]string{
+	errAirtable:     "Failed to fetch records from Airtable :sob:",
+	errAirtableAuth: "Anerbot isn't able to sign in to Airtable right now, the maintainers need to update its access :lock:",
+	errRender:       "Failed to put your results together :sob:",
+}
+
+// Struct for an error from a response along with the code categorizing it.
+type codedError struct {
+	Code errorCode
+	Err  error
+}
+
+// Function to return the error message prefixed with its code, such as
+// "[airtable_unavailable] error querying Airtable: ...".
+func (e *codedError) Error() string {
+	return fmt.Sprintf("[%s] %v", e.Code, e.Err)
+}
+
+// Function to create a codedError from a formatted error message.
+func codeErrorf(code errorCode, format string, a ...interface{}) error {
+	return &codedError{Code: code, Err: fmt.Errorf(format, a...)}
+}
+
+// Function to wrap an error from querying Airtable with its code. Errors
+// where Airtable rejected the credentials, such as after the API key was
+// revoked, get their own code and tell maintainers what to check, so they
+// aren't mistaken for an Airtable outage.
+func airtableError(requestID string, err error) *codedError {
+	if ae, ok := err.(airtable.Error); ok && (ae.StatusCode == http.StatusUnauthorized || ae.StatusCode == http.StatusForbidden) {
+		return &codedError{
+			Code: errAirtableAuth,
+			Err:  fmt.Errorf("request %s: Airtable rejected the credentials, check that AIRTABLE_API_KEY is valid and can read base %s: %v", requestID, airtableBaseID, err),
+		}
+	}
+	return &codedError{
+		Code: errAirtable,
+		Err:  fmt.Errorf("request %s: error querying Airtable: %v", requestID, err),
+	}
+}
@@ -0,0 +1,180 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Struct for a recordLister that fails every query with the error passed
+// in.
+type failingLister struct {
+	err error
+}
+
+// Function to fail a query.
+func (l failingLister) ListRecords(tableName string, recordsHolder interface{}, listParams ...airtable.ListParameters) error {
+	return l.err
+}
+
+func TestResponseErrorCodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		listErr     error
+		slackDown   bool
+		responseURL string
+		want        errorCode
+		wantMessage string
+	}{
+		{"response URL that isn't Slack", nil, false, "https://example.com/hook", errInvalidMessage, ""},
+		{"Airtable unreachable", errors.New("connection refused"), false, "", errAirtable, "Failed to fetch records from Airtable :sob: (error: airtable_unavailable)"},
+		{"Airtable rejected the credentials", airtable.Error{StatusCode: 401, Type: "AUTHENTICATION_REQUIRED"}, false, "", errAirtableAuth, "Anerbot isn't able to sign in to Airtable right now, the maintainers need to update its access :lock: (error: airtable_auth_failed)"},
+		{"Airtable forbade the base", airtable.Error{StatusCode: 403, Type: "INVALID_PERMISSIONS"}, false, "", errAirtableAuth, "Anerbot isn't able to sign in to Airtable right now, the maintainers need to update its access :lock: (error: airtable_auth_failed)"},
+		{"Airtable error other than credentials", airtable.Error{StatusCode: 500, Type: "SERVER_ERROR"}, false, "", errAirtable, "Failed to fetch records from Airtable :sob: (error: airtable_unavailable)"},
+		{"Slack unreachable", nil, true, "", errSlack, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"SLACK_SHOW_ERROR_CODES": "true", "QUERY_CACHE_TTL": ""})()
+			a := newFakeAirtable(nil)
+			defer useFakeAirtable(a)()
+			if tt.listErr != nil {
+				newLister = func() (recordLister, error) { return failingLister{tt.listErr}, nil }
+			}
+			slack := newFakeSlack()
+			defer slack.Close()
+			responseURL := slack.URL
+			if tt.responseURL != "" {
+				responseURL = tt.responseURL
+			}
+			if tt.slackDown {
+				slack.Close()
+			}
+
+			err := respond(t, queueMessage{Query: "sso", ResponseUrl: responseURL, RequestID: "req1"})
+			ce, ok := err.(*codedError)
+			if !ok || ce.Code != tt.want {
+				t.Fatalf("Response() error = %v, want code %s", err, tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), "["+string(tt.want)+"] ") {
+				t.Errorf("error %q isn't prefixed with its code", err)
+			}
+			if tt.slackDown {
+				return
+			}
+			var got string
+			for _, m := range slack.posted() {
+				got += m.Text
+			}
+			if got != tt.wantMessage {
+				t.Errorf("posted %q, want %q", got, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestInvalidMessageErrorCode(t *testing.T) {
+	err := Response(context.Background(), PubSubMessage{Data: []byte("not json")})
+	if ce, ok := err.(*codedError); !ok || ce.Code != errInvalidMessage {
+		t.Errorf("Response() error = %v, want code %s", err, errInvalidMessage)
+	}
+}
+
+func TestFailureMessageHidesCodes(t *testing.T) {
+	defer useEnv(t, map[string]string{"SLACK_SHOW_ERROR_CODES": ""})()
+	slack := newFakeSlack()
+	defer slack.Close()
+
+	sendFailureMessage(slack.URL, errRender)
+	if got, want := slack.posted(), "Failed to put your results together :sob:"; len(got) != 1 || got[0].Text != want {
+		t.Errorf("posted %+v, want %q", got, want)
+	}
+}
+
+func TestFailureMessageToUnreachableSlack(t *testing.T) {
+	slack := newFakeSlack()
+	slack.Close()
+
+	logs := captureLogs(func() { sendFailureMessage(slack.URL, errAirtable) })
+	if !strings.Contains(logs, "unable to send failure message to Slack") {
+		t.Errorf("logs = %q, want the failure to reach Slack logged", logs)
+	}
+}
+
+func TestLocalResponseErrors(t *testing.T) {
+	defer useFakeAirtable(newFakeAirtable(nil))()
+	newLister = func() (recordLister, error) { return failingLister{errors.New("airtable is down")}, nil }
+
+	tests := []struct {
+		name   string
+		method string
+		want   int
+	}{
+		{"not a POST", "GET", http.StatusMethodNotAllowed},
+		{"Airtable failing", "POST", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", strings.NewReader("text=sso"))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			captureLogs(func() { LocalResponse(w, r) })
+			if w.Code != tt.want {
+				t.Errorf("LocalResponse() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAirtableAuthError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     errorCode
+		wantHint bool
+	}{
+		{"invalid API key", http.StatusUnauthorized, `{"error":{"type":"AUTHENTICATION_REQUIRED","message":"Authentication required"}}`, errAirtableAuth, true},
+		{"no access to the base", http.StatusForbidden, `{"error":{"type":"INVALID_PERMISSIONS_OR_MODEL_NOT_FOUND","message":"Invalid permissions"}}`, errAirtableAuth, true},
+		{"outage", http.StatusServiceUnavailable, `{"error":{"type":"SERVICE_UNAVAILABLE","message":"Try again later"}}`, errAirtable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			defer useEnv(t, map[string]string{
+				"AIRTABLE_API_URL":            server.URL,
+				"AIRTABLE_API_KEY":            "keyTest0000000000",
+				"AIRTABLE_BASE_ID":            "appTest0000000000",
+				"AIRTABLE_RATE_LIMIT_RETRIES": "0",
+			})()
+
+			client, err := newLister()
+			if err != nil {
+				t.Fatalf("newLister() error = %v", err)
+			}
+			var f []feature
+			err = client.ListRecords("tblFeatures", &f)
+			if err == nil {
+				t.Fatal("ListRecords() succeeded, want an error")
+			}
+			ce := airtableError("req1", err)
+			if ce.Code != tt.want {
+				t.Errorf("code = %s, want %s", ce.Code, tt.want)
+			}
+			hint := strings.Contains(ce.Error(), "check that AIRTABLE_API_KEY is valid and can read base appTest0000000000")
+			if hint != tt.wantHint {
+				t.Errorf("error %q tells maintainers to check the credentials = %v, want %v", ce, hint, tt.wantHint)
+			}
+		})
+	}
+}
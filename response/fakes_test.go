@@ -0,0 +1,237 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Struct for a fake Airtable base, keeping the records of the feature
+// table, and of any other tables written to, in memory by their ID. The
+// list parameters of every query are recorded.
+type fakeAirtable struct {
+	records map[string]map[string]interface{}
+	tables  map[string]map[string]map[string]interface{}
+	queries []airtable.ListParameters
+	nextID  int
+}
+
+// Function to create a fake Airtable base with the feature records passed
+// in.
+func newFakeAirtable(records map[string]map[string]interface{}) *fakeAirtable {
+	if records == nil {
+		records = make(map[string]map[string]interface{})
+	}
+	return &fakeAirtable{records: records, tables: make(map[string]map[string]map[string]interface{})}
+}
+
+// Function to replace newLister and newEditor with the fake base,
+// returning a function to put the real ones back.
+func useFakeAirtable(a *fakeAirtable) func() {
+	lister, editor := newLister, newEditor
+	newLister = func() (recordLister, error) { return a, nil }
+	newEditor = func() (recordEditor, error) { return a, nil }
+	return func() { newLister, newEditor = lister, editor }
+}
+
+// Function to return the records of a table. Tables other than the
+// feature table are created empty the first time they are used.
+func (a *fakeAirtable) table(name string) map[string]map[string]interface{} {
+	if name == airtableTableID {
+		return a.records
+	}
+	if a.tables[name] == nil {
+		a.tables[name] = make(map[string]map[string]interface{})
+	}
+	return a.tables[name]
+}
+
+// Patterns matching the parts of formulas the fake understands: record
+// IDs, checked with OR, and fields equal to a value, checked with OR when
+// the formula starts with OR and with AND otherwise.
+var (
+	fakeRecordIDPattern   = regexp.MustCompile(`RECORD_ID\(\) = '([^']*)'`)
+	fakeFieldEqualPattern = regexp.MustCompile(`\{([^}]+)\} = '([^']*)'`)
+)
+
+// Function to report whether a record matches a formula, as far as the
+// fake understands formulas. Anything else matches every record.
+func fakeMatches(formula, id string, fields map[string]interface{}) bool {
+	if ids := fakeRecordIDPattern.FindAllStringSubmatch(formula, -1); len(ids) > 0 {
+		for _, m := range ids {
+			if m[1] == id {
+				return true
+			}
+		}
+		return false
+	}
+	equals := fakeFieldEqualPattern.FindAllStringSubmatch(formula, -1)
+	if len(equals) == 0 {
+		return true
+	}
+	any := strings.HasPrefix(formula, "OR(")
+	for _, m := range equals {
+		if matched := fmt.Sprint(fields[m[1]]) == m[2]; matched == any {
+			return any
+		}
+	}
+	return !any
+}
+
+// Function to list the records of a table matching the formula, in ID
+// order.
+func (a *fakeAirtable) ListRecords(tableName string, recordsHolder interface{}, listParams ...airtable.ListParameters) error {
+	var params airtable.ListParameters
+	if len(listParams) > 0 {
+		params = listParams[0]
+	}
+	a.queries = append(a.queries, params)
+
+	table := a.table(tableName)
+	ids := make([]string, 0, len(table))
+	for id, fields := range table {
+		if fakeMatches(params.FilterByFormula, id, fields) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if params.MaxRecords > 0 && len(ids) > params.MaxRecords {
+		ids = ids[:params.MaxRecords]
+	}
+
+	records := []map[string]interface{}{}
+	for _, id := range ids {
+		records = append(records, map[string]interface{}{"id": id, "fields": table[id]})
+	}
+	return remarshal(records, recordsHolder)
+}
+
+func (a *fakeAirtable) RetrieveRecord(tableName string, recordID string, recordHolder interface{}) error {
+	fields, ok := a.table(tableName)[recordID]
+	if !ok {
+		return airtable.Error{StatusCode: 404, Type: "NOT_FOUND"}
+	}
+	return remarshal(map[string]interface{}{"id": recordID, "fields": fields}, recordHolder)
+}
+
+func (a *fakeAirtable) CreateRecord(tableName string, record interface{}) error {
+	var r struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := remarshal(record, &r); err != nil {
+		return err
+	}
+	a.nextID++
+	a.table(tableName)[fmt.Sprintf("recCreated%07d", a.nextID)] = r.Fields
+	return nil
+}
+
+func (a *fakeAirtable) UpdateRecord(tableName, recordID string, updatedFields map[string]interface{}, record interface{}) error {
+	fields, ok := a.table(tableName)[recordID]
+	if !ok {
+		return airtable.Error{StatusCode: 404, Type: "NOT_FOUND"}
+	}
+	for k, v := range updatedFields {
+		fields[k] = v
+	}
+	return nil
+}
+
+// Function to copy a value into another through JSON, as the Airtable
+// client does with the bodies of its responses.
+func remarshal(from, to interface{}) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, to)
+}
+
+// Struct for a fake Slack endpoint, keeping every message posted to it.
+type fakeSlack struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []slackResponse
+	bodies   []string
+}
+
+// Function to start a fake Slack endpoint. Call Close once the test is
+// finished.
+func newFakeSlack() *fakeSlack {
+	s := &fakeSlack{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var res slackResponse
+		json.Unmarshal(b, &res)
+		s.mu.Lock()
+		s.messages = append(s.messages, res)
+		s.bodies = append(s.bodies, string(b))
+		s.mu.Unlock()
+	}))
+	return s
+}
+
+// Function to return the messages posted to the fake Slack endpoint.
+func (s *fakeSlack) posted() []slackResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]slackResponse(nil), s.messages...)
+}
+
+// Function to return the raw bodies posted to the fake Slack endpoint
+// joined together, for checking what text was sent.
+func (s *fakeSlack) text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.bodies, "\n")
+}
+
+// Function to build features as they would be returned by Airtable.
+func testFeatures(t *testing.T, records ...map[string]interface{}) []feature {
+	t.Helper()
+	var f []feature
+	if err := remarshal(records, &f); err != nil {
+		t.Fatalf("unable to build features: %v", err)
+	}
+	return f
+}
+
+// Function to set env variables and load the configuration from them,
+// returning a function to put the variables back as they were and load
+// the configuration again.
+func useEnv(t *testing.T, env map[string]string) func() {
+	t.Helper()
+	type saved struct {
+		value string
+		ok    bool
+	}
+	old := make(map[string]saved)
+	for k, v := range env {
+		value, ok := os.LookupEnv(k)
+		old[k] = saved{value, ok}
+		os.Setenv(k, v)
+	}
+	if err := loadConfig(); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	return func() {
+		for k, v := range old {
+			if v.ok {
+				os.Setenv(k, v.value)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+		loadConfig()
+	}
+}
@@ -0,0 +1,95 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Action sent by the anerbot-queue function when a user picks one of the
+// collapsed fields of a feature from the overflow menu beside it.
+const fieldDetailsAction = "field_details"
+
+// Struct for the value of an option in the overflow menu of collapsed
+// fields, identifying the feature and the field to show in full. The
+// field is empty for the option pointing at the rest of the fields.
+type fieldDetailsValue struct {
+	ID    string `json:"id"`
+	Field string `json:"field,omitempty"`
+}
+
+// Function to build the value of an option in the overflow menu of
+// collapsed fields. Slack rejects option values longer than the text of
+// an option may be, so false is returned when the value is too long.
+func fieldDetailsOption(id, field string) (string, bool) {
+	value, err := json.Marshal(fieldDetailsValue{ID: id, Field: field})
+	if err != nil {
+		log.Printf("json.Marshal: %v", err)
+		return "", false
+	}
+	return string(value), len(value) <= maxOptionLength
+}
+
+// Function to respond with the full value of a collapsed field picked
+// from the overflow menu beside a feature, since the menu itself only
+// has room for the start of it. Only the user who picked it sees the
+// response, and the results stay as they were.
+func handleFieldDetails(message queueMessage) error {
+	var v fieldDetailsValue
+	if err := json.Unmarshal([]byte(message.Value), &v); err != nil {
+		return codeErrorf(errInvalidMessage, "request %s: unable to parse field details: %v", message.RequestID, err)
+	}
+	if !recordIDPattern.MatchString(v.ID) {
+		return codeErrorf(errInvalidMessage, "request %s: field details are missing a valid feature", message.RequestID)
+	}
+
+	// Only fields shown in the channel can be asked for, so a hidden field
+	// can't be read by crafting the value of an option.
+	var field *displayField
+	for _, d := range visibleFields(message.ChannelID) {
+		if d.Name == v.Field {
+			d := d
+			field = &d
+			break
+		}
+	}
+	if v.Field != "" && field == nil {
+		return codeErrorf(errInvalidMessage, "request %s: field %q is not shown in this channel", message.RequestID, v.Field)
+	}
+	log.Printf("request %s: showing %q of %s", message.RequestID, v.Field, v.ID)
+
+	fields := []string{"Feature"}
+	if field != nil {
+		fields = append(fields, field.Name)
+	}
+	features, err := fetchFeaturesByID([]string{v.ID}, fields)
+	if err != nil {
+		ce := airtableError(message.RequestID, err)
+		sendFailureMessage(message.ResponseUrl, ce.Code)
+		return ce
+	}
+
+	var text string
+	f, ok := features[v.ID]
+	switch {
+	case !ok:
+		text = "Anerbot couldn't find that feature anymore :cry:"
+	case field == nil:
+		text = fmt.Sprintf("Open <%s|%s> to see all of its details.", featureLink(v.ID), slackEscaper.Replace(f.Fields.Feature))
+	case f.fieldValue(field.Name) == "":
+		text = fmt.Sprintf("*%s* of %s is empty.", field.Label, slackEscaper.Replace(f.Fields.Feature))
+	default:
+		text = fmt.Sprintf("*%s* of %s\n%s", field.Label, slackEscaper.Replace(f.Fields.Feature), formatValue(field.Name, f.fieldValue(field.Name)))
+	}
+
+	res := &slackResponse{
+		ReplaceOriginal: strconv.FormatBool(false),
+		ResponseType:    responseEphemeral,
+		Text:            text,
+	}
+	if err := postToSlack(message.ResponseUrl, res); err != nil {
+		return codeErrorf(errSlack, "request %s: %v", message.RequestID, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package response
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCollapsedFieldsCarryFieldDetails(t *testing.T) {
+	defer func(d []displayField) { displayFields = d }(displayFields)
+	displayFields = defaultDisplayFields
+
+	var f feature
+	f.AirtableID = "recSso00000000001"
+	f.Fields.Roadmap = "Q3"
+	f.Fields.TeamResponsible = "Identity"
+	f.Fields.Plan = strings.Repeat("Enterprise ", 20)
+
+	b := collapsedFieldsBlock(f, "C0123456789")
+	if b == nil {
+		t.Fatal("collapsedFieldsBlock() = nil, want a block")
+	}
+	var got []fieldDetailsValue
+	for _, o := range b.Accessory.(blockElement).Options {
+		var v fieldDetailsValue
+		if err := json.Unmarshal([]byte(o.Value), &v); err != nil {
+			t.Fatalf("option value %q isn't field details: %v", o.Value, err)
+		}
+		got = append(got, v)
+	}
+	want := []fieldDetailsValue{{ID: f.AirtableID, Field: "Team responsible"}, {ID: f.AirtableID, Field: "Plan"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("option values = %+v, want %+v", got, want)
+	}
+}
+
+func TestHandleFieldDetails(t *testing.T) {
+	defer func(d []displayField, h map[string][]string) {
+		displayFields, channelHiddenFields = d, h
+	}(displayFields, channelHiddenFields)
+	displayFields = defaultDisplayFields
+	channelHiddenFields = parseScopes("C0999999999=plan")
+
+	long := strings.Repeat("Enterprise and Business plans ", 10)
+	tests := []struct {
+		name      string
+		channelID string
+		value     string
+		want      string
+		wantErr   bool
+	}{
+		{"shows the full value", "C0123456789", `{"id":"recSso00000000001","field":"Plan"}`, long[:len(long)-1], false},
+		{"links to the feature for the rest", "C0123456789", `{"id":"recSso00000000001"}`, "/recSso00000000001|Single sign-on>", false},
+		{"says when the feature is gone", "C0123456789", `{"id":"recGone0000000000","field":"Plan"}`, "couldn't find", false},
+		{"rejects fields hidden in the channel", "C0999999999", `{"id":"recSso00000000001","field":"Plan"}`, "", true},
+		{"rejects fields that aren't shown", "C0123456789", `{"id":"recSso00000000001","field":"Secret"}`, "", true},
+		{"rejects values that aren't record IDs", "C0123456789", `{"id":"Single sign-on","field":"Plan"}`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newFakeAirtable(map[string]map[string]interface{}{
+				"recSso00000000001": {"Feature": "Single sign-on", "Plan": long, "Secret": "hidden"},
+			})
+			defer useFakeAirtable(a)()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			err := handleFieldDetails(queueMessage{ResponseUrl: slack.URL, ChannelID: tt.channelID, RequestID: "test", Action: fieldDetailsAction, Value: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handleFieldDetails() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			posted := slack.posted()
+			if tt.wantErr {
+				if len(posted) != 0 {
+					t.Errorf("posted %d messages, want none", len(posted))
+				}
+				return
+			}
+			if len(posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(posted))
+			}
+			if p := posted[0]; p.ResponseType != responseEphemeral || p.ReplaceOriginal != "false" || !strings.Contains(p.Text, tt.want) {
+				t.Errorf("posted %+v, want an ephemeral message containing %q", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollapseFields(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature":          "SSO",
+		"Roadmap":          "Q3",
+		"Team responsible": "Identity",
+		"Plan":             "Enterprise",
+	}})
+	tests := []struct {
+		name        string
+		collapse    string
+		wantSummary string
+		wantOptions []string
+	}{
+		{"collapsed", "true", ":sparkles: *Roadmap:* Q3", []string{"Team(s): Identity", "Plan: Enterprise"}},
+		{"expanded", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_COLLAPSE_FIELDS":   tt.collapse,
+				"SLACK_UNAVAILABLE_EMOJI": "",
+				"FIELD_ORDER":             "",
+				"TEAM_MENTIONS":           "",
+			})()
+			defer useFakeAirtable(newFakeAirtable(nil))()
+			res, err := buildSlackResponse(f, parseQuery("sso"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a := res.Attachments[0]
+			var section *block
+			for i, b := range a.Blocks {
+				if b.Type == "section" && b.Accessory != nil {
+					section = &a.Blocks[i]
+				}
+			}
+			if tt.wantSummary == "" {
+				if section != nil || len(a.Fields) == 0 {
+					t.Errorf("attachment = %+v, want the fields expanded", a)
+				}
+				return
+			}
+			if section == nil || len(a.Fields) != 0 {
+				t.Fatalf("attachment = %+v, want the fields collapsed into a section", a)
+			}
+			if section.Text.Text != tt.wantSummary {
+				t.Errorf("summary = %q, want %q", section.Text.Text, tt.wantSummary)
+			}
+			menu := section.Accessory.(blockElement)
+			var options []string
+			for _, o := range menu.Options {
+				options = append(options, o.Text.Text)
+			}
+			if menu.Type != "overflow" || menu.ActionID != fieldDetailsActionID || !reflect.DeepEqual(options, tt.wantOptions) {
+				t.Errorf("accessory %s %s with options %q, want an overflow menu with %q", menu.Type, menu.ActionID, options, tt.wantOptions)
+			}
+		})
+	}
+}
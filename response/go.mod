@@ -2,4 +2,7 @@ module github.com/smfsh/anerbot/response
 
 go 1.13
 
-require github.com/smfsh/airtable-go v3.1.2+incompatible
+require (
+	github.com/smfsh/airtable-go v3.1.2+incompatible
+	golang.org/x/text v0.3.2
+)
@@ -0,0 +1,69 @@
+package response
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Group modes that can be configured to bucket the results sent to Slack
+// under headers. An empty group mode lists results without headers.
+const (
+	groupPlanTier = "plan"
+)
+
+// Name of the group for features without a plan.
+const unspecifiedPlan = "Unspecified"
+
+// Function to find the plan tier group a feature belongs to, along with
+// the rank used to order the groups. A feature available on several
+// comma-separated plans is grouped under the best ranked of them. Plans
+// without a configured rank follow every configured tier, and features
+// without a plan are grouped last.
+func planGroup(plan string) (string, int) {
+	name, rank := "", math.MaxInt32-1
+	for _, p := range strings.Split(plan, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		r, ok := planTierRanks[strings.ToLower(p)]
+		if !ok {
+			r = math.MaxInt32 - 1
+		}
+		if name == "" || r < rank {
+			name, rank = p, r
+		}
+	}
+	if name == "" {
+		return unspecifiedPlan, math.MaxInt32
+	}
+	return name, rank
+}
+
+// Function to order a slice of features so that features in the same
+// plan tier group are next to each other, with the groups ordered by rank
+// and then by name. Features keep their order within each group. The
+// slice passed in is left untouched and a grouped copy is returned.
+func groupFeatures(f []feature) []feature {
+	grouped := make([]feature, len(f))
+	copy(grouped, f)
+
+	sort.SliceStable(grouped, func(i, j int) bool {
+		ni, ri := planGroup(grouped[i].Fields.Plan)
+		nj, rj := planGroup(grouped[j].Fields.Plan)
+		if ri != rj {
+			return ri < rj
+		}
+		return ni < nj
+	})
+	return grouped
+}
+
+// Function to build the header attachment shown above each group.
+func groupHeader(name string) attachment {
+	return attachment{
+		Title:    name,
+		Fallback: name,
+	}
+}
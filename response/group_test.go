@@ -0,0 +1,82 @@
+package response
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupFeatures(t *testing.T) {
+	defer func(r map[string]int) { planTierRanks = r }(planTierRanks)
+
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recA", "fields": map[string]interface{}{"Feature": "Audit logs", "Plan": "Enterprise"}},
+		map[string]interface{}{"id": "recB", "fields": map[string]interface{}{"Feature": "Billing"}},
+		map[string]interface{}{"id": "recC", "fields": map[string]interface{}{"Feature": "Comments", "Plan": "Free"}},
+		map[string]interface{}{"id": "recD", "fields": map[string]interface{}{"Feature": "Dashboards", "Plan": "Beta"}},
+		map[string]interface{}{"id": "recE", "fields": map[string]interface{}{"Feature": "Exports", "Plan": "Free, Team"}},
+		map[string]interface{}{"id": "recF", "fields": map[string]interface{}{"Feature": "Filters", "Plan": "Enterprise"}},
+	)
+
+	tests := []struct {
+		name  string
+		ranks string
+		want  []string
+	}{
+		{"default ranks", "", []string{
+			"Enterprise", "Audit logs", "Filters",
+			"Team", "Exports",
+			"Free", "Comments",
+			"Beta", "Dashboards",
+			"Unspecified", "Billing",
+		}},
+		{"sparse ranks keep unknown plans after every tier", "Free=10,Enterprise=20", []string{
+			"Free", "Comments", "Exports",
+			"Enterprise", "Audit logs", "Filters",
+			"Beta", "Dashboards",
+			"Unspecified", "Billing",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planTierRanks = parseRanks(tt.ranks, "Enterprise=1,Team=2,Free=3")
+			var got []string
+			var group string
+			for _, v := range groupFeatures(f) {
+				if name, _ := planGroup(v.Fields.Plan); name != group {
+					group = name
+					got = append(got, name)
+				}
+				got = append(got, v.Fields.Feature)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("grouped = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupHeaders(t *testing.T) {
+	defer func(g string, r map[string]int, n, l bool) {
+		resultGroup, planTierRanks, numberResults, richTextList = g, r, n, l
+	}(resultGroup, planTierRanks, numberResults, richTextList)
+	resultGroup, numberResults, richTextList = groupPlanTier, false, false
+	planTierRanks = parseRanks("", "Enterprise=1,Team=2,Free=3")
+
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recB", "fields": map[string]interface{}{"Feature": "Billing"}},
+		map[string]interface{}{"id": "recA", "fields": map[string]interface{}{"Feature": "Audit logs", "Plan": "Enterprise"}},
+	)
+	res, err := buildSlackResponse(f, parseQuery(""))
+	if err != nil {
+		t.Fatalf("buildSlackResponse() error = %v", err)
+	}
+	var got []string
+	for _, a := range res.Attachments {
+		if a.Title != "" {
+			got = append(got, a.Title)
+		}
+	}
+	if want := []string{"Enterprise", "Audit logs", "Unspecified", "Billing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("titles = %q, want %q", got, want)
+	}
+}
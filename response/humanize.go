@@ -0,0 +1,98 @@
+package response
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Layouts tried, in order, when parsing a timestamp returned by Airtable.
+// Timestamps are returned as strings formatted by the field's settings,
+// so both the ISO and the US formats offered by Airtable are accepted.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04",
+	"2006-01-02 3:04pm",
+	"2006-01-02",
+	"1/2/2006 15:04",
+	"1/2/2006 3:04pm",
+	"1/2/2006",
+	"January 2, 2006 15:04",
+	"January 2, 2006 3:04pm",
+	"January 2, 2006",
+}
+
+// Function to render how long ago a feature was last updated, such as
+// "3 days ago". An empty string is returned when the base doesn't track
+// the last update or the timestamp can't be parsed.
+func lastUpdated(f feature, now time.Time) string {
+	if lastModifiedField == "" {
+		return ""
+	}
+	value := strings.TrimSpace(f.fieldValue(lastModifiedField))
+	if value == "" {
+		return ""
+	}
+
+	t, err := parseTimestamp(value, airtableLocation)
+	if err != nil {
+		log.Printf("unable to parse last modified time for %s: %v", f.AirtableID, err)
+		return ""
+	}
+	return humanizeSince(t, now)
+}
+
+// Function to return the name of whoever last edited a feature, when the
+// base tracks it with a "last modified by" field. An empty string is
+// returned otherwise.
+func lastEditor(f feature) string {
+	if lastModifiedByField == "" {
+		return ""
+	}
+	return strings.TrimSpace(f.fieldValue(lastModifiedByField))
+}
+
+// Function to parse a timestamp using the first layout that matches.
+// Airtable formats timestamps in the time zone it was asked for, so those
+// without a time zone of their own are read in that location.
+func parseTimestamp(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
+
+// Function to render the time elapsed between a timestamp and now in
+// words. Timestamps in the future, such as from clock skew, are treated
+// as having just happened.
+func humanizeSince(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute") + " ago"
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour") + " ago"
+	case d < 30*24*time.Hour:
+		return plural(int(d/(24*time.Hour)), "day") + " ago"
+	case d < 365*24*time.Hour:
+		return plural(int(d/(30*24*time.Hour)), "month") + " ago"
+	}
+	return plural(int(d/(365*24*time.Hour)), "year") + " ago"
+}
+
+// Function to render a count alongside a unit, pluralizing the unit
+// unless the count is exactly one.
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
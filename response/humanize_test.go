@@ -0,0 +1,111 @@
+package response
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		value string
+		loc   *time.Location
+		want  time.Time
+	}{
+		{"2024-03-05T14:30:00Z", newYork, time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)},
+		{"2024-03-05 14:30", newYork, time.Date(2024, 3, 5, 19, 30, 0, 0, time.UTC)},
+		{"3/5/2024 2:30pm", newYork, time.Date(2024, 3, 5, 19, 30, 0, 0, time.UTC)},
+		{"July 4, 2024 9:00", newYork, time.Date(2024, 7, 4, 13, 0, 0, 0, time.UTC)},
+		{"2024-03-05", newYork, time.Date(2024, 3, 5, 5, 0, 0, 0, time.UTC)},
+		{"2024-03-05 14:30", nil, time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.value, tt.loc)
+		if err != nil {
+			t.Errorf("parseTimestamp(%q) error = %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimestamp(%q) = %v, want %v", tt.value, got.UTC(), tt.want)
+		}
+	}
+
+	if _, err := parseTimestamp("last Tuesday", newYork); err == nil {
+		t.Error("parseTimestamp() accepted an unrecognized timestamp")
+	}
+}
+
+func TestHumanizeSince(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{-time.Hour, "just now"},
+		{30 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{45 * time.Minute, "45 minutes ago"},
+		{3 * time.Hour, "3 hours ago"},
+		{3 * 24 * time.Hour, "3 days ago"},
+		{60 * 24 * time.Hour, "2 months ago"},
+		{2 * 365 * 24 * time.Hour, "2 years ago"},
+	}
+	for _, tt := range tests {
+		if got := humanizeSince(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("humanizeSince(%v ago) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+}
+
+func TestLastUpdatedWithoutTimestamp(t *testing.T) {
+	defer func(f string, l *time.Location) { lastModifiedField, airtableLocation = f, l }(lastModifiedField, airtableLocation)
+	airtableLocation = time.UTC
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		field string
+		value interface{}
+		want  string
+	}{
+		{"no field configured", "", "2024-03-05 11:00", ""},
+		{"empty value", "Last modified", "", ""},
+		{"unrecognized value", "Last modified", "last Tuesday", ""},
+		{"recognized value", "Last modified", "2024-03-02T12:00:00Z", "3 days ago"},
+	}
+	for _, tt := range tests {
+		lastModifiedField = tt.field
+		f := testFeatures(t, map[string]interface{}{"id": "rec1", "fields": map[string]interface{}{"Last modified": tt.value}})[0]
+		if got := lastUpdated(f, now); got != tt.want {
+			t.Errorf("%s: lastUpdated() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLastUpdatedUsesAirtableTimeZone(t *testing.T) {
+	defer func(f string, l *time.Location) { lastModifiedField, airtableLocation = f, l }(lastModifiedField, airtableLocation)
+	lastModifiedField = "Last modified"
+
+	tokyo := time.FixedZone("Tokyo", 9*60*60)
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	f := testFeatures(t, map[string]interface{}{"id": "rec1", "fields": map[string]interface{}{"Last modified": "2024-03-05 20:30"}})[0]
+
+	tests := []struct {
+		loc  *time.Location
+		want string
+	}{
+		// 20:30 in Tokyo is 11:30 UTC, half an hour before now.
+		{tokyo, "30 minutes ago"},
+		// 20:30 UTC is after now, so it is treated as just happened.
+		{time.UTC, "just now"},
+	}
+	for _, tt := range tests {
+		airtableLocation = tt.loc
+		if got := lastUpdated(f, now); got != tt.want {
+			t.Errorf("lastUpdated() in %v = %q, want %q", tt.loc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package response
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Maximum number of images shown for a single feature.
+const maxImages = 3
+
+// Pattern matching an Airtable attachment in its string cell format,
+// which is the file name followed by its URL in parentheses, such as
+// "diagram.png (https://dl.airtable.com/.../diagram.png)". Several
+// attachments in one field are separated by commas.
+var attachmentPattern = regexp.MustCompile(`([^,]+?)\s*\((https?://[^\s()]+)\)`)
+
+// File extensions of the attachments Slack can show as images.
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// Struct for a file attached to a field in Airtable.
+type airtableFile struct {
+	Name string
+	URL  string
+}
+
+// Function to find every attachment in the string value of a field.
+func parseAttachments(value string) []airtableFile {
+	var files []airtableFile
+	for _, m := range attachmentPattern.FindAllStringSubmatch(value, -1) {
+		files = append(files, airtableFile{Name: strings.TrimSpace(m[1]), URL: m[2]})
+	}
+	return files
+}
+
+// Function to check whether an attachment is an image, going by the
+// extension of its file name or its URL.
+func (a airtableFile) isImage() bool {
+	for _, s := range []string{a.Name, a.URL} {
+		if imageExtensions[strings.ToLower(path.Ext(s))] {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to build an image block for each image attached to the fields
+// of a feature, checking the fields displayed in the channel along with
+// any fields configured to hold images.
+func imageBlocks(f feature, channelID string) []block {
+	var names []string
+	for _, d := range visibleFields(channelID) {
+		names = append(names, d.Name)
+	}
+	names = append(names, imageFields...)
+
+	var blocks []block
+	for _, name := range names {
+		for _, a := range parseAttachments(f.fieldValue(name)) {
+			if len(blocks) >= maxImages {
+				return blocks
+			}
+			if a.isImage() {
+				blocks = append(blocks, block{
+					Type:     "image",
+					ImageURL: a.URL,
+					AltText:  a.Name,
+				})
+			}
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,59 @@
+package response
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAttachments(t *testing.T) {
+	got := parseAttachments("diagram.png (https://dl.airtable.com/abc/diagram.png), spec.pdf (https://dl.airtable.com/def/spec.pdf)")
+	want := []airtableFile{
+		{Name: "diagram.png", URL: "https://dl.airtable.com/abc/diagram.png"},
+		{Name: "spec.pdf", URL: "https://dl.airtable.com/def/spec.pdf"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAttachments() = %+v, want %+v", got, want)
+	}
+	if got := parseAttachments("Available in Q3 (maybe)"); len(got) != 0 {
+		t.Errorf("parseAttachments() of plain text = %+v, want none", got)
+	}
+}
+
+func TestImageBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		values map[string]interface{}
+		want   []block
+	}{
+		{"image in a displayed field", "", map[string]interface{}{
+			"Roadmap": "mockup.PNG (https://dl.airtable.com/abc/mockup.PNG)",
+		}, []block{{Type: "image", ImageURL: "https://dl.airtable.com/abc/mockup.PNG", AltText: "mockup.PNG"}}},
+		{"image in a configured field", "Screenshots", map[string]interface{}{
+			"Screenshots": "screen (https://dl.airtable.com/abc/screen.jpg)",
+		}, []block{{Type: "image", ImageURL: "https://dl.airtable.com/abc/screen.jpg", AltText: "screen"}}},
+		{"files that aren't images", "Screenshots", map[string]interface{}{
+			"Screenshots": "spec.pdf (https://dl.airtable.com/abc/spec.pdf)",
+		}, nil},
+		{"image in a field that isn't checked", "", map[string]interface{}{
+			"Screenshots": "screen.png (https://dl.airtable.com/abc/screen.png)",
+		}, nil},
+		{"at most three images", "Screenshots", map[string]interface{}{
+			"Screenshots": "a.png (https://x.test/a.png), b.png (https://x.test/b.png), c.png (https://x.test/c.png), d.png (https://x.test/d.png)",
+		}, []block{
+			{Type: "image", ImageURL: "https://x.test/a.png", AltText: "a.png"},
+			{Type: "image", ImageURL: "https://x.test/b.png", AltText: "b.png"},
+			{Type: "image", ImageURL: "https://x.test/c.png", AltText: "c.png"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"IMAGE_FIELDS": tt.fields})()
+			tt.values["Feature"] = "SSO"
+			f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": tt.values})[0]
+			if got := imageBlocks(f, ""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imageBlocks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package response
+
+import (
+	"strings"
+)
+
+// Flag that can be added to a query to display the results in another
+// language, such as "--lang=fr".
+const languageFlag = "--lang="
+
+// Separator between a field name and a language in the name of a field
+// holding a translation, such as "Feature_fr".
+const languageSeparator = "_"
+
+// Function to parse a language flag such as "--lang=fr", returning the
+// lowercase language.
+func parseLanguageFlag(s string) (string, bool) {
+	if len(s) <= len(languageFlag) || !strings.EqualFold(s[:len(languageFlag)], languageFlag) {
+		return "", false
+	}
+	return strings.ToLower(s[len(languageFlag):]), true
+}
+
+// Function to return the language results of a search request are shown
+// in, which is the language the query asked for or the configured display
+// language. An empty language shows the default fields.
+func (r searchRequest) language() string {
+	if r.Language != "" {
+		return r.Language
+	}
+	return displayLanguage
+}
+
+// Function to return a copy of a feature with the value of each field
+// replaced by its translation in the language passed in, such as the
+// "Feature_fr" field for the "Feature" field. Fields without a
+// translation keep their default value.
+func localizeFeature(f feature, lang string) feature {
+	if lang == "" {
+		return f
+	}
+
+	translated := func(name string) (string, bool) {
+		v := strings.TrimSpace(f.Extra[name+languageSeparator+lang])
+		return v, v != ""
+	}
+
+	fields := map[string]*string{
+		"Feature":                &f.Fields.Feature,
+		"Roadmap":                &f.Fields.Roadmap,
+		"Team responsible":       &f.Fields.TeamResponsible,
+		"Plan":                   &f.Fields.Plan,
+		"Feature flag":           &f.Fields.FeatureFlag,
+		"Entitlements":           &f.Fields.Entitlements,
+		"External documentation": &f.Fields.ExternalDocumentation,
+	}
+	for name, value := range fields {
+		if v, ok := translated(name); ok {
+			*value = v
+		}
+	}
+
+	// Copy the extra fields so the original feature is left untouched.
+	extra := make(map[string]string, len(f.Extra))
+	for name, value := range f.Extra {
+		if v, ok := translated(name); ok {
+			value = v
+		}
+		extra[name] = value
+	}
+	f.Extra = extra
+
+	return f
+}
@@ -0,0 +1,67 @@
+package response
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLocalizedResults(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature":    "Single sign-on",
+		"Feature_fr": "Authentification unique",
+		"Roadmap":    "Available now",
+		"Roadmap_de": "Jetzt verfügbar",
+		"Plan":       "Enterprise",
+	}})
+
+	tests := []struct {
+		name        string
+		language    string
+		query       string
+		wantTitle   string
+		wantRoadmap string
+	}{
+		{"default fields", "", "sso", "Single sign-on", "Available now"},
+		{"query flag", "", "sso --lang=fr", "Authentification unique", "Available now"},
+		{"query flag is case-insensitive", "", "sso --LANG=FR", "Authentification unique", "Available now"},
+		{"configured language", "de", "sso", "Single sign-on", "Jetzt verfügbar"},
+		{"query flag overrides the configured language", "de", "sso --lang=fr", "Authentification unique", "Available now"},
+		{"language without translations", "es", "sso", "Single sign-on", "Available now"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"DISPLAY_LANGUAGE": tt.language, "SLACK_COMPACT_FIELDS": "", "SLACK_COLLAPSE_FIELDS": ""})()
+			search := parseQuery(tt.query)
+			if got := search.text(); got != "sso" {
+				t.Errorf("searched %q, want the flag left out", got)
+			}
+			res, err := buildSlackResponse(f, search)
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			var found bool
+			for _, a := range res.Attachments {
+				if a.TitleLink == "" {
+					continue
+				}
+				found = true
+				if a.Title != tt.wantTitle {
+					t.Errorf("title = %q, want %q", a.Title, tt.wantTitle)
+				}
+				if len(a.Fields) != 1 || !strings.Contains(a.Fields[0].Value, tt.wantRoadmap) || !strings.Contains(a.Fields[0].Value, "Enterprise") {
+					t.Errorf("fields = %+v, want the roadmap %q and the untranslated plan", a.Fields, tt.wantRoadmap)
+				}
+			}
+			if !found {
+				t.Error("no feature attachment")
+			}
+			if got := requestFields(search) == nil; got != (search.language() != "") {
+				t.Errorf("requestFields() = %v, want every field requested only when showing another language", requestFields(search))
+			}
+		})
+	}
+
+	if f[0].Fields.Feature != "Single sign-on" || f[0].Extra["Feature_fr"] != "Authentification unique" {
+		t.Errorf("feature changed to %+v, want it left untouched", f[0])
+	}
+}
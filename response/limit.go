@@ -0,0 +1,35 @@
+package response
+
+import (
+	"github.com/smfsh/airtable-go"
+)
+
+// Slots limiting how many Airtable queries run at once across every
+// request served by this instance. A nil channel means there is no limit.
+var airtableSlots chan struct{}
+
+// Struct for a recordLister that waits for a free slot before each query
+// is sent to Airtable, so bursts of searches don't exceed Airtable's rate
+// limits.
+type limitedLister struct {
+	lister recordLister
+	slots  chan struct{}
+}
+
+// Function to wrap a recordLister so that it respects the configured
+// limit on concurrent Airtable queries.
+func limitLister(l recordLister) recordLister {
+	if airtableSlots == nil {
+		return l
+	}
+	return &limitedLister{lister: l, slots: airtableSlots}
+}
+
+// Function to list records once a slot is free, releasing the slot again
+// when the query is finished.
+func (l *limitedLister) ListRecords(tableName string, recordsHolder interface{}, listParams ...airtable.ListParameters) error {
+	l.slots <- struct{}{}
+	defer func() { <-l.slots }()
+
+	return l.lister.ListRecords(tableName, recordsHolder, listParams...)
+}
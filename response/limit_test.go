@@ -0,0 +1,83 @@
+package response
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Struct for a recordLister that blocks every query until it is released,
+// keeping track of the most queries that were in flight at once.
+type blockingLister struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	started  chan struct{}
+	release  chan struct{}
+}
+
+// Function to list nothing once the query is released, recording how
+// many queries were running alongside it.
+func (l *blockingLister) ListRecords(tableName string, recordsHolder interface{}, listParams ...airtable.ListParameters) error {
+	l.mu.Lock()
+	l.inFlight++
+	if l.inFlight > l.peak {
+		l.peak = l.inFlight
+	}
+	l.mu.Unlock()
+	l.started <- struct{}{}
+
+	<-l.release
+
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+	return nil
+}
+
+func TestConcurrentQueryLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   string
+		queries int
+		want    int
+	}{
+		{"limited", "2", 6, 2},
+		{"unlimited", "", 6, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"AIRTABLE_MAX_CONCURRENT_QUERIES": tt.limit})()
+			fake := &blockingLister{started: make(chan struct{}), release: make(chan struct{})}
+			l := limitLister(fake)
+
+			var wg sync.WaitGroup
+			for i := 0; i < tt.queries; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := l.ListRecords(airtableTableID, &[]feature{}); err != nil {
+						t.Errorf("ListRecords() error = %v", err)
+					}
+				}()
+			}
+
+			// Let each query start as soon as a slot is free, then release
+			// it so the next waiting query can take its slot.
+			for i := 0; i < tt.want; i++ {
+				<-fake.started
+			}
+			for i := tt.want; i < tt.queries; i++ {
+				fake.release <- struct{}{}
+				<-fake.started
+			}
+			close(fake.release)
+			wg.Wait()
+
+			if fake.peak != tt.want {
+				t.Errorf("peak in-flight queries = %d, want %d", fake.peak, tt.want)
+			}
+		})
+	}
+}
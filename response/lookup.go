@@ -0,0 +1,45 @@
+package response
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Action sent by the anerbot-queue function when a user picks a feature
+// from the feature select menu.
+const lookupAction = "lookup"
+
+// Function to respond with the single feature picked from the feature
+// select menu. The value of the message is the feature's record ID, so
+// exactly that feature is shown even when other features share its name.
+func handleLookup(message queueMessage) error {
+	if !recordIDPattern.MatchString(message.Value) {
+		return codeErrorf(errInvalidMessage, "request %s: lookup is missing a valid feature: %q", message.RequestID, message.Value)
+	}
+	log.Printf("request %s: looking up %s", message.RequestID, message.Value)
+
+	search := searchRequest{ChannelID: message.ChannelID}
+	features, err := fetchFeaturesByID([]string{message.Value}, requestFields(search))
+	if err != nil {
+		ce := airtableError(message.RequestID, err)
+		sendFailureMessage(message.ResponseUrl, ce.Code)
+		return ce
+	}
+
+	// Show the feature as the exact match of a search for its name, so
+	// the results read the same as if the user had searched for it.
+	var atr []feature
+	if f, ok := features[message.Value]; ok {
+		atr = append(atr, f)
+		search = parseQuery(fmt.Sprintf(`"%s"`, strings.Replace(f.Fields.Feature, `"`, "", -1)))
+		search.ChannelID = message.ChannelID
+	}
+
+	res, err := buildSlackResponse(atr, search)
+	if err != nil {
+		sendFailureMessage(message.ResponseUrl, errRender)
+		return codeErrorf(errRender, "request %s: unable to build slack response: %v", message.RequestID, err)
+	}
+	return postResults(message, res, len(atr))
+}
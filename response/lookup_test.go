@@ -0,0 +1,63 @@
+package response
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		notWant string
+		wantErr bool
+	}{
+		{"shows the feature picked", "recSso00000000002", "Scoped to admins", "Scoped to everyone", false},
+		{"rejects values that aren't record IDs", "Single sign-on", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newFakeAirtable(map[string]map[string]interface{}{
+				"recSso00000000001": {"Feature": "Single sign-on", "Roadmap": "Scoped to everyone"},
+				"recSso00000000002": {"Feature": "Single sign-on", "Roadmap": "Scoped to admins"},
+			})
+			defer useFakeAirtable(a)()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			err := handleLookup(queueMessage{ResponseUrl: slack.URL, Action: lookupAction, Value: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handleLookup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			text := slack.text()
+			if !strings.Contains(text, tt.want) || strings.Contains(text, tt.notWant) {
+				t.Errorf("posted %s, want %q without %q", text, tt.want, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestResponseRoutesLookups(t *testing.T) {
+	a := newFakeAirtable(map[string]map[string]interface{}{
+		"recSso00000000001": {"Feature": "Single sign-on"},
+		"recBill0000000000": {"Feature": "Billing"},
+	})
+	defer useFakeAirtable(a)()
+	slack := newFakeSlack()
+	defer slack.Close()
+
+	if err := respond(t, queueMessage{ResponseUrl: slack.URL, Action: lookupAction, Value: "recBill0000000000"}); err != nil {
+		t.Fatalf("Response() error = %v", err)
+	}
+	if len(a.queries) != 1 || a.queries[0].FilterByFormula != "OR(RECORD_ID() = 'recBill0000000000')" {
+		t.Errorf("queries = %+v, want the picked record looked up", a.queries)
+	}
+	if text := slack.text(); !strings.Contains(text, "Billing") || strings.Contains(text, "Single sign-on") {
+		t.Errorf("posted %s, want only the picked feature", text)
+	}
+}
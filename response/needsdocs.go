@@ -0,0 +1,60 @@
+package response
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Action sent by the anerbot-queue function when an admin asks for the
+// report of features missing documentation.
+const needsDocsAction = "needs_docs"
+
+// Query run for the report of features missing documentation. It is the
+// inverse of the documented filter, and brief so the report is a list of
+// feature names. Sharing the report, or viewing it in Airtable, runs the
+// same query.
+const needsDocsQuery = briefKeyword + " " + documentedFilter + ":false"
+
+// Function to handle the report of features missing documentation asked
+// for by an admin, listing every feature in the view without a link to
+// its external documentation for the docs team to work through.
+func handleNeedsDocs(message queueMessage) error {
+	log.Printf("request %s: reporting features missing documentation", message.RequestID)
+	search := parseQuery(needsDocsQuery)
+	search.ChannelID = message.ChannelID
+	search.Page = message.Page
+	search.Report = needsDocsAction
+
+	atr, err := queryAirtable(search)
+	if err != nil {
+		ce := airtableError(message.RequestID, err)
+		sendFailureMessage(message.ResponseUrl, ce.Code)
+		return ce
+	}
+
+	if len(atr) == 0 {
+		res := &slackResponse{
+			ReplaceOriginal: strconv.FormatBool(true),
+			ResponseType:    responseEphemeral,
+			Text:            styleHeader("Every feature has documentation! :tada:"),
+		}
+		if err := postToSlack(message.ResponseUrl, res); err != nil {
+			return codeErrorf(errSlack, "request %s: %v", message.RequestID, err)
+		}
+		return nil
+	}
+
+	res, err := buildSlackResponse(atr, search)
+	if err != nil {
+		sendFailureMessage(message.ResponseUrl, errRender)
+		return codeErrorf(errRender, "request %s: unable to build slack response: %v", message.RequestID, err)
+	}
+
+	// The report is only for the admin who asked for it unless they share
+	// it. What the report is about goes after the header rather than in
+	// place of it, so the count and page of the results are kept.
+	res.ResponseType = responseEphemeral
+	res.Text += "\n" + styleHeader(fmt.Sprintf("%s missing documentation. Click on any of them to add it.", plural(len(atr), "feature")))
+	return postResults(message, res, len(atr))
+}
@@ -0,0 +1,131 @@
+package response
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNeedsDocsKeepsHeader(t *testing.T) {
+	defer func(p int) { pageSize = p }(pageSize)
+
+	tests := []struct {
+		name     string
+		pageSize int
+		want     []string
+	}{
+		{"single page", 0, []string{"Found 3 items!", "3 features missing documentation."}},
+		{"several pages", 2, []string{"Showing page 1 of 2.", "3 features missing documentation."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pageSize = tt.pageSize
+			a := newFakeAirtable(map[string]map[string]interface{}{
+				"recAudit000000000": {"Feature": "Audit log", "External documentation": ""},
+				"recBill0000000000": {"Feature": "Billing", "External documentation": ""},
+				"recSso00000000001": {"Feature": "Single sign-on", "External documentation": ""},
+				"recSso00000000002": {"Feature": "SCIM", "External documentation": "https://docs.example.com/scim"},
+			})
+			defer useFakeAirtable(a)()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			if err := handleNeedsDocs(queueMessage{ResponseUrl: slack.URL, RequestID: "test", Action: needsDocsAction}); err != nil {
+				t.Fatalf("handleNeedsDocs() error = %v", err)
+			}
+			posted := slack.posted()
+			if len(posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(posted))
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(posted[0].Text, w) {
+					t.Errorf("header = %q, want it to contain %q", posted[0].Text, w)
+				}
+			}
+		})
+	}
+}
+
+func TestNeedsDocsReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  map[string]map[string]interface{}
+		want     []string
+		wantText string
+	}{
+		{
+			"only undocumented features",
+			map[string]map[string]interface{}{
+				"recAudit000000000": {"Feature": "Audit log", "External documentation": ""},
+				"recSso00000000002": {"Feature": "SCIM", "External documentation": "https://docs.example.com/scim"},
+			},
+			[]string{"Audit log"},
+			"1 feature missing documentation.",
+		},
+		{
+			"everything documented",
+			map[string]map[string]interface{}{
+				"recSso00000000002": {"Feature": "SCIM", "External documentation": "https://docs.example.com/scim"},
+			},
+			nil,
+			"Every feature has documentation! :tada:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"SLACK_PAGE_SIZE": "", "QUERY_CACHE_TTL": ""})()
+			a := newFakeAirtable(tt.records)
+			defer useFakeAirtable(a)()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			if err := handleNeedsDocs(queueMessage{ResponseUrl: slack.URL, RequestID: "test", Action: needsDocsAction}); err != nil {
+				t.Fatalf("handleNeedsDocs() error = %v", err)
+			}
+			if len(a.queries) != 1 || a.queries[0].FilterByFormula != "{External documentation} = ''" {
+				t.Errorf("queries = %+v, want a single query for features without documentation", a.queries)
+			}
+			posted := slack.posted()
+			if len(posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(posted))
+			}
+			if !strings.Contains(posted[0].Text, tt.wantText) {
+				t.Errorf("header = %q, want it to contain %q", posted[0].Text, tt.wantText)
+			}
+			var got []string
+			for _, at := range posted[0].Attachments {
+				if i := strings.Index(at.Fallback, ": https://"); i > 0 {
+					got = append(got, at.Fallback[:i])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reported %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsDocsPages(t *testing.T) {
+	defer useEnv(t, map[string]string{"SLACK_PAGE_SIZE": "2", "QUERY_CACHE_TTL": ""})()
+	defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{
+		"recAudit000000000": {"Feature": "Audit log", "External documentation": ""},
+		"recBill0000000000": {"Feature": "Billing", "External documentation": ""},
+		"recSso00000000001": {"Feature": "Single sign-on", "External documentation": ""},
+	}))()
+	slack := newFakeSlack()
+	defer slack.Close()
+
+	if err := respond(t, queueMessage{ResponseUrl: slack.URL, RequestID: "test", Action: needsDocsAction, Page: 2}); err != nil {
+		t.Fatalf("Response() error = %v", err)
+	}
+	posted := slack.posted()
+	if len(posted) != 1 || !strings.Contains(posted[0].Text, "Showing page 2 of 2.") || !strings.Contains(posted[0].Text, "3 features missing documentation.") {
+		t.Fatalf("posted %+v, want the second page of the report", posted)
+	}
+	for _, want := range []string{`\"report\":\"needs_docs\"`, goToReportPageBlockPrefix + needsDocsAction} {
+		if !strings.Contains(slack.text(), want) {
+			t.Errorf("posted %s, want the pager to run the report again with %s", slack.text(), want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// Prefixes of the block ID of the "go to page" input. The rest of the
+// block ID is the query being paged through, or the action of the admin
+// report being paged through, since Slack only sends back what was typed
+// into the input.
+const (
+	goToPageBlockPrefix       = "go_to_page:"
+	goToReportPageBlockPrefix = "go_to_report_page:"
+)
+
+// Longest block ID Slack accepts. The "go to page" input is left out when
+// the query is too long to fit in its block ID.
+const maxBlockIDLength = 255
+
+// Struct for the value of a "previous" or "next" page button, identifying
+// the search or admin report being paged through and the page to show.
+type pageValue struct {
+	Query  string `json:"query"`
+	Page   int    `json:"page"`
+	Report string `json:"report,omitempty"`
+}
+
+// Function to count the pages needed to show a number of features at the
+// configured page size. Results always fit on a single page when paging
+// is turned off.
+func pageCount(n int) int {
+	if pageSize <= 0 || n <= pageSize {
+		return 1
+	}
+	return (n + pageSize - 1) / pageSize
+}
+
+// Function to clamp a requested page to the pages available, so a page
+// before the first shows the first page and a page after the last shows
+// the last page.
+func clampPage(page, pages int) int {
+	if page < 1 {
+		return 1
+	}
+	if page > pages {
+		return pages
+	}
+	return page
+}
+
+// Function to return the features shown on a page, along with the number
+// of features on the pages before it.
+func pageFeatures(f []feature, page int) ([]feature, int) {
+	if pageSize <= 0 {
+		return f, 0
+	}
+	start := (page - 1) * pageSize
+	if start >= len(f) {
+		return nil, start
+	}
+	end := start + pageSize
+	if end > len(f) {
+		end = len(f)
+	}
+	return f[start:end], start
+}
+
+// Function to build the attachment for moving between the pages of
+// results, with buttons for the previous and next pages and an input to
+// jump straight to a page by its number.
+func paginationAttachment(search searchRequest, page, pages int) attachment {
+	var buttons []interface{}
+	if page > 1 {
+		buttons = append(buttons, pageElement(search, "Previous", page-1))
+	}
+	if page < pages {
+		buttons = append(buttons, pageElement(search, "Next", page+1))
+	}
+
+	a := attachment{
+		Fallback: fmt.Sprintf("Page %d of %d", page, pages),
+		Blocks:   []block{{Type: "actions", Elements: buttons}},
+	}
+	blockID := goToPageBlockPrefix + search.Query
+	if search.Report != "" {
+		blockID = goToReportPageBlockPrefix + search.Report
+	}
+	if len(blockID) <= maxBlockIDLength {
+		a.Blocks = append(a.Blocks, block{
+			Type:           "input",
+			BlockID:        blockID,
+			DispatchAction: true,
+			Label:          &textObject{Type: "plain_text", Text: fmt.Sprintf("Go to page (1-%d)", pages)},
+			Element: blockElement{
+				Type:        "plain_text_input",
+				ActionID:    goToPageActionID,
+				Placeholder: &textObject{Type: "plain_text", Text: "Page number"},
+			},
+		})
+	}
+	return a
+}
+
+// Function to build a button showing another page of the results of a
+// search. The value of the button is the query, the admin report the
+// results belong to if any, and the page to show.
+func pageElement(search searchRequest, text string, page int) blockElement {
+	value, err := json.Marshal(pageValue{Query: search.Query, Page: page, Report: search.Report})
+	if err != nil {
+		log.Printf("json.Marshal: %v", err)
+	}
+	return blockElement{
+		Type:     "button",
+		ActionID: pageActionID,
+		Text:     &textObject{Type: "plain_text", Text: text},
+		Value:    string(value),
+	}
+}
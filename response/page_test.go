@@ -0,0 +1,168 @@
+package response
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClampPage(t *testing.T) {
+	tests := []struct {
+		page, pages int
+		want        int
+	}{
+		{1, 3, 1},
+		{2, 3, 2},
+		{0, 3, 1},
+		{-4, 3, 1},
+		{4, 3, 3},
+		{99, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := clampPage(tt.page, tt.pages); got != tt.want {
+			t.Errorf("clampPage(%d, %d) = %d, want %d", tt.page, tt.pages, got, tt.want)
+		}
+	}
+}
+
+func TestPageFeatures(t *testing.T) {
+	defer func(p int) { pageSize = p }(pageSize)
+	var f []feature
+	for i := 0; i < 5; i++ {
+		var v feature
+		v.Fields.Feature = fmt.Sprintf("Feature %d", i)
+		f = append(f, v)
+	}
+
+	tests := []struct {
+		name      string
+		pageSize  int
+		page      int
+		want      []string
+		wantSkip  int
+		wantPages int
+	}{
+		{"paging off", 0, 1, []string{"Feature 0", "Feature 1", "Feature 2", "Feature 3", "Feature 4"}, 0, 1},
+		{"first page", 2, 1, []string{"Feature 0", "Feature 1"}, 0, 3},
+		{"middle page", 2, 2, []string{"Feature 2", "Feature 3"}, 2, 3},
+		{"last page", 2, 3, []string{"Feature 4"}, 4, 3},
+		{"past the last page", 2, 4, nil, 6, 3},
+		{"everything on one page", 5, 1, []string{"Feature 0", "Feature 1", "Feature 2", "Feature 3", "Feature 4"}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pageSize = tt.pageSize
+			got, skip := pageFeatures(f, tt.page)
+			if names := featureNamesOf(got); !reflect.DeepEqual(names, tt.want) || skip != tt.wantSkip {
+				t.Errorf("pageFeatures() = %q, %d, want %q, %d", names, skip, tt.want, tt.wantSkip)
+			}
+			if pages := pageCount(len(f)); pages != tt.wantPages {
+				t.Errorf("pageCount() = %d, want %d", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestPaginationAttachment(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		page, pages int
+		wantButtons []string
+		wantInput   bool
+	}{
+		{"first page", "sso", 1, 3, []string{"Next"}, true},
+		{"middle page", "sso", 2, 3, []string{"Previous", "Next"}, true},
+		{"last page", "sso", 3, 3, []string{"Previous"}, true},
+		{"query too long for the input", strings.Repeat("a", maxBlockIDLength), 1, 3, []string{"Next"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := paginationAttachment(searchRequest{Query: tt.query}, tt.page, tt.pages)
+			var buttons []string
+			for _, e := range a.Blocks[0].Elements {
+				buttons = append(buttons, e.(blockElement).Text.Text)
+			}
+			if !reflect.DeepEqual(buttons, tt.wantButtons) {
+				t.Errorf("buttons = %q, want %q", buttons, tt.wantButtons)
+			}
+			if got := len(a.Blocks) == 2; got != tt.wantInput {
+				t.Fatalf("go to page input shown = %v, want %v", got, tt.wantInput)
+			}
+			if !tt.wantInput {
+				return
+			}
+			input := a.Blocks[1]
+			element := input.Element.(blockElement)
+			if input.BlockID != goToPageBlockPrefix+tt.query || element.ActionID != goToPageActionID {
+				t.Errorf("input %s %s, want %s%s %s", input.BlockID, element.ActionID, goToPageBlockPrefix, tt.query, goToPageActionID)
+			}
+			if want := fmt.Sprintf("Go to page (1-%d)", tt.pages); input.Label.Text != want {
+				t.Errorf("label = %q, want %q", input.Label.Text, want)
+			}
+		})
+	}
+}
+
+func TestOutOfRangePage(t *testing.T) {
+	tests := []struct {
+		name string
+		page int
+		want string
+	}{
+		{"before the first page", -2, "Showing page 1 of 2."},
+		{"after the last page", 9, "Showing page 2 of 2."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"SLACK_PAGE_SIZE": "2", "QUERY_CACHE_TTL": ""})()
+			defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{
+				"recSso00000000001": {"Feature": "SSO"},
+				"recSso00000000002": {"Feature": "SSO for teams"},
+				"recSso00000000003": {"Feature": "Enforced SSO"},
+			}))()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			if err := respond(t, queueMessage{Query: "sso", Page: tt.page, ResponseUrl: slack.URL, RequestID: "test"}); err != nil {
+				t.Fatal(err)
+			}
+			posted := slack.posted()
+			if len(posted) != 1 || !strings.Contains(posted[0].Text, tt.want) {
+				t.Fatalf("posted %+v, want a header containing %q", posted, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestSectionsArentPaged(t *testing.T) {
+	defer useEnv(t, map[string]string{"SLACK_PAGE_SIZE": "2", "QUERY_CACHE_TTL": ""})()
+	defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{
+		"recSso00000000001": {"Feature": "SSO"},
+		"recSso00000000002": {"Feature": "SSO for teams"},
+		"recSso00000000003": {"Feature": "Enforced SSO"},
+	}))()
+	slack := newFakeSlack()
+	defer slack.Close()
+
+	if err := respondWithDigest(queueMessage{Query: "sso; billing", ResponseUrl: slack.URL, RequestID: "test"}, splitDigest("sso; billing")); err != nil {
+		t.Fatal(err)
+	}
+	posted := slack.posted()
+	if len(posted) != 1 {
+		t.Fatalf("posted %d messages, want 1", len(posted))
+	}
+	var features int
+	for _, at := range posted[0].Attachments {
+		if strings.Contains(at.Title, "SSO") && !strings.HasPrefix(at.Title, `"`) {
+			features++
+		}
+	}
+	if strings.Contains(posted[0].Text, "Showing page") {
+		t.Errorf("text = %q, want the digest shown on a single page", posted[0].Text)
+	}
+	if features != 6 {
+		t.Errorf("showed %d features, want all 3 in each section", features)
+	}
+}
@@ -0,0 +1,197 @@
+package response
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Maximum number of features looked up in a single Airtable query while
+// polling, keeping each formula a reasonable length.
+const maxPollBatch = 50
+
+// Struct for the response from Slack's chat.postMessage method.
+type postMessageResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// Entry point for GCF anerbot-poll function. Cloud Scheduler calls this
+// periodically to look for changes to the roadmap of every feature users
+// have subscribed to, sending each subscriber a direct message about the
+// features that changed. Only requests bearing the poll secret are
+// answered, so nobody else can make Anerbot message subscribers.
+func Poll(w http.ResponseWriter, r *http.Request) {
+	if !verifyBearer(r, pollSecret) {
+		http.Error(w, "Unable to validate request", 401)
+		return
+	}
+
+	sent, err := pollSubscriptions()
+	if err != nil {
+		log.Printf("unable to poll subscriptions: %v", err)
+		http.Error(w, "Failed to poll subscriptions", 502)
+		return
+	}
+	log.Printf("sent %d subscription notifications", sent)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Function to check that a request bears the secret passed in as a bearer
+// token in its Authorization header. Every request is rejected when no
+// secret has been configured.
+func verifyBearer(r *http.Request, secret string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if secret == "" || token == r.Header.Get("Authorization") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// Function to look up the current roadmap of every subscribed feature and
+// notify the subscribers of any that changed. The new roadmap is recorded
+// once a subscriber has been told about it, so they are only told once.
+// Features that are no longer in the view are left alone. Returns the
+// number of notifications sent.
+func pollSubscriptions() (int, error) {
+	if subscriptions == nil {
+		return 0, fmt.Errorf("SUBSCRIPTIONS_TABLE is not set")
+	}
+	subs, err := subscriptions.Subscriptions()
+	if err != nil {
+		return 0, fmt.Errorf("unable to list subscriptions: %v", err)
+	}
+	if len(subs) == 0 {
+		return 0, nil
+	}
+
+	var ids []string
+	for _, s := range subs {
+		if !containsString(ids, s.FeatureID) {
+			ids = append(ids, s.FeatureID)
+		}
+	}
+	current, err := fetchFeaturesByID(ids, []string{"Feature", "Roadmap"})
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	for _, s := range subs {
+		f, ok := current[s.FeatureID]
+		if !ok || f.Fields.Roadmap == s.Roadmap {
+			continue
+		}
+		if err := notifyUser(s.UserID, changeText(s, f)); err != nil {
+			log.Printf("unable to notify %s about %s: %v", s.UserID, s.FeatureID, err)
+			continue
+		}
+		sent++
+
+		s.Feature, s.Roadmap = f.Fields.Feature, f.Fields.Roadmap
+		if err := subscriptions.Subscribe(s); err != nil {
+			log.Printf("unable to update subscription of %s to %s: %v", s.UserID, s.FeatureID, err)
+		}
+	}
+	return sent, nil
+}
+
+// Function to fetch the fields passed in of features by their record ID,
+// in batches, keyed by their record ID.
+func fetchFeaturesByID(ids []string, fields []string) (map[string]feature, error) {
+	client, err := newLister()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new airtable client: %v", err)
+	}
+
+	features := make(map[string]feature)
+	for start := 0; start < len(ids); start += maxPollBatch {
+		end := start + maxPollBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var batch []feature
+		err = client.ListRecords(airtableTableID, &batch, airtable.ListParameters{
+			CellFormat:      "string",
+			Fields:          fields,
+			FilterByFormula: recordIDFormula(ids[start:end]),
+			TimeZone:        airtableTimeZone,
+			UserLocale:      "en-US",
+			View:            airtableViewID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range batch {
+			features[f.AirtableID] = f
+		}
+	}
+	return features, nil
+}
+
+// Function to build a formula matching the records with any of the IDs
+// passed in.
+func recordIDFormula(ids []string) string {
+	var statements []string
+	for _, id := range ids {
+		statements = append(statements, fmt.Sprintf("RECORD_ID() = '%s'", formulaString(id)))
+	}
+	return fmt.Sprintf("OR(%s)", strings.Join(statements, ", "))
+}
+
+// Function to render the message telling a subscriber that the roadmap of
+// a feature changed.
+func changeText(s subscription, f feature) string {
+	from, to := s.Roadmap, f.Fields.Roadmap
+	if from == "" {
+		from = "none"
+	}
+	if to == "" {
+		to = "none"
+	}
+	return fmt.Sprintf(":bell: The roadmap for <%s|%s> changed from *%s* to *%s*", featureLink(f.AirtableID), f.Fields.Feature, from, to)
+}
+
+// Function used to send a direct message to a Slack user, posting as the
+// Slack app with its bot token.
+var notifyUser = func(userID, text string) error {
+	if slackBotToken == "" {
+		return fmt.Errorf("SLACK_BOT_TOKEN is not set")
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"channel": userID,
+		"text":    text,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to convert slack message to JSON: %v", err)
+	}
+	req, err := http.NewRequest("POST", "https://slack.com/api/chat.postMessage", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("unable to build new HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+slackBotToken)
+
+	resp, err := newHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send message to Slack: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var pm postMessageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pm); err != nil {
+		return fmt.Errorf("unable to read response from Slack: %v", err)
+	}
+	if !pm.OK {
+		return fmt.Errorf("slack rejected the message: %s", pm.Error)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPollRequiresSecret(t *testing.T) {
+	defer func(s string, st subscriptionStore) { pollSecret, subscriptions = s, st }(pollSecret, subscriptions)
+	subscriptions = airtableSubscriptionStore{table: "Subscriptions"}
+	defer useFakeAirtable(newFakeAirtable(nil))()
+
+	tests := []struct {
+		name   string
+		secret string
+		auth   string
+		want   int
+	}{
+		{"no secret configured", "", "Bearer ", http.StatusUnauthorized},
+		{"missing header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong secret", "s3cret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret without bearer scheme", "s3cret", "s3cret", http.StatusUnauthorized},
+		{"right secret", "s3cret", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pollSecret = tt.secret
+			r := httptest.NewRequest("POST", "/", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			Poll(w, r)
+			if w.Code != tt.want {
+				t.Errorf("Poll() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollSubscriptionsReadsTheStore(t *testing.T) {
+	defer func(st subscriptionStore, n func(string, string) error) { subscriptions, notifyUser = st, n }(subscriptions, notifyUser)
+	a := newFakeAirtable(map[string]map[string]interface{}{
+		"recSso00000000001": {"Feature": "SSO", "Roadmap": "Q2"},
+		"recBill0000000001": {"Feature": "Billing", "Roadmap": "Q1"},
+	})
+	defer useFakeAirtable(a)()
+	store := airtableSubscriptionStore{table: "Subscriptions"}
+	subscriptions = store
+	for _, s := range []subscription{
+		{UserID: "U1", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"},
+		{UserID: "U2", FeatureID: "recBill0000000001", Feature: "Billing", Roadmap: "Q1"},
+		{UserID: "U3", FeatureID: "recGone0000000001", Feature: "Gone", Roadmap: "Q1"},
+	} {
+		if err := store.Subscribe(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := make(map[string]string)
+	notifyUser = func(userID, text string) error {
+		sent[userID] = text
+		return nil
+	}
+
+	for _, run := range []struct {
+		name string
+		want int
+	}{
+		{"first poll notifies the changed feature", 1},
+		{"second poll has nothing new", 0},
+	} {
+		sent = make(map[string]string)
+		n, err := pollSubscriptions()
+		if err != nil {
+			t.Fatalf("%s: pollSubscriptions() error = %v", run.name, err)
+		}
+		if n != run.want || len(sent) != run.want {
+			t.Errorf("%s: sent %d notifications %v, want %d", run.name, n, sent, run.want)
+		}
+		if run.want > 0 && !strings.Contains(sent["U1"], "from *Q1* to *Q2*") {
+			t.Errorf("%s: U1 was told %q", run.name, sent["U1"])
+		}
+	}
+}
+
+func TestPollSubscriptionsWithoutStore(t *testing.T) {
+	defer func(st subscriptionStore) { subscriptions = st }(subscriptions)
+	subscriptions = nil
+	if _, err := pollSubscriptions(); err == nil {
+		t.Error("pollSubscriptions() without a store succeeded, want an error")
+	}
+}
+
+func TestRecordIDFormula(t *testing.T) {
+	tests := []struct {
+		ids  []string
+		want string
+	}{
+		{[]string{"recSso00000000001"}, `OR(RECORD_ID() = 'recSso00000000001')`},
+		{[]string{"recSso00000000001", "recBill0000000001"}, `OR(RECORD_ID() = 'recSso00000000001', RECORD_ID() = 'recBill0000000001')`},
+		{[]string{"rec') OR TRUE() OR ('"}, `OR(RECORD_ID() = 'rec\') OR TRUE() OR (\'')`},
+	}
+	for _, tt := range tests {
+		if got := recordIDFormula(tt.ids); got != tt.want {
+			t.Errorf("recordIDFormula(%q) = %s, want %s", tt.ids, got, tt.want)
+		}
+	}
+}
+
+func TestFetchFeaturesByIDBatches(t *testing.T) {
+	records := make(map[string]map[string]interface{})
+	var ids []string
+	for i := 0; i < 2*maxPollBatch+1; i++ {
+		id := fmt.Sprintf("rec%014d", i)
+		records[id] = map[string]interface{}{"Feature": fmt.Sprintf("Feature %d", i)}
+		ids = append(ids, id)
+	}
+	a := newFakeAirtable(records)
+	defer useFakeAirtable(a)()
+
+	got, err := fetchFeaturesByID(ids, []string{"Feature", "Roadmap"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(ids) {
+		t.Errorf("fetched %d features, want %d", len(got), len(ids))
+	}
+	if len(a.queries) != 3 {
+		t.Errorf("ran %d queries, want 3 batches of at most %d", len(a.queries), maxPollBatch)
+	}
+}
+
+func TestChangeText(t *testing.T) {
+	defer func(tbl, v string) { airtableTableID, airtableViewID = tbl, v }(airtableTableID, airtableViewID)
+	airtableTableID, airtableViewID = "tblFeatures", "viwAll"
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{"moved", "Q1", "Q2", ":bell: The roadmap for <https://airtable.com/tblFeatures/viwAll/recSso00000000001|SSO> changed from *Q1* to *Q2*"},
+		{"added to the roadmap", "", "Q3", ":bell: The roadmap for <https://airtable.com/tblFeatures/viwAll/recSso00000000001|SSO> changed from *none* to *Q3*"},
+		{"taken off the roadmap", "Q3", "", ":bell: The roadmap for <https://airtable.com/tblFeatures/viwAll/recSso00000000001|SSO> changed from *Q3* to *none*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO", "Roadmap": tt.to}})[0]
+			if got := changeText(subscription{UserID: "U1", FeatureID: f.AirtableID, Feature: "SSO", Roadmap: tt.from}, f); got != tt.want {
+				t.Errorf("changeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollRetriesFailedNotifications(t *testing.T) {
+	defer func(st subscriptionStore, n func(string, string) error) { subscriptions, notifyUser = st, n }(subscriptions, notifyUser)
+	defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{
+		"recSso00000000001": {"Feature": "SSO", "Roadmap": "Q2"},
+	}))()
+	store := airtableSubscriptionStore{table: "Subscriptions"}
+	subscriptions = store
+	if err := store.Subscribe(subscription{UserID: "U1", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, run := range []struct {
+		name    string
+		failing bool
+		want    int
+	}{
+		{"notification fails", true, 0},
+		{"next poll tries again", false, 1},
+	} {
+		notifyUser = func(userID, text string) error {
+			if run.failing {
+				return errors.New("slack is down")
+			}
+			return nil
+		}
+		n, err := pollSubscriptions()
+		if err != nil {
+			t.Fatalf("%s: pollSubscriptions() error = %v", run.name, err)
+		}
+		if n != run.want {
+			t.Errorf("%s: sent %d notifications, want %d", run.name, n, run.want)
+		}
+	}
+}
@@ -0,0 +1,552 @@
+package response
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Flags that can be added anywhere in a query to change how the search
+// is performed. Flags are removed from the query before it is searched.
+const (
+	wholeWordFlag = "--word"
+	debugFlag     = "--debug"
+)
+
+// Keywords that can start a query to change what is returned for the
+// rest of the query. Keywords are removed from the query before it is
+// searched.
+const (
+	breakdownKeyword = "breakdown"
+	briefKeyword     = "brief"
+	mineKeyword      = "mine"
+)
+
+// Filters that can be added anywhere in a query to narrow down the
+// results regardless of the terms searched, such as "flagged:true".
+const (
+	flaggedFilter    = "flagged"
+	documentedFilter = "documented"
+	quarterFilter    = "quarter"
+)
+
+// Pattern matching a token that looks like it was meant to be scoped to a
+// field, such as "teams:billing", as opposed to a URL or a time.
+var scopePattern = regexp.MustCompile(`^([A-Za-z][A-Za-z_-]*):(?:[^/]|$)`)
+
+// Pattern matching the value of a quarter filter, which is the quarter
+// optionally followed by its year, such as "Q3" or "Q3-2024".
+var quarterPattern = regexp.MustCompile(`(?i)^q([1-4])(?:[-/]?(\d{4}))?$`)
+
+// Operators that can be placed between words in a query. Operators must
+// be uppercase so that everyday words like "and" can still be searched.
+// A query without any operator is searched as a single phrase.
+const (
+	operatorAnd = "AND"
+	operatorOr  = "OR"
+)
+
+// Aliases that can be used to scope a term in a query to a single field,
+// such as "plan:enterprise", mapped to the field name in Airtable.
+var fieldAliases = map[string]string{
+	"feature":      "Feature",
+	"roadmap":      "Roadmap",
+	"team":         "Team responsible",
+	"plan":         "Plan",
+	"flag":         "Feature flag",
+	"entitlements": "Entitlements",
+	"docs":         "External documentation",
+}
+
+// Struct to contain a search request after the raw query text from the
+// user has been parsed. Query keeps the raw query text itself, the
+// keyword is set when the query started with one, and the channel ID is
+// that of the channel the search was requested in. Flagged and
+// Documented are nil unless the query filtered on whether a feature has
+// a feature flag or documentation. Quarter is set when the query filtered
+// on the roadmap quarter, such as "Q3" or "Q3 2024". Team is set to the
+// team of the user who searched when they asked for their own team's
+// features. UnknownScopes holds any field names used to scope a term that
+// aren't known, such as "teams" in "teams:billing". Elapsed is how long
+// Airtable took to answer once the search was run. Shared is set when the
+// results are being shared with the channel. Page is the page of results
+// asked for, if any. Section is set when the results are one section of a
+// digest, which adds everything around the results once for the whole
+// digest, so only the results are built. Report is the action of the
+// admin report the results belong to, if any, so that paging through them
+// runs the report again rather than an ordinary search.
+type searchRequest struct {
+	Query         string
+	Keyword       string
+	Terms         []searchTerm
+	Exclusions    []searchTerm
+	Operator      string
+	WholeWord     bool
+	Debug         bool
+	Truncated     bool
+	Flagged       *bool
+	Documented    *bool
+	Quarter       string
+	Team          string
+	UnknownScopes []string
+	ChannelID     string
+	Elapsed       time.Duration
+	Shared        bool
+	Language      string
+	Page          int
+	Section       bool
+	Report        string
+}
+
+// Struct for a single term to be searched. Terms scoped to a field are
+// only matched against that field, otherwise they are matched against
+// every field in the default scope. Quoted is set when the term was
+// wrapped in quotes.
+type searchTerm struct {
+	Text   string
+	Field  string
+	Quoted bool
+}
+
+// Struct for a single token found while splitting up the raw query text.
+// Quoted tokens are kept whole and are never treated as operators, flags
+// or exclusions.
+type queryToken struct {
+	Text   string
+	Quoted bool
+}
+
+// Function to parse the raw query text from the user into a searchRequest,
+// pulling out any flags, operators, scopes and exclusions.
+func parseQuery(text string) searchRequest {
+	req := searchRequest{Query: text}
+	var processed int
+
+	// A scope or exclusion on its own, such as `plan:` in `plan:"team
+	// plan"`, applies to the token that follows it.
+	var pendingExclude bool
+	var pendingField string
+
+	tokens := tokenizeQuery(text)
+	if len(tokens) > 1 && !tokens[0].Quoted && isKeyword(tokens[0].Text) {
+		req.Keyword = strings.ToLower(tokens[0].Text)
+		tokens = tokens[1:]
+	}
+
+	for _, t := range tokens {
+		exclude, field, value := pendingExclude, pendingField, t.Text
+		pendingExclude, pendingField = false, ""
+
+		if !t.Quoted {
+			switch {
+			case strings.ToLower(t.Text) == wholeWordFlag:
+				req.WholeWord = true
+				continue
+			case strings.ToLower(t.Text) == debugFlag:
+				req.Debug = true
+				continue
+			case strings.HasPrefix(strings.ToLower(t.Text), languageFlag):
+				if lang, ok := parseLanguageFlag(t.Text); ok {
+					req.Language = lang
+					continue
+				}
+			case t.Text == operatorAnd:
+				req.Operator = operatorAnd
+				continue
+			case t.Text == operatorOr:
+				// AND takes precedence when both operators are used.
+				if req.Operator == "" {
+					req.Operator = operatorOr
+				}
+				continue
+			}
+			if flagged, ok := parseFilter(t.Text, flaggedFilter); ok {
+				req.Flagged = &flagged
+				continue
+			}
+			if documented, ok := parseFilter(t.Text, documentedFilter); ok {
+				req.Documented = &documented
+				continue
+			}
+			if quarter, ok := parseQuarter(t.Text); ok {
+				req.Quarter = quarter
+				continue
+			}
+
+			var e bool
+			var f string
+			e, f, value = splitModifiers(t.Text)
+			exclude = exclude || e
+			if f != "" {
+				field = f
+			} else if m := scopePattern.FindStringSubmatch(value); m != nil && !isFilter(m[1]) {
+				req.UnknownScopes = append(req.UnknownScopes, strings.ToLower(m[1]))
+			}
+
+			// Punctuation around a word, such as "billing?", is rarely
+			// meant to be searched for.
+			value = strings.Trim(value, trimPunctuation)
+			if value == "" && f == "" && !e {
+				continue
+			}
+			if value == "" {
+				pendingExclude, pendingField = exclude, field
+				continue
+			}
+		}
+
+		// Stop processing words once the configured maximum is reached
+		// so huge queries don't generate huge formulas.
+		if maxQueryTokens > 0 && processed >= maxQueryTokens {
+			req.Truncated = true
+			continue
+		}
+		processed++
+
+		term := searchTerm{Text: value, Field: field, Quoted: t.Quoted}
+		if exclude {
+			req.Exclusions = append(req.Exclusions, term)
+			continue
+		}
+		req.Terms = append(req.Terms, term)
+	}
+
+	// Without an operator every remaining unscoped word is searched
+	// together as a single phrase to maintain backwards compatibility.
+	if req.Operator == "" {
+		var words []string
+		var terms []searchTerm
+		for _, t := range req.Terms {
+			if t.Field == "" {
+				words = append(words, t.Text)
+				continue
+			}
+			terms = append(terms, t)
+		}
+		if len(words) > 0 {
+			terms = append([]searchTerm{{Text: strings.Join(words, " ")}}, terms...)
+		}
+		req.Terms = terms
+	}
+
+	// Very short words required in AND mode rarely narrow the results in
+	// a useful way, so they are left out.
+	if req.Operator == operatorAnd {
+		req.Terms = dropShortTerms(req.Terms, minAndTokenLength)
+	}
+
+	return req
+}
+
+// Function to leave out terms shorter than the minimum length, keeping
+// any term that was quoted or scoped to a field since those were clearly
+// meant to be searched. The longest term is kept when every term would
+// be left out, so the search is never left without a term.
+func dropShortTerms(terms []searchTerm, minLength int) []searchTerm {
+	if minLength <= 0 || len(terms) == 0 {
+		return terms
+	}
+
+	var kept []searchTerm
+	longest := terms[0]
+	for _, t := range terms {
+		if utf8.RuneCountInString(t.Text) > utf8.RuneCountInString(longest.Text) {
+			longest = t
+		}
+		if t.Quoted || t.Field != "" || utf8.RuneCountInString(t.Text) >= minLength {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		kept = []searchTerm{longest}
+	}
+	return kept
+}
+
+// Function to check whether a word is one of the keywords a query can
+// start with.
+func isKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case breakdownKeyword, briefKeyword, mineKeyword:
+		return true
+	}
+	return false
+}
+
+// Function to scope a search to the team of the user who requested it when
+// the query started with the "mine" keyword. Users without a configured
+// team are left unscoped.
+func (r *searchRequest) scopeToUser(userID string) {
+	if r.Keyword == mineKeyword {
+		r.Team = userTeams[userID]
+	}
+}
+
+// Function to check whether a word is the name of one of the filters, so
+// a filter with a value that couldn't be understood isn't mistaken for an
+// unknown field.
+func isFilter(s string) bool {
+	switch strings.ToLower(s) {
+	case flaggedFilter, documentedFilter, quarterFilter:
+		return true
+	}
+	return false
+}
+
+// Function to parse a filter with the name passed in, such as
+// "flagged:false". Tokens that aren't that filter with a true or false
+// value are searched as normal.
+func parseFilter(s string, name string) (present bool, ok bool) {
+	i := strings.Index(s, ":")
+	if i < 0 || strings.ToLower(s[:i]) != name {
+		return false, false
+	}
+	present, err := strconv.ParseBool(s[i+1:])
+	if err != nil {
+		return false, false
+	}
+	return present, true
+}
+
+// Function to parse a quarter filter, such as "quarter:Q3" or
+// "quarter:Q3-2024", into the quarter it names, such as "Q3 2024". Tokens
+// that aren't a quarter filter with a valid quarter are searched as normal.
+func parseQuarter(s string) (string, bool) {
+	i := strings.Index(s, ":")
+	if i < 0 || strings.ToLower(s[:i]) != quarterFilter {
+		return "", false
+	}
+	m := quarterPattern.FindStringSubmatch(s[i+1:])
+	if m == nil {
+		return "", false
+	}
+	if m[2] == "" {
+		return "Q" + m[1], true
+	}
+	return fmt.Sprintf("Q%s %s", m[1], m[2]), true
+}
+
+// Function to split the exclusion and field scope modifiers off the front
+// of an unquoted token. Prefixes that aren't a known field alias are left
+// as part of the value.
+func splitModifiers(s string) (exclude bool, field string, value string) {
+	if len(s) > 1 && strings.HasPrefix(s, "-") {
+		exclude = true
+		s = s[1:]
+	}
+	if i := strings.Index(s, ":"); i > 0 {
+		if f, ok := fieldAliases[strings.ToLower(s[:i])]; ok {
+			field = f
+			s = s[i+1:]
+		}
+	}
+	return exclude, field, s
+}
+
+// Function to build the message asking the user to clarify the fields
+// their query was scoped to when any of them aren't known, listing every
+// field alias that can be used instead. No message is returned when every
+// scope was known.
+func scopeClarification(search searchRequest) string {
+	if len(search.UnknownScopes) == 0 {
+		return ""
+	}
+
+	var aliases []string
+	for alias := range fieldAliases {
+		aliases = append(aliases, "`"+alias+":`")
+	}
+	sort.Strings(aliases)
+	return fmt.Sprintf(`Anerbot doesn't know the field "%s". Try scoping your search to one of %s instead.`,
+		strings.Join(search.UnknownScopes, `" or "`), strings.Join(aliases, ", "))
+}
+
+// Function to join the text of every term in a search request, such as
+// to show the user what was searched.
+func (r searchRequest) text() string {
+	var words []string
+	for _, t := range r.Terms {
+		words = append(words, t.Text)
+	}
+	return strings.Join(words, " ")
+}
+
+// Function to find the fields searched by terms that aren't scoped to a
+// single field. Channels can be configured with their own default scope,
+// otherwise the configured search column is searched on its own, falling
+// back to searching every searchable field.
+func defaultScope(channelID string) []string {
+	if fields, ok := channelScopes[normalizeID(channelID)]; ok && len(fields) > 0 {
+		return fields
+	}
+	if searchColumn != "" {
+		return []string{searchColumn}
+	}
+	return searchFields
+}
+
+// Function to split the raw query text into tokens on whitespace. Text
+// inside double quotes is kept together as a single token. The text is
+// normalized first so accented characters typed in either composed or
+// decomposed form are searched the same way.
+func tokenizeQuery(text string) []queryToken {
+	var tokens []queryToken
+	for i, part := range strings.Split(norm.NFC.String(text), `"`) {
+		// Every odd part of the split was inside a pair of quotes.
+		if i%2 == 1 {
+			if part = strings.TrimSpace(part); part != "" {
+				tokens = append(tokens, queryToken{Text: part, Quoted: true})
+			}
+			continue
+		}
+		for _, w := range strings.Fields(part) {
+			tokens = append(tokens, queryToken{Text: w})
+		}
+	}
+	return tokens
+}
+
+// Function to build an Airtable-compatible formula that matches the
+// search request. Terms that aren't scoped to a field are matched against
+// each of the fields passed in.
+func buildFormula(req searchRequest, fields []string) string {
+	// Build a statement for each of the terms and each of the exclusions.
+	var terms []string
+	for _, t := range req.Terms {
+		terms = append(terms, termFormula(t, fields, req.WholeWord))
+	}
+	var exclusions []string
+	for _, e := range req.Exclusions {
+		exclusions = append(exclusions, fmt.Sprintf("NOT(%s)", termFormula(e, fields, req.WholeWord)))
+	}
+
+	// Combine the terms using the operator from the query. Without an
+	// operator the phrase and any scoped terms must all match. Exclusions
+	// always apply on top of whatever the terms matched.
+	var statements []string
+	switch {
+	case len(terms) == 1 || req.Operator != operatorOr:
+		statements = append(statements, terms...)
+	case len(terms) > 1:
+		statements = append(statements, fmt.Sprintf("OR(%s)", strings.Join(terms, ", ")))
+	}
+	statements = append(statements, exclusions...)
+
+	// Filters also apply on top of whatever the terms matched.
+	if req.Flagged != nil {
+		statements = append(statements, presenceFormula("Feature flag", *req.Flagged))
+	}
+	if req.Documented != nil {
+		statements = append(statements, presenceFormula("External documentation", *req.Documented))
+	}
+	if req.Quarter != "" {
+		statements = append(statements, quarterFormula(req.Quarter))
+	}
+	if req.Team != "" {
+		team := req.Team
+		if !caseSensitive {
+			team = foldCase(team)
+		}
+		statements = append(statements, matchStatement(team, "Team responsible", false))
+	}
+
+	// Nothing was left to search for once flags were removed, so make
+	// sure nothing matches rather than returning every record.
+	if len(statements) == 0 {
+		return "FALSE()"
+	}
+	if len(statements) == 1 {
+		return statements[0]
+	}
+
+	return fmt.Sprintf("AND(%s)", strings.Join(statements, ", "))
+}
+
+// Function to build a formula matching features that either have, or
+// don't have, a value in a field.
+func presenceFormula(field string, present bool) string {
+	if present {
+		return fmt.Sprintf("{%s} != ''", field)
+	}
+	return fmt.Sprintf("{%s} = ''", field)
+}
+
+// Function to build a formula matching features targeted to a quarter in
+// the configured quarter field, the roadmap by default. The quarter must
+// appear as a whole word, and when a year is given it must follow the
+// quarter, so "Q3 2024" matches "Q3 2024" and "Q3-2024" but not "Q3 2025".
+func quarterFormula(quarter string) string {
+	parts := strings.Fields(quarter)
+	pattern := fmt.Sprintf(`(?i)\b%s\b`, parts[0])
+	if len(parts) > 1 {
+		pattern = fmt.Sprintf(`(?i)\b%s[\s/-]*%s\b`, parts[0], parts[1])
+	}
+	return fmt.Sprintf("REGEX_MATCH({%s}, '%s')", quarterField, formulaString(pattern))
+}
+
+// Function to build a formula matching a single term against its scoped
+// field, or any of the fields passed in when it isn't scoped.
+func termFormula(term searchTerm, fields []string, wholeWord bool) string {
+	if term.Field != "" {
+		fields = []string{term.Field}
+	}
+
+	// Convert our term to lowercase to gather the most results, unless
+	// the base has been configured to be searched case-sensitively.
+	text := term.Text
+	if !caseSensitive {
+		text = foldCase(text)
+	}
+
+	// Create one statement for each of the fields and each variant of the
+	// term, then combine every statement into a single formula, separated
+	// by a comma.
+	var searchStatements []string
+	for _, v := range fields {
+		for _, variant := range termVariants(text) {
+			searchStatements = append(searchStatements, matchStatement(variant, v, wholeWord))
+		}
+	}
+
+	return fmt.Sprintf("OR(%s)", strings.Join(searchStatements, ", "))
+}
+
+// Function to build a single Airtable statement matching a query against
+// one field. Substring matches use SEARCH() while whole-word matches use
+// a regular expression bounded on both sides by word boundaries, so that
+// searching "api" does not match "rapid". The field is wrapped in LOWER()
+// unless searches are case-sensitive.
+func matchStatement(query, field string, wholeWord bool) string {
+	value := fmt.Sprintf("{%s}", field)
+	if !caseSensitive {
+		value = fmt.Sprintf("LOWER(%s)", value)
+	}
+
+	if wholeWord {
+		pattern := fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(query))
+		return fmt.Sprintf("REGEX_MATCH(%s, '%s')", value, formulaString(pattern))
+	}
+	return fmt.Sprintf("SEARCH('%s', %s) > 0", formulaString(query), value)
+}
+
+// Function to normalize a string to NFC and lowercase it using the full
+// Unicode case mappings, so non-ASCII characters are handled correctly.
+// Lowercasing is used rather than full case folding so that the result
+// agrees with the LOWER() function Airtable applies to the fields.
+func foldCase(s string) string {
+	return cases.Lower(language.Und).String(norm.NFC.String(s))
+}
+
+// Function to escape a value so it can be placed inside a single-quoted
+// string in an Airtable formula.
+func formulaString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
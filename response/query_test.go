@@ -0,0 +1,483 @@
+package response
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWholeWordMatch(t *testing.T) {
+	defer func(c bool) { caseSensitive = c }(caseSensitive)
+	caseSensitive = false
+
+	tests := []struct {
+		name      string
+		query     string
+		wantWhole bool
+		want      string
+	}{
+		{"substring by default", "api", false, `OR(SEARCH('api', LOWER({Feature})) > 0)`},
+		{"whole word with the flag", "api --word", true, `OR(REGEX_MATCH(LOWER({Feature}), '\\bapi\\b'))`},
+		{"flag is case-insensitive", "--WORD api", true, `OR(REGEX_MATCH(LOWER({Feature}), '\\bapi\\b'))`},
+		{"quoted flag is searched", `"--word" api`, false, `OR(SEARCH('--word api', LOWER({Feature})) > 0)`},
+		{"regex characters are escaped", "c++ --word", true, `OR(REGEX_MATCH(LOWER({Feature}), '\\bc\\+\\+\\b'))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := parseQuery(tt.query)
+			if search.WholeWord != tt.wantWhole {
+				t.Errorf("WholeWord = %t, want %t", search.WholeWord, tt.wantWhole)
+			}
+			if got := buildFormula(search, []string{"Feature"}); got != tt.want {
+				t.Errorf("buildFormula() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaseSensitiveFormula(t *testing.T) {
+	defer func(c bool) { caseSensitive = c }(caseSensitive)
+	fields := []string{"Feature", "Plan"}
+
+	tests := []struct {
+		name          string
+		caseSensitive bool
+		query         string
+		want          string
+	}{
+		{"insensitive lowercases both sides", false, "SSO", `OR(SEARCH('sso', LOWER({Feature})) > 0, SEARCH('sso', LOWER({Plan})) > 0)`},
+		{"sensitive keeps the query as typed", true, "SSO", `OR(SEARCH('SSO', {Feature}) > 0, SEARCH('SSO', {Plan}) > 0)`},
+		{"insensitive whole word", false, "SSO --word", `OR(REGEX_MATCH(LOWER({Feature}), '\\bsso\\b'), REGEX_MATCH(LOWER({Plan}), '\\bsso\\b'))`},
+		{"sensitive whole word", true, "SSO --word", `OR(REGEX_MATCH({Feature}, '\\bSSO\\b'), REGEX_MATCH({Plan}, '\\bSSO\\b'))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caseSensitive = tt.caseSensitive
+			if got := buildFormula(parseQuery(tt.query), fields); got != tt.want {
+				t.Errorf("buildFormula() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaseSensitiveConfig(t *testing.T) {
+	for _, v := range []string{"true", "false", ""} {
+		restore := useEnv(t, map[string]string{"AIRTABLE_CASE_SENSITIVE": v})
+		if want := v == "true"; caseSensitive != want {
+			t.Errorf("AIRTABLE_CASE_SENSITIVE=%q: caseSensitive = %t, want %t", v, caseSensitive, want)
+		}
+		restore()
+	}
+}
+
+func TestQueryOperators(t *testing.T) {
+	defer func(c bool) { caseSensitive = c }(caseSensitive)
+	caseSensitive = false
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"words are searched as a phrase", "single sign on", `OR(SEARCH('single sign on', LOWER({Feature})) > 0)`},
+		{"AND matches every term", "sso AND saml", `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), OR(SEARCH('saml', LOWER({Feature})) > 0))`},
+		{"OR matches any term", "sso OR saml", `OR(OR(SEARCH('sso', LOWER({Feature})) > 0), OR(SEARCH('saml', LOWER({Feature})) > 0))`},
+		{"AND takes precedence over OR", "sso OR saml AND scim", `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), OR(SEARCH('saml', LOWER({Feature})) > 0), OR(SEARCH('scim', LOWER({Feature})) > 0))`},
+		{"lowercase operators are searched", "salt and pepper", `OR(SEARCH('salt and pepper', LOWER({Feature})) > 0)`},
+		{"quoted phrase is one term", `"single sign on" OR saml`, `OR(OR(SEARCH('single sign on', LOWER({Feature})) > 0), OR(SEARCH('saml', LOWER({Feature})) > 0))`},
+		{"quoted operator is searched", `"AND"`, `OR(SEARCH('and', LOWER({Feature})) > 0)`},
+		{"exclusion", "billing -legacy", `AND(OR(SEARCH('billing', LOWER({Feature})) > 0), NOT(OR(SEARCH('legacy', LOWER({Feature})) > 0)))`},
+		{"only operators matches nothing", "AND OR", "FALSE()"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildFormula(parseQuery(tt.query), []string{"Feature"}); got != tt.want {
+				t.Errorf("buildFormula() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxQueryTokens(t *testing.T) {
+	defer func(m int) { maxQueryTokens = m }(maxQueryTokens)
+
+	tests := []struct {
+		name          string
+		max           int
+		query         string
+		wantText      string
+		wantTruncated bool
+	}{
+		{"under the limit", 3, "single sign on", "single sign on", false},
+		{"over the limit", 3, "single sign on for every user", "single sign on", true},
+		{"operators and flags aren't counted", 2, "sso AND saml --word", "sso saml", false},
+		{"no limit", 0, "single sign on for every user", "single sign on for every user", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxQueryTokens = tt.max
+			search := parseQuery(tt.query)
+			var words []string
+			for _, term := range search.Terms {
+				words = append(words, term.Text)
+			}
+			if got := strings.Join(words, " "); got != tt.wantText || search.Truncated != tt.wantTruncated {
+				t.Errorf("parseQuery() searched %q truncated %t, want %q %t", got, search.Truncated, tt.wantText, tt.wantTruncated)
+			}
+
+			res, err := buildSlackResponse(nil, search)
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			if got := strings.Contains(res.Text, "Your query was too long"); got != tt.wantTruncated {
+				t.Errorf("header %q mentions truncation = %t, want %t", res.Text, got, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestUnicodeQueries(t *testing.T) {
+	defer func(c bool, s []string) { caseSensitive, searchFields = c, s }(caseSensitive, searchFields)
+	caseSensitive = false
+	searchFields = []string{"Feature"}
+
+	f := testFeatures(t, map[string]interface{}{"id": "recCafe0000000000", "fields": map[string]interface{}{"Feature": "Café Crème"}})[0]
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"composed", "café crème"},
+		{"decomposed", "cafe\u0301 cre\u0300me"},
+		{"uppercase", "CAFÉ CRÈME"},
+		{"uppercase decomposed", "CAFE\u0301 CRE\u0300ME"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := parseQuery(tt.query)
+			if got, want := buildFormula(search, searchFields), `OR(SEARCH('café crème', LOWER({Feature})) > 0)`; got != want {
+				t.Errorf("buildFormula() = %s, want %s", got, want)
+			}
+			if got := matchedFields(f, search); !reflect.DeepEqual(got, []string{"Feature"}) {
+				t.Errorf("matchedFields() = %v, want the accented feature name", got)
+			}
+		})
+	}
+}
+
+func TestFlaggedFilter(t *testing.T) {
+	defer func(c bool) { caseSensitive = c }(caseSensitive)
+	caseSensitive = false
+
+	tests := []struct {
+		name        string
+		query       string
+		wantFlagged *bool
+		want        string
+	}{
+		{"flagged", "sso flagged:true", boolPtr(true), `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), {Feature flag} != '')`},
+		{"not flagged", "sso flagged:false", boolPtr(false), `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), {Feature flag} = '')`},
+		{"filter name is case-insensitive", "sso FLAGGED:true", boolPtr(true), `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), {Feature flag} != '')`},
+		{"filter on its own", "flagged:true", boolPtr(true), `{Feature flag} != ''`},
+		{"value that isn't a bool is searched", "flagged:maybe", nil, `OR(SEARCH('flagged:maybe', LOWER({Feature})) > 0)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := parseQuery(tt.query)
+			if !reflect.DeepEqual(search.Flagged, tt.wantFlagged) {
+				t.Errorf("Flagged = %v, want %v", search.Flagged, tt.wantFlagged)
+			}
+			if got := buildFormula(search, []string{"Feature"}); got != tt.want {
+				t.Errorf("buildFormula() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Function to return a pointer to a bool, for the optional filters of a
+// search request.
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestSearchColumn(t *testing.T) {
+	defer func(s []string) { searchFields = s }(searchFields)
+	searchFields = []string{"Feature", "Plan"}
+
+	tests := []struct {
+		name         string
+		searchColumn string
+		want         string
+	}{
+		{"searches the joined column", "Searchable", `OR(SEARCH('sso', LOWER({Searchable})) > 0)`},
+		{"falls back to each field", "", `OR(SEARCH('sso', LOWER({Feature})) > 0, SEARCH('sso', LOWER({Plan})) > 0)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"AIRTABLE_SEARCH_COLUMN": " " + tt.searchColumn + " ",
+				"CHANNEL_DEFAULT_SCOPES": "",
+				"QUERY_CACHE_TTL":        "",
+			})()
+			a := newFakeAirtable(nil)
+			defer useFakeAirtable(a)()
+
+			if _, err := queryAirtable(parseQuery("sso")); err != nil {
+				t.Fatalf("queryAirtable() error = %v", err)
+			}
+			if len(a.queries) != 1 || a.queries[0].FilterByFormula != tt.want {
+				t.Errorf("queries = %+v, want the formula %s", a.queries, tt.want)
+			}
+
+			f := testFeatures(t, map[string]interface{}{"id": "recSso000000000000", "fields": map[string]interface{}{"Feature": "SSO"}})[0]
+			if got := matchedFields(f, parseQuery("sso")); !reflect.DeepEqual(got, []string{"Feature"}) {
+				t.Errorf("matchedFields() = %v, want the field itself rather than the column", got)
+			}
+		})
+	}
+}
+
+func TestTrimPunctuation(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure bool
+		chars     string
+		query     string
+		want      string
+	}{
+		{"trailing question mark", false, "", "billing?", "billing"},
+		{"leading and trailing", false, "", "¡sso!", "¡sso"},
+		{"every word", false, "", "single, sign. on;", "single sign on"},
+		{"inside a word is kept", false, "", "v1.2", "v1.2"},
+		{"quoted phrases are kept", false, "", `"what is sso?"`, "what is sso?"},
+		{"punctuation on its own is dropped", false, "", "sso ?", "sso"},
+		{"configured characters", true, "()", "(sso)?", "sso)?"},
+		{"trimming turned off", true, "", "billing?", "billing?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.configure {
+				defer useEnv(t, map[string]string{"QUERY_TRIM_PUNCTUATION": tt.chars})()
+			} else {
+				// The default characters are trimmed only while the
+				// variable is unset.
+				defer useEnv(t, map[string]string{})()
+				v, ok := os.LookupEnv("QUERY_TRIM_PUNCTUATION")
+				os.Unsetenv("QUERY_TRIM_PUNCTUATION")
+				loadConfig()
+				if ok {
+					defer os.Setenv("QUERY_TRIM_PUNCTUATION", v)
+				}
+			}
+			if got := parseQuery(tt.query).text(); got != tt.want {
+				t.Errorf("parseQuery(%q) searched %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentedFilter(t *testing.T) {
+	defer func(c bool) { caseSensitive = c }(caseSensitive)
+	caseSensitive = false
+
+	tests := []struct {
+		name           string
+		query          string
+		wantDocumented *bool
+		want           string
+	}{
+		{"documented", "sso documented:true", boolPtr(true), `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), {External documentation} != '')`},
+		{"undocumented", "sso documented:false", boolPtr(false), `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), {External documentation} = '')`},
+		{"filter on its own", "Documented:TRUE", boolPtr(true), `{External documentation} != ''`},
+		{"combined with the flagged filter", "documented:false flagged:true", boolPtr(false), `AND({Feature flag} != '', {External documentation} = '')`},
+		{"value that isn't a bool is searched", "documented:yes please", nil, `OR(SEARCH('documented:yes please', LOWER({Feature})) > 0)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := parseQuery(tt.query)
+			if !reflect.DeepEqual(search.Documented, tt.wantDocumented) {
+				t.Errorf("Documented = %v, want %v", search.Documented, tt.wantDocumented)
+			}
+			if got := buildFormula(search, []string{"Feature"}); got != tt.want {
+				t.Errorf("buildFormula() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuarterFilter(t *testing.T) {
+	defer useEnv(t, map[string]string{"ROADMAP_QUARTER_FIELD": ""})()
+
+	tests := []struct {
+		name        string
+		query       string
+		wantQuarter string
+		want        string
+		matches     []string
+		misses      []string
+	}{
+		{"quarter", "quarter:Q3", "Q3", `REGEX_MATCH({Roadmap}, '(?i)\\bQ3\\b')`, []string{"Q3 2024", "q3", "Now, Q3"}, []string{"Q4 2024", "Q33"}},
+		{"quarter and year", "quarter:q3-2024", "Q3 2024", `REGEX_MATCH({Roadmap}, '(?i)\\bQ3[\\s/-]*2024\\b')`, []string{"Q3 2024", "Q3-2024", "Q3/2024"}, []string{"Q3 2025", "Q4 2024"}},
+		{"quarter that doesn't exist is searched", "quarter:Q5", "", "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := parseQuery(tt.query)
+			if search.Quarter != tt.wantQuarter {
+				t.Fatalf("Quarter = %q, want %q", search.Quarter, tt.wantQuarter)
+			}
+			if tt.wantQuarter == "" {
+				if len(search.Terms) != 1 || search.Terms[0].Text != tt.query {
+					t.Errorf("Terms = %+v, want the query searched as it was typed", search.Terms)
+				}
+				return
+			}
+			got := quarterFormula(search.Quarter)
+			if got != tt.want {
+				t.Fatalf("quarterFormula() = %s, want %s", got, tt.want)
+			}
+
+			pattern := strings.TrimSuffix(strings.TrimPrefix(got, "REGEX_MATCH({Roadmap}, '"), "')")
+			re := regexp.MustCompile(strings.Replace(pattern, `\\`, `\`, -1))
+			for _, v := range tt.matches {
+				if !re.MatchString(v) {
+					t.Errorf("formula doesn't match roadmap %q", v)
+				}
+			}
+			for _, v := range tt.misses {
+				if re.MatchString(v) {
+					t.Errorf("formula matches roadmap %q", v)
+				}
+			}
+		})
+	}
+}
+
+func TestQuarterField(t *testing.T) {
+	defer useEnv(t, map[string]string{"ROADMAP_QUARTER_FIELD": "Target quarter"})()
+	if got, want := quarterFormula("Q1"), `REGEX_MATCH({Target quarter}, '(?i)\\bQ1\\b')`; got != want {
+		t.Errorf("quarterFormula() = %s, want %s", got, want)
+	}
+}
+
+func TestMineKeyword(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		userID      string
+		wantTeam    string
+		wantFormula string
+		wantText    string
+	}{
+		{"known user", "mine sso", "U1", "Billing & Identity", `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), SEARCH('billing & identity', LOWER({Team responsible})) > 0)`, "Only features owned by Billing & Identity were searched."},
+		{"keyword is case-insensitive", "MINE sso", "U1", "Billing & Identity", `AND(OR(SEARCH('sso', LOWER({Feature})) > 0), SEARCH('billing & identity', LOWER({Team responsible})) > 0)`, "Only features owned by Billing & Identity were searched."},
+		{"unknown user", "mine sso", "U2", "", `OR(SEARCH('sso', LOWER({Feature})) > 0)`, "Anerbot doesn't know which team you're on"},
+		{"without the keyword", "sso", "U1", "", `OR(SEARCH('sso', LOWER({Feature})) > 0)`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"USER_TEAMS": "U1=Billing & Identity", "AIRTABLE_CASE_SENSITIVE": "", "QUERY_STEMMING": ""})()
+			search := parseQuery(tt.query)
+			search.scopeToUser(tt.userID)
+			if search.Team != tt.wantTeam {
+				t.Errorf("Team = %q, want %q", search.Team, tt.wantTeam)
+			}
+			if got := buildFormula(search, []string{"Feature"}); got != tt.wantFormula {
+				t.Errorf("buildFormula() = %s, want %s", got, tt.wantFormula)
+			}
+
+			defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{"recSso00000000001": {"Feature": "SSO"}}))()
+			slack := newFakeSlack()
+			defer slack.Close()
+			if err := respond(t, queueMessage{Query: tt.query, UserID: tt.userID, ResponseUrl: slack.URL, RequestID: "test"}); err != nil {
+				t.Fatal(err)
+			}
+			posted := slack.posted()
+			if len(posted) == 0 {
+				t.Fatal("nothing was posted")
+			}
+			if tt.wantText == "" {
+				if strings.Contains(posted[0].Text, "team") {
+					t.Errorf("text = %q, want no mention of teams", posted[0].Text)
+				}
+			} else if !strings.Contains(posted[0].Text, tt.wantText) {
+				t.Errorf("text = %q, want it to contain %q", posted[0].Text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestScopeClarification(t *testing.T) {
+	valid := "Try scoping your search to one of `docs:`, `entitlements:`, `feature:`, `flag:`, `plan:`, `roadmap:`, `team:` instead."
+	tests := []struct {
+		name       string
+		clarify    string
+		query      string
+		wantScopes []string
+		want       string
+	}{
+		{"unknown scope", "true", "teams:billing", []string{"teams"}, `Anerbot doesn't know the field "teams". ` + valid},
+		{"several unknown scopes", "true", "Teams:billing owner:sso", []string{"teams", "owner"}, `Anerbot doesn't know the field "teams" or "owner". ` + valid},
+		{"known scope", "true", "team:billing", nil, ""},
+		{"filter", "true", "flagged:true", nil, ""},
+		{"clarification turned off", "", "teams:billing", []string{"teams"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"QUERY_CLARIFY_SCOPES": tt.clarify})()
+			search := parseQuery(tt.query)
+			if !reflect.DeepEqual(search.UnknownScopes, tt.wantScopes) {
+				t.Errorf("UnknownScopes = %q, want %q", search.UnknownScopes, tt.wantScopes)
+			}
+
+			a := newFakeAirtable(map[string]map[string]interface{}{"recSso00000000001": {"Feature": "SSO"}})
+			defer useFakeAirtable(a)()
+			slack := newFakeSlack()
+			defer slack.Close()
+			if err := respond(t, queueMessage{Query: tt.query, ResponseUrl: slack.URL, RequestID: "test"}); err != nil {
+				t.Fatal(err)
+			}
+			posted := slack.posted()
+			if len(posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(posted))
+			}
+			if tt.want != "" {
+				if posted[0].Text != tt.want || len(a.queries) != 0 {
+					t.Errorf("posted %q after %d queries, want %q without searching", posted[0].Text, len(a.queries), tt.want)
+				}
+			} else if len(a.queries) == 0 {
+				t.Errorf("posted %q without searching, want the results", posted[0].Text)
+			}
+		})
+	}
+}
+
+func TestMinAndTokenLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		min   string
+		query string
+		want  []string
+	}{
+		{"short terms dropped", "3", "sso AND ui AND export", []string{"sso", "export"}},
+		{"minimum not configured", "", "sso AND ui", []string{"sso", "ui"}},
+		{"longest kept when every term is short", "4", "ui AND sso", []string{"sso"}},
+		{"quoted terms kept", "3", `"ui" AND export`, []string{"ui", "export"}},
+		{"scoped terms kept", "3", "plan:ui AND export", []string{"ui", "export"}},
+		{"OR mode is left alone", "3", "sso OR ui", []string{"sso", "ui"}},
+		{"length counted in characters", "3", "né AND export", []string{"export"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"QUERY_MIN_AND_TOKEN_LENGTH": tt.min})()
+			var got []string
+			for _, term := range parseQuery(tt.query).Terms {
+				got = append(got, term.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("terms = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package response
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Maximum number of queries kept in the query cache at once.
+const maxCachedQueries = 500
+
+// Cache of the features returned by Airtable for each query, keyed by
+// everything that decides which records and fields Airtable returns so
+// that queries only share an entry when they would get the same records.
+var queryCache = struct {
+	mu      sync.Mutex
+	entries map[string]cachedQuery
+}{
+	entries: make(map[string]cachedQuery),
+}
+
+// Struct for the parts of a query that make up its key in the query
+// cache. The scope is the fields searched by terms that aren't scoped to
+// a single field, and AllFields tells requesting every field apart from
+// requesting only some.
+type queryCacheKey struct {
+	View      string   `json:"view"`
+	Formula   string   `json:"formula"`
+	Scope     []string `json:"scope"`
+	Fields    []string `json:"fields"`
+	AllFields bool     `json:"all_fields"`
+}
+
+// Function to build the query cache key for a search and the parameters
+// it is sent to Airtable with, so that searches scoped to different
+// fields, or filtered differently, never share a cache entry.
+func cacheKey(search searchRequest, params airtable.ListParameters) string {
+	key, _ := json.Marshal(queryCacheKey{
+		View:      params.View,
+		Formula:   params.FilterByFormula,
+		Scope:     defaultScope(search.ChannelID),
+		Fields:    params.Fields,
+		AllFields: params.Fields == nil,
+	})
+	return string(key)
+}
+
+// Struct for the features cached for a single query.
+type cachedQuery struct {
+	features  []feature
+	fetchedAt time.Time
+}
+
+// Function to return the cached features for a query, if the query has
+// been cached within the configured time to live.
+func cachedFeatures(key string) ([]feature, bool) {
+	if queryCacheTTL <= 0 {
+		return nil, false
+	}
+
+	queryCache.mu.Lock()
+	defer queryCache.mu.Unlock()
+
+	c, ok := queryCache.entries[key]
+	if !ok || time.Since(c.fetchedAt) >= queryCacheTTL {
+		return nil, false
+	}
+	// Return a copy so nothing done with the results can change the order
+	// of the cached features.
+	return append([]feature(nil), c.features...), true
+}
+
+// Function to cache the features returned for a query. Expired entries
+// are dropped to make room once the cache is full, followed by any entry
+// if every entry is still fresh.
+func cacheFeatures(key string, f []feature) {
+	if queryCacheTTL <= 0 {
+		return
+	}
+
+	queryCache.mu.Lock()
+	defer queryCache.mu.Unlock()
+
+	if len(queryCache.entries) >= maxCachedQueries {
+		for k, c := range queryCache.entries {
+			if time.Since(c.fetchedAt) >= queryCacheTTL {
+				delete(queryCache.entries, k)
+			}
+		}
+	}
+	if len(queryCache.entries) >= maxCachedQueries {
+		for k := range queryCache.entries {
+			delete(queryCache.entries, k)
+			break
+		}
+	}
+	queryCache.entries[key] = cachedQuery{features: append([]feature(nil), f...), fetchedAt: time.Now()}
+}
+
+// Function to warm the query cache by running each of the queries passed
+// in, such as the most popular searches, so the first users to search
+// for them after a cold start don't wait on Airtable.
+func preloadQueries(queries []string) {
+	for _, q := range queries {
+		if _, err := queryAirtable(parseQuery(q)); err != nil {
+			log.Printf("unable to preload query %s: %v", logQuery(q), err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package response
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Function to empty the query cache, so each test starts by querying
+// whichever Airtable it is using.
+func resetQueryCache() {
+	queryCache.mu.Lock()
+	queryCache.entries = make(map[string]cachedQuery)
+	queryCache.mu.Unlock()
+}
+
+func TestPreloadQueries(t *testing.T) {
+	defer useEnv(t, map[string]string{"QUERY_CACHE_TTL": "1m"})()
+	resetQueryCache()
+	defer resetQueryCache()
+	a := newFakeAirtable(map[string]map[string]interface{}{"recSso": {"Feature": "SSO"}})
+	defer useFakeAirtable(a)()
+
+	preloadQueries([]string{"sso", "billing"})
+	if len(a.queries) != 2 {
+		t.Fatalf("preloading ran %d queries, want 2", len(a.queries))
+	}
+	for i, q := range []string{"sso", "billing"} {
+		if !strings.Contains(a.queries[i].FilterByFormula, q) {
+			t.Errorf("query %d formula = %s, want it to search for %q", i, a.queries[i].FilterByFormula, q)
+		}
+	}
+
+	f, err := queryAirtable(parseQuery("sso"))
+	if err != nil {
+		t.Fatalf("queryAirtable() error = %v", err)
+	}
+	if len(a.queries) != 2 || len(f) != 1 {
+		t.Errorf("preloaded search sent %d more queries and found %d features, want the cached feature", len(a.queries)-2, len(f))
+	}
+}
+
+func TestPreloadQueriesLogsFailures(t *testing.T) {
+	defer useEnv(t, map[string]string{"QUERY_CACHE_TTL": "1m", "LOG_REDACT_QUERIES": ""})()
+	resetQueryCache()
+	defer resetQueryCache()
+	defer useFakeAirtable(newFakeAirtable(nil))()
+	newLister = func() (recordLister, error) { return failingLister{errors.New("connection refused")}, nil }
+
+	logs := captureLogs(func() { preloadQueries([]string{"sso"}) })
+	if !strings.Contains(logs, `unable to preload query "sso": connection refused`) {
+		t.Errorf("logs = %q, want the failed preload logged", logs)
+	}
+}
+
+func TestQueryCacheKeyIncludesScope(t *testing.T) {
+	defer useEnv(t, map[string]string{"QUERY_CACHE_TTL": "1m", "CHANNEL_DEFAULT_SCOPES": "CPLANS=plan"})()
+	resetQueryCache()
+	defer resetQueryCache()
+	a := newFakeAirtable(map[string]map[string]interface{}{"recSso": {"Feature": "SSO", "Plan": "Enterprise"}})
+	defer useFakeAirtable(a)()
+
+	tests := []struct {
+		name      string
+		query     string
+		channelID string
+		want      int
+	}{
+		{"unscoped", "sso", "", 1},
+		{"unscoped again is cached", "sso", "", 1},
+		{"scoped to a field", "feature:sso", "", 2},
+		{"scoped again is cached", "feature:sso", "", 2},
+		{"channel with a default scope", "sso", "CPLANS", 3},
+		{"channel without a default scope shares the unscoped entry", "sso", "COTHER", 3},
+	}
+	for _, tt := range tests {
+		search := parseQuery(tt.query)
+		search.ChannelID = tt.channelID
+		if _, err := queryAirtable(search); err != nil {
+			t.Fatalf("%s: queryAirtable() error = %v", tt.name, err)
+		}
+		if got := len(a.queries); got != tt.want {
+			t.Errorf("%s: %d queries sent to Airtable, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Struct for a recordLister that lists the records passed in, in the
+// order they were passed in, as Airtable might between cache refreshes.
+type orderedLister struct {
+	records []map[string]interface{}
+}
+
+// Function to list every record in order.
+func (l orderedLister) ListRecords(tableName string, recordsHolder interface{}, listParams ...airtable.ListParameters) error {
+	return remarshal(l.records, recordsHolder)
+}
+
+func TestCachedAndFreshResultsShareOrder(t *testing.T) {
+	records := []map[string]interface{}{
+		{"id": "recSso00000000003", "fields": map[string]interface{}{"Feature": "SSO for teams", "Plan": "Team"}},
+		{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO", "Plan": "Enterprise"}},
+		{"id": "recSso00000000004", "fields": map[string]interface{}{"Feature": "Enforced SSO", "Plan": "Enterprise"}},
+		{"id": "recSso00000000002", "fields": map[string]interface{}{"Feature": "sso audit", "Plan": "Team"}},
+	}
+	reversed := make([]map[string]interface{}, len(records))
+	for i, r := range records {
+		reversed[len(records)-1-i] = r
+	}
+
+	for _, mode := range []string{sortName, sortRelevance, sortPlanTier} {
+		t.Run(mode, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"QUERY_CACHE_TTL": "1m", "RESULT_SORT": mode, "MIN_RESULT_SCORE": ""})()
+			resetQueryCache()
+			defer resetQueryCache()
+			defer useFakeAirtable(newFakeAirtable(nil))()
+
+			var fetches int
+			run := func(order []map[string]interface{}) []string {
+				newLister = func() (recordLister, error) {
+					fetches++
+					return orderedLister{order}, nil
+				}
+				slack := newFakeSlack()
+				defer slack.Close()
+				if err := respond(t, queueMessage{Query: "sso", ResponseUrl: slack.URL, RequestID: "test"}); err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for _, m := range slack.posted() {
+					for _, a := range m.Attachments {
+						if i := strings.Index(a.Fallback, ": https://"); i > 0 {
+							names = append(names, a.Fallback[:i])
+						}
+					}
+				}
+				return names
+			}
+
+			fresh := run(records)
+			cached := run(reversed)
+			resetQueryCache()
+			refreshed := run(reversed)
+			if fetches != 2 {
+				t.Errorf("fetched from Airtable %d times, want the second search served from the cache", fetches)
+			}
+			if len(fresh) != len(records) {
+				t.Fatalf("fresh results = %q, want every feature", fresh)
+			}
+			if !reflect.DeepEqual(cached, fresh) || !reflect.DeepEqual(refreshed, fresh) {
+				t.Errorf("fresh %q, cached %q and refreshed %q results differ in order", fresh, cached, refreshed)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package response
+
+import (
+	"log"
+	"time"
+)
+
+// Action sent by the anerbot-queue function when an admin asks for the
+// configuration to be reloaded.
+const reloadAction = "reload"
+
+// Function to reload the configuration, reading the secret files again,
+// and drop everything cached under the old configuration, so that
+// features fetched with old credentials are not served. Airtable clients
+// are built for each query, so they pick up a rotated API key as soon as
+// it is loaded.
+func reloadConfig() error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
+
+	nameCache.mu.Lock()
+	nameCache.names = nil
+	nameCache.fetchedAt = time.Time{}
+	nameCache.mu.Unlock()
+
+	queryCache.mu.Lock()
+	queryCache.entries = make(map[string]cachedQuery)
+	queryCache.mu.Unlock()
+
+	return nil
+}
+
+// Function to handle a reload requested by an admin, letting them know
+// whether the new configuration was loaded. A configuration that fails to
+// load is reported rather than stopping the function, leaving whatever
+// settings were read before the failure in place.
+func handleReload(message queueMessage) error {
+	log.Printf("request %s: reloading configuration", message.RequestID)
+
+	text := "Configuration reloaded :white_check_mark:"
+	err := reloadConfig()
+	if err != nil {
+		log.Printf("request %s: unable to reload configuration: %v", message.RequestID, err)
+		text = "Unable to reload configuration: " + err.Error()
+	}
+
+	res := &slackResponse{
+		ResponseType: responseEphemeral,
+		Text:         text,
+	}
+	if err := postToSlack(message.ResponseUrl, res); err != nil {
+		return codeErrorf(errSlack, "request %s: %v", message.RequestID, err)
+	}
+	return nil
+}
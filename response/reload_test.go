@@ -0,0 +1,77 @@
+package response
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to write a secret to a file in a new temporary directory, as
+// a mounted secret would be, returning the path to the file. Call
+// os.RemoveAll on its directory once the test is finished.
+func writeSecret(t *testing.T, secret string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "anerbot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReloadReadsRotatedSecrets(t *testing.T) {
+	path := writeSecret(t, "old-key")
+	defer os.RemoveAll(filepath.Dir(path))
+	defer useEnv(t, map[string]string{"AIRTABLE_API_KEY_FILE": path})()
+	if airtableAPIKey != "old-key" {
+		t.Fatalf("airtableAPIKey = %q, want the key from the file", airtableAPIKey)
+	}
+
+	queryCache.mu.Lock()
+	queryCache.entries["sso"] = cachedQuery{fetchedAt: time.Now()}
+	queryCache.mu.Unlock()
+	if err := ioutil.WriteFile(path, []byte("rotated-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	slack := newFakeSlack()
+	defer slack.Close()
+	if err := respond(t, queueMessage{ResponseUrl: slack.URL, RequestID: "req1", Action: reloadAction}); err != nil {
+		t.Fatalf("Response() error = %v", err)
+	}
+	if !strings.Contains(slack.text(), "Configuration reloaded") {
+		t.Errorf("posted %q, want the reload confirmed", slack.text())
+	}
+	if airtableAPIKey != "rotated-key" {
+		t.Errorf("airtableAPIKey = %q, want the rotated key", airtableAPIKey)
+	}
+	queryCache.mu.Lock()
+	defer queryCache.mu.Unlock()
+	if len(queryCache.entries) != 0 {
+		t.Errorf("query cache kept %d entries from before the reload", len(queryCache.entries))
+	}
+}
+
+func TestReloadFailure(t *testing.T) {
+	path := writeSecret(t, "old-key")
+	defer os.RemoveAll(filepath.Dir(path))
+	defer useEnv(t, map[string]string{"AIRTABLE_API_KEY_FILE": path})()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	slack := newFakeSlack()
+	defer slack.Close()
+	if err := respond(t, queueMessage{ResponseUrl: slack.URL, RequestID: "req1", Action: reloadAction}); err != nil {
+		t.Fatalf("Response() error = %v", err)
+	}
+	if !strings.Contains(slack.text(), "Unable to reload configuration: unable to read AIRTABLE_API_KEY_FILE") {
+		t.Errorf("posted %q, want the unreadable file reported", slack.text())
+	}
+}
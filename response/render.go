@@ -0,0 +1,618 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Struct describing a feature field that is displayed in Slack. Name is
+// the field name in Airtable while Label and Emoji are shown alongside
+// the value of the field.
+type displayField struct {
+	Name  string
+	Label string
+	Emoji string
+}
+
+// Fields of a feature displayed in Slack, in the order they are shown
+// unless a different order has been configured.
+var displayFields []displayField
+
+// Fields of a feature displayed in Slack, in their default order.
+var defaultDisplayFields = []displayField{
+	{Name: "Roadmap", Label: "Roadmap", Emoji: "sparkles"},
+	{Name: "Team responsible", Label: "Team(s)", Emoji: "one-team"},
+	{Name: "Plan", Label: "Plan", Emoji: "moneybag"},
+	{Name: "Feature flag", Label: "Feature Flag", Emoji: "triangular_flag_on_post"},
+	{Name: "Entitlements", Label: "Entitlements", Emoji: "crown"},
+	{Name: "External documentation", Label: "External Documentation", Emoji: "books"},
+}
+
+// Function to reorder the fields displayed for each feature. Fields named
+// in the order come first, in that order, followed by every other field
+// in its default position.
+func orderFields(fields []displayField, order []string) []displayField {
+	var ordered []displayField
+	for _, name := range order {
+		for _, d := range fields {
+			if d.Name == name && !containsField(ordered, name) {
+				ordered = append(ordered, d)
+			}
+		}
+	}
+	for _, d := range fields {
+		if !containsField(ordered, d.Name) {
+			ordered = append(ordered, d)
+		}
+	}
+	return ordered
+}
+
+// Function to check whether a slice of display fields contains a field.
+func containsField(fields []displayField, name string) bool {
+	for _, d := range fields {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to return the fields displayed for each feature in a channel.
+// Every field is displayed unless a subset has been configured, and any
+// fields hidden in the channel, such as internal fields in an external
+// facing channel, are left out.
+func visibleFields(channelID string) []displayField {
+	hidden := channelHiddenFields[normalizeID(channelID)]
+	var fields []displayField
+	for _, d := range displayFields {
+		if shownFields != nil && !shownFields[d.Name] {
+			continue
+		}
+		if containsString(hidden, d.Name) {
+			continue
+		}
+		fields = append(fields, d)
+	}
+	return fields
+}
+
+// Function to return the fields to request from Airtable, which are only
+// the fields needed to render and sort the results of the search
+// request. The feature name is always requested, and is all that brief
+// results show. Nil requests every field.
+func requestFields(search searchRequest) []string {
+	if search.Keyword == breakdownKeyword {
+		return []string{"Feature", "Team responsible"}
+	}
+
+	// Translations can't be requested by name since not every field has
+	// one, so every field is requested when showing another language.
+	if search.language() != "" {
+		return nil
+	}
+
+	fields := []string{"Feature"}
+	if search.Keyword != briefKeyword {
+		for _, d := range visibleFields(search.ChannelID) {
+			fields = append(fields, d.Name)
+		}
+		if lastModifiedField != "" {
+			fields = append(fields, lastModifiedField)
+		}
+		if lastModifiedByField != "" {
+			fields = append(fields, lastModifiedByField)
+		}
+	}
+
+	// Fields holding images are shown as images rather than as fields.
+	if search.Keyword != briefKeyword {
+		for _, name := range imageFields {
+			if !containsString(fields, name) {
+				fields = append(fields, name)
+			}
+		}
+	}
+
+	// The roadmap is needed for status emoji, and for subscriptions to
+	// compare later roadmaps against, even when it isn't shown.
+	if (len(statusEmoji) > 0 || subscribeButton) && !containsString(fields, "Roadmap") {
+		fields = append(fields, "Roadmap")
+	}
+	// The plan is needed to sort or group by plan tier even when it isn't
+	// shown.
+	if (resultSort == sortPlanTier || resultGroup == groupPlanTier) && !containsString(fields, "Plan") {
+		fields = append(fields, "Plan")
+	}
+	// Every field the search is scored against is needed to filter by
+	// score or sort by relevance.
+	if minScore > 0 || resultSort == sortRelevance {
+		for _, name := range scoredFields(search) {
+			if !containsString(fields, name) {
+				fields = append(fields, name)
+			}
+		}
+	}
+	return fields
+}
+
+// Function to style the header text at the top of a response, in bold
+// and prefixed with the configured emoji when they are enabled.
+func styleHeader(text string) string {
+	if boldHeader {
+		text = fmt.Sprintf("*%s*", text)
+	}
+	if headerEmoji != "" {
+		text = fmt.Sprintf("%s %s", headerEmoji, text)
+	}
+	return text
+}
+
+// Function to render the title of a feature, prefixed with the emoji for
+// its roadmap status when status emoji are configured.
+func featureTitle(f feature) string {
+	name := ellipsize(f.Fields.Feature, maxTitleLength)
+	if e := roadmapStatus(f.Fields.Roadmap); e != "" {
+		return fmt.Sprintf("%s %s", e, name)
+	}
+	return name
+}
+
+// Function to shorten a string to at most n characters, ending it with an
+// ellipsis when it was shortened. Strings are left as they are when n
+// isn't positive.
+func ellipsize(s string, n int) string {
+	r := []rune(s)
+	if n <= 0 || len(r) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(r[:n-1])) + "…"
+}
+
+// Function to find the status emoji for a roadmap value. The configured
+// status contained in the roadmap value is used, preferring the longest
+// match so "not shipped" wins over "shipped" when both are configured.
+func roadmapStatus(roadmap string) string {
+	roadmap = foldCase(roadmap)
+	var match string
+	for status := range statusEmoji {
+		if strings.Contains(roadmap, status) && len(status) > len(match) {
+			match = status
+		}
+	}
+	return statusEmoji[match]
+}
+
+// Function to check whether a slice of strings contains a value.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to look up the value of one of a feature's fields by its
+// Airtable field name.
+func (f feature) fieldValue(name string) string {
+	switch name {
+	case "Feature":
+		return f.Fields.Feature
+	case "Roadmap":
+		return f.Fields.Roadmap
+	case "Team responsible":
+		return f.Fields.TeamResponsible
+	case "Plan":
+		return f.Fields.Plan
+	case "Feature flag":
+		return f.Fields.FeatureFlag
+	case "Entitlements":
+		return f.Fields.Entitlements
+	case "External documentation":
+		return f.Fields.ExternalDocumentation
+	}
+	return f.Extra[name]
+}
+
+// Function to render the populated fields of a feature as attachment
+// fields. By default a single field is returned containing one line per
+// populated field. Lines are visually separated in Slack via the
+// configured line delimiter, a new line by default. In the
+// compact layout each populated field is returned as its own short
+// field instead. Only the fields visible in the channel the search was
+// requested in are rendered, up to the configured maximum, followed by a
+// note of how many were left out.
+func renderFields(f feature, search searchRequest) []attachmentField {
+	var fields []attachmentField
+	var value string
+	var rendered, omitted int
+	for _, d := range visibleFields(search.ChannelID) {
+		v := formatValue(d.Name, f.fieldValue(d.Name))
+		if v == "" {
+			continue
+		}
+		if maxFieldLines > 0 && rendered >= maxFieldLines {
+			omitted++
+			continue
+		}
+		rendered++
+
+		if compactFields {
+			fields = append(fields, attachmentField{
+				Title: fieldLabel(d.Emoji, d.Label),
+				Value: v,
+				Short: true,
+			})
+			continue
+		}
+		value += fieldLine(d.Emoji, d.Label, v)
+	}
+
+	// Let the user know there is more to see on the feature itself.
+	if omitted > 0 {
+		more := fmt.Sprintf("_…%s not shown, open the feature to see them all_", plural(omitted, "field"))
+		if compactFields {
+			fields = append(fields, attachmentField{
+				Value: more,
+				Short: false,
+			})
+		} else {
+			value += more + lineDelimiter
+		}
+	}
+
+	// Show how long ago the feature was last updated, and by whom, when
+	// the base tracks it and the timestamp can be understood.
+	updated := lastUpdated(f, time.Now())
+	if editor := lastEditor(f); editor != "" {
+		updated = strings.TrimSpace(fmt.Sprintf("%s by %s", updated, editor))
+	}
+	if updated != "" {
+		if compactFields {
+			fields = append(fields, attachmentField{
+				Title: fieldLabel("clock3", "Last updated"),
+				Value: updated,
+				Short: true,
+			})
+		} else {
+			value += fieldLine("clock3", "Last updated", updated)
+		}
+	}
+
+	// Link to the feature in the Airtable desktop app alongside the
+	// link to the feature on the web.
+	if appLinks {
+		links := fmt.Sprintf("<%s|Open on the web> | <%s|Open in app>", titleLink(f.AirtableID, search.Query), appLink(f.AirtableID))
+		if compactFields {
+			fields = append(fields, attachmentField{
+				Title: fieldLabel("link", "Links"),
+				Value: links,
+				Short: true,
+			})
+		} else {
+			value += fieldLine("link", "Links", links)
+		}
+	}
+
+	if compactFields {
+		return fields
+	}
+	return []attachmentField{
+		{
+			Title: "",
+			Value: value,
+		},
+	}
+}
+
+// Function to render the fields of a feature collapsed into a single
+// section block. The first populated field is shown as the text of the
+// section and the rest are tucked away in an overflow menu beside it,
+// which expands to show the start of each of them when clicked. Picking
+// one shows its full value. Nil is returned when no field is populated.
+func collapsedFieldsBlock(f feature, channelID string) *block {
+	var summary string
+	var options []selectOption
+	var omitted int
+	for _, d := range visibleFields(channelID) {
+		v := f.fieldValue(d.Name)
+		if v == "" {
+			continue
+		}
+		if summary == "" {
+			summary = strings.TrimSuffix(fieldLine(d.Emoji, d.Label, formatValue(d.Name, v)), lineDelimiter)
+			continue
+		}
+		value, ok := fieldDetailsOption(f.AirtableID, d.Name)
+		if !ok || len(options) >= maxOverflowOptions {
+			omitted++
+			continue
+		}
+		options = append(options, selectOption{
+			Text:  textObject{Type: "plain_text", Text: ellipsize(fmt.Sprintf("%s: %s", d.Label, v), maxOptionLength)},
+			Value: value,
+		})
+	}
+	if summary == "" {
+		return nil
+	}
+
+	// Make room in the menu to let the user know there is more to see on
+	// the feature itself.
+	if omitted > 0 {
+		more := omitted
+		if len(options) >= maxOverflowOptions {
+			options = options[:len(options)-1]
+			more++
+		}
+		value, _ := fieldDetailsOption(f.AirtableID, "")
+		options = append(options, selectOption{
+			Text:  textObject{Type: "plain_text", Text: fmt.Sprintf("…%s more, open the feature to see them all", plural(more, "field"))},
+			Value: value,
+		})
+	}
+
+	b := &block{
+		Type: "section",
+		Text: &textObject{Type: "mrkdwn", Text: summary},
+	}
+	if len(options) > 0 {
+		b.Accessory = blockElement{
+			Type:     "overflow",
+			ActionID: fieldDetailsActionID,
+			Options:  options,
+		}
+	}
+	return b
+}
+
+// Function to format the value of a field for display. Entitlements are
+// rendered as badges when badges are configured, and each team responsible
+// for a feature is rendered separately as its configured mention. Fields
+// configured to render as bullets have their comma or newline separated
+// items split onto their own bulleted lines, starting on the line after
+// the label.
+func formatValue(field, value string) string {
+	if value == "" {
+		return value
+	}
+	if field == "Entitlements" && len(entitlementBadges) > 0 {
+		return renderBadges(splitItems(value))
+	}
+
+	items := splitItems(value)
+	if field == "Team responsible" {
+		items = renderTeams(items)
+		if !bulletFields[field] {
+			return strings.Join(items, ", ")
+		}
+	}
+	if !bulletFields[field] {
+		return value
+	}
+
+	var bullets string
+	for _, item := range items {
+		bullets += fmt.Sprintf("%s• %s", lineDelimiter, item)
+	}
+	return bullets
+}
+
+// Pattern matching emoji shortcodes at the start of a value, such as
+// ":one-team: Billing".
+var emojiPrefixPattern = regexp.MustCompile(`^(\s*:[a-z0-9_+'-]+:)+`)
+
+// Patterns matching the Slack IDs of a user group and of a channel, which
+// team mentions may be configured as.
+var (
+	userGroupIDPattern = regexp.MustCompile(`^S[A-Z0-9]{8,}$`)
+	channelIDPattern   = regexp.MustCompile(`^C[A-Z0-9]{8,}$`)
+)
+
+// Replacer escaping the characters Slack treats as markup, so configured
+// text is shown as it was written.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Function to render each team responsible for a feature as its
+// configured Slack mention or link. A user group ID mentions the group, a
+// channel ID links to the channel and a URL links the team's name to it.
+// Any other mention is shown as plain text. Teams without a mention are
+// shown by name, with any emoji their name starts with removed so it
+// isn't repeated after the field's own emoji.
+func renderTeams(teams []string) []string {
+	var rendered []string
+	for _, team := range teams {
+		team = strings.TrimSpace(emojiPrefixPattern.ReplaceAllString(team, ""))
+		if team == "" {
+			continue
+		}
+		mention := teamMentions[foldCase(team)]
+		switch {
+		case mention == "":
+			rendered = append(rendered, team)
+		case strings.HasPrefix(mention, "http://") || strings.HasPrefix(mention, "https://"):
+			rendered = append(rendered, fmt.Sprintf("<%s|%s>", mention, team))
+		case userGroupIDPattern.MatchString(mention):
+			rendered = append(rendered, fmt.Sprintf("<!subteam^%s>", mention))
+		case channelIDPattern.MatchString(mention):
+			rendered = append(rendered, fmt.Sprintf("<#%s>", mention))
+		default:
+			rendered = append(rendered, slackEscaper.Replace(mention))
+		}
+	}
+	return rendered
+}
+
+// Function to split the comma or newline separated items of a field's
+// value, leaving out any empty items.
+func splitItems(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Function to render entitlements as a row of badges, each one the name
+// of the entitlement in code formatting prefixed with its configured
+// emoji. Entitlements without a configured emoji are still shown as a
+// badge, just without the emoji.
+func renderBadges(items []string) string {
+	var badges []string
+	for _, item := range items {
+		badge := fmt.Sprintf("`%s`", item)
+		if name, ok := entitlementBadges[foldCase(item)]; ok {
+			if e := emoji(name); e != "" {
+				badge = fmt.Sprintf("%s %s", e, badge)
+			}
+		}
+		badges = append(badges, badge)
+	}
+	return strings.Join(badges, "  ")
+}
+
+// Function to render the footer naming the Airtable base and table the
+// results came from, using the human-readable names configured for their
+// IDs. No footer is shown unless a name has been configured.
+func sourceFooter() string {
+	base, baseOK := sourceNames[airtableBaseID]
+	table, tableOK := sourceNames[airtableTableID]
+	if !baseOK && !tableOK {
+		return ""
+	}
+	if !baseOK {
+		base = airtableBaseID
+	}
+	if !tableOK {
+		table = airtableTableID
+	}
+	return fmt.Sprintf("%s › %s", base, table)
+}
+
+// Tips shown when a search finds nothing, explaining how to refine it.
+var tips = []string{
+	"Wrap words in quotes to search for an exact phrase, such as `\"single sign on\"`",
+	"Search a single field with its name, such as `plan:enterprise` or `team:billing`",
+	"Leave out features matching a word by putting `-` in front of it, such as `billing -legacy`",
+}
+
+// Function to build the attachment holding the search tips.
+func tipsAttachment() attachment {
+	var value string
+	for _, t := range tips {
+		value += fmt.Sprintf("• %s%s", t, lineDelimiter)
+	}
+	return attachment{
+		Title:    "Search tips",
+		Fallback: value,
+		Fields: []attachmentField{
+			{
+				Title: "",
+				Value: value,
+			},
+		},
+	}
+}
+
+// Function to add the configured call to action, such as where to ask
+// questions about the results, to the bottom of a response. Nothing is
+// added when no call to action has been configured.
+func appendCallToAction(res *slackResponse) {
+	if callToAction == "" {
+		return
+	}
+	res.Attachments = append(res.Attachments, attachment{
+		Fallback: callToAction,
+		Blocks: []block{{
+			Type:     "context",
+			Elements: []interface{}{textObject{Type: "mrkdwn", Text: callToAction}},
+		}},
+	})
+}
+
+// Function to build the block of buttons shown under a feature. Nil is
+// returned when no buttons are enabled.
+func actionsBlock(f feature) *block {
+	var elements []interface{}
+	if copyLinkButton {
+		elements = append(elements, blockElement{
+			Type:     "button",
+			ActionID: copyLinkActionID,
+			Text:     &textObject{Type: "plain_text", Text: "Copy link"},
+			Value:    featureLink(f.AirtableID),
+		})
+	}
+	if reportButton {
+		value, err := json.Marshal(reportValue{
+			ID:      f.AirtableID,
+			Feature: f.Fields.Feature,
+			Link:    featureLink(f.AirtableID),
+		})
+		if err == nil {
+			elements = append(elements, blockElement{
+				Type:     "button",
+				ActionID: reportActionID,
+				Text:     &textObject{Type: "plain_text", Text: "Report incorrect data"},
+				Value:    string(value),
+			})
+		}
+	}
+	if subscribeButton {
+		value, err := json.Marshal(subscribeValue{
+			ID:      f.AirtableID,
+			Feature: f.Fields.Feature,
+			Roadmap: f.Fields.Roadmap,
+		})
+		if err == nil {
+			elements = append(elements, blockElement{
+				Type:     "button",
+				ActionID: subscribeActionID,
+				Text:     &textObject{Type: "plain_text", Text: "Subscribe to updates"},
+				Value:    string(value),
+			})
+		}
+	}
+
+	if len(elements) == 0 {
+		return nil
+	}
+	return &block{
+		Type:     "actions",
+		Elements: elements,
+	}
+}
+
+// Function to render a single line of a feature's details in Slack
+// markdown, prefixed with an emoji when one is available.
+func fieldLine(emojiName, label, value string) string {
+	if e := emoji(emojiName); e != "" {
+		return fmt.Sprintf("%s *%s:* %s%s", e, label, value, lineDelimiter)
+	}
+	return fmt.Sprintf("*%s:* %s%s", label, value, lineDelimiter)
+}
+
+// Function to render the title of a short field, prefixed with an emoji
+// when one is available.
+func fieldLabel(emojiName, label string) string {
+	if e := emoji(emojiName); e != "" {
+		return fmt.Sprintf("%s %s", e, label)
+	}
+	return label
+}
+
+// Function to render an emoji by name. Custom emoji marked as unavailable
+// in the workspace are replaced with their configured fallback instead.
+func emoji(name string) string {
+	if unavailableEmoji[name] {
+		return emojiFallbacks[name]
+	}
+	return fmt.Sprintf(":%s:", name)
+}
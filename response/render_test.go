@@ -0,0 +1,766 @@
+package response
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmojiFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		unavailable string
+		fallbacks   string
+		want        string
+		wantLine    string
+	}{
+		{"available custom emoji", "", "", ":one-team:", ":one-team: *Team(s):* Identity\n"},
+		{"default fallback", ":one-team:", "", ":busts_in_silhouette:", ":busts_in_silhouette: *Team(s):* Identity\n"},
+		{"configured fallback", "one-team", "one-team=👥", "👥", "👥 *Team(s):* Identity\n"},
+		{"plain text fallback", "one-team", "one-team=", "", "*Team(s):* Identity\n"},
+		{"fallback configured with colons", "one-team", ":one-team:=:people_holding_hands:", ":people_holding_hands:", ":people_holding_hands: *Team(s):* Identity\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_UNAVAILABLE_EMOJI": tt.unavailable,
+				"SLACK_EMOJI_FALLBACKS":   tt.fallbacks,
+				"FIELD_LINE_DELIMITER":    "",
+			})()
+			if got := emoji("one-team"); got != tt.want {
+				t.Errorf("emoji() = %q, want %q", got, tt.want)
+			}
+			if got := fieldLine("one-team", "Team(s)", "Identity"); got != tt.wantLine {
+				t.Errorf("fieldLine() = %q, want %q", got, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestCompactFields(t *testing.T) {
+	defer func(c bool, d string) { compactFields, lineDelimiter = c, d }(compactFields, lineDelimiter)
+	lineDelimiter = "\n"
+
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature": "Single sign-on", "Roadmap": "Shipped", "Plan": "Enterprise",
+	}})[0]
+	tests := []struct {
+		name    string
+		compact bool
+		want    []attachmentField
+	}{
+		{"full layout", false, []attachmentField{{Value: ":sparkles: *Roadmap:* Shipped\n:moneybag: *Plan:* Enterprise\n"}}},
+		{"compact layout", true, []attachmentField{
+			{Title: ":sparkles: Roadmap", Value: "Shipped", Short: true},
+			{Title: ":moneybag: Plan", Value: "Enterprise", Short: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compactFields = tt.compact
+			if got := renderFields(f, searchRequest{}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("renderFields() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppLinks(t *testing.T) {
+	defer func(a bool, u, tbl, v string) {
+		appLinks, trackingURL, airtableTableID, airtableViewID = a, u, tbl, v
+	}(appLinks, trackingURL, airtableTableID, airtableViewID)
+	trackingURL, airtableTableID, airtableViewID = "", "tblFeatures", "viwAll"
+
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "Single sign-on"}})[0]
+	web := "<https://airtable.com/tblFeatures/viwAll/recSso00000000001|Open on the web>"
+	app := "<airtable://airtable.com/tblFeatures/viwAll/recSso00000000001|Open in app>"
+	tests := []struct {
+		name     string
+		appLinks bool
+		want     bool
+	}{
+		{"both links when enabled", true, true},
+		{"no links when disabled", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appLinks = tt.appLinks
+			value := renderFields(f, searchRequest{})[0].Value
+			if got := strings.Contains(value, web) && strings.Contains(value, app); got != tt.want {
+				t.Errorf("fields = %q, want both links %t", value, tt.want)
+			}
+		})
+	}
+}
+
+func TestBulletFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		bullets string
+		field   string
+		value   string
+		want    string
+	}{
+		{"comma-separated value as bullets", "docs", "External documentation", "Guide, API reference", "\n• Guide\n• API reference"},
+		{"newline-separated value as bullets", "docs", "External documentation", "Guide\nAPI reference", "\n• Guide\n• API reference"},
+		{"other fields left as they are", "docs", "Plan", "Team, Enterprise", "Team, Enterprise"},
+		{"nothing configured", "", "External documentation", "Guide, API reference", "Guide, API reference"},
+		{"empty values stay empty", "docs", "External documentation", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"BULLET_FIELDS": tt.bullets, "FIELD_LINE_DELIMITER": ""})()
+			if got := formatValue(tt.field, tt.value); got != tt.want {
+				t.Errorf("formatValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyLinkButton(t *testing.T) {
+	defer func(c, r, s bool, tbl, v string) {
+		copyLinkButton, reportButton, subscribeButton, airtableTableID, airtableViewID = c, r, s, tbl, v
+	}(copyLinkButton, reportButton, subscribeButton, airtableTableID, airtableViewID)
+	reportButton, subscribeButton = false, false
+	airtableTableID, airtableViewID = "tblFeatures", "viwAll"
+
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "Single sign-on"}})[0]
+	tests := []struct {
+		name    string
+		enabled bool
+		want    []interface{}
+	}{
+		{"button carries the feature link", true, []interface{}{blockElement{
+			Type:     "button",
+			ActionID: copyLinkActionID,
+			Text:     &textObject{Type: "plain_text", Text: "Copy link"},
+			Value:    "https://airtable.com/tblFeatures/viwAll/recSso00000000001",
+		}}},
+		{"no button when disabled", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copyLinkButton = tt.enabled
+			var got []interface{}
+			if b := actionsBlock(f); b != nil {
+				got = b.Elements
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actionsBlock() elements = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestFieldsFollowRenderConfig(t *testing.T) {
+	all := []string{"Feature", "Roadmap", "Team responsible", "Plan", "Feature flag", "Entitlements", "External documentation"}
+	tests := []struct {
+		name  string
+		env   map[string]string
+		query string
+		want  []string
+	}{
+		{"every displayed field", nil, "sso", all},
+		{"brief results only need names", nil, "brief sso", []string{"Feature"}},
+		{"breakdowns only need teams", nil, "breakdown sso", []string{"Feature", "Team responsible"}},
+		{"hidden fields aren't requested", map[string]string{"CHANNEL_HIDDEN_FIELDS": "C0123456789=flag|entitlements"}, "sso",
+			[]string{"Feature", "Roadmap", "Team responsible", "Plan", "External documentation"}},
+		{"only the displayed fields", map[string]string{"DISPLAY_FIELDS": "plan,roadmap"}, "sso", []string{"Feature", "Roadmap", "Plan"}},
+		{"status emoji need the roadmap", map[string]string{"ROADMAP_STATUS_EMOJI": "shipped=:white_check_mark:"}, "brief sso", []string{"Feature", "Roadmap"}},
+		{"plan tier sort needs the plan", map[string]string{"RESULT_SORT": "plan", "DISPLAY_FIELDS": "roadmap"}, "sso", []string{"Feature", "Roadmap", "Plan"}},
+		{"last modified fields", map[string]string{"AIRTABLE_LAST_MODIFIED_FIELD": "Modified", "AIRTABLE_LAST_MODIFIED_BY_FIELD": "Editor"}, "sso",
+			append(append([]string(nil), all...), "Modified", "Editor")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"CHANNEL_HIDDEN_FIELDS":           "",
+				"DISPLAY_FIELDS":                  "",
+				"ROADMAP_STATUS_EMOJI":            "",
+				"RESULT_SORT":                     "",
+				"AIRTABLE_LAST_MODIFIED_FIELD":    "",
+				"AIRTABLE_LAST_MODIFIED_BY_FIELD": "",
+				"QUERY_CACHE_TTL":                 "",
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			defer useEnv(t, env)()
+			a := newFakeAirtable(nil)
+			defer useFakeAirtable(a)()
+
+			search := parseQuery(tt.query)
+			search.ChannelID = "C0123456789"
+			if _, err := queryAirtable(search); err != nil {
+				t.Fatalf("queryAirtable() error = %v", err)
+			}
+			if len(a.queries) != 1 || !reflect.DeepEqual(a.queries[0].Fields, tt.want) {
+				t.Errorf("requested fields %+v, want %v", a.queries, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoadmapStatusEmoji(t *testing.T) {
+	defer useEnv(t, map[string]string{
+		"ROADMAP_STATUS_EMOJI":   "shipped=:white_check_mark:,not shipped=:x:,In Progress=:construction:,planned=:clipboard:",
+		"SLACK_TITLE_MAX_LENGTH": "",
+	})()
+
+	tests := []struct {
+		roadmap string
+		want    string
+	}{
+		{"Shipped in Q2", ":white_check_mark: Single sign-on"},
+		{"In progress", ":construction: Single sign-on"},
+		{"planned for Q4", ":clipboard: Single sign-on"},
+		{"Not shipped yet", ":x: Single sign-on"},
+		{"Under review", "Single sign-on"},
+		{"", "Single sign-on"},
+	}
+	for _, tt := range tests {
+		f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "Single sign-on", "Roadmap": tt.roadmap}})[0]
+		if got := featureTitle(f); got != tt.want {
+			t.Errorf("featureTitle() with roadmap %q = %q, want %q", tt.roadmap, got, tt.want)
+		}
+	}
+}
+
+func TestFieldLineDelimiter(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature": "Single sign-on", "Roadmap": "Shipped", "Plan": "Enterprise",
+	}})[0]
+	tests := []struct {
+		name      string
+		delimiter string
+		want      string
+	}{
+		{"new line by default", "", ":sparkles: *Roadmap:* Shipped\n:moneybag: *Plan:* Enterprise\n"},
+		{"escaped carriage return", `\r\n`, ":sparkles: *Roadmap:* Shipped\r\n:moneybag: *Plan:* Enterprise\r\n"},
+		{"literal delimiter", " | ", ":sparkles: *Roadmap:* Shipped | :moneybag: *Plan:* Enterprise | "},
+		{"invalid escape falls back", `\q`, ":sparkles: *Roadmap:* Shipped\n:moneybag: *Plan:* Enterprise\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"FIELD_LINE_DELIMITER": tt.delimiter, "SLACK_COMPACT_FIELDS": ""})()
+			if got := renderFields(f, searchRequest{})[0].Value; got != tt.want {
+				t.Errorf("renderFields() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberedResults(t *testing.T) {
+	defer func(n, r bool, p int) { numberResults, richTextList, pageSize = n, r, p }(numberResults, richTextList, pageSize)
+	richTextList = false
+
+	var records []map[string]interface{}
+	for _, name := range []string{"Audit logs", "Billing", "Custom roles", "Dashboards", "Exports"} {
+		records = append(records, map[string]interface{}{"id": "rec" + name, "fields": map[string]interface{}{"Feature": name}})
+	}
+	f := testFeatures(t, records...)
+
+	tests := []struct {
+		name     string
+		numbered bool
+		pageSize int
+		page     int
+		want     []string
+	}{
+		{"numbered", true, 0, 0, []string{"1. Audit logs", "2. Billing", "3. Custom roles", "4. Dashboards", "5. Exports"}},
+		{"numbering continues on later pages", true, 2, 2, []string{"3. Custom roles", "4. Dashboards"}},
+		{"not numbered", false, 0, 0, []string{"Audit logs", "Billing", "Custom roles", "Dashboards", "Exports"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			numberResults, pageSize = tt.numbered, tt.pageSize
+			search := parseQuery("")
+			search.Page = tt.page
+			res, err := buildSlackResponse(f, search)
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			var titles []string
+			for _, a := range res.Attachments {
+				if a.TitleLink != "" {
+					titles = append(titles, a.Title)
+					if !strings.HasPrefix(a.Fallback, a.Title+": ") {
+						t.Errorf("fallback %q isn't numbered like the title %q", a.Fallback, a.Title)
+					}
+				}
+			}
+			if !reflect.DeepEqual(titles, tt.want) {
+				t.Errorf("titles = %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldOrder(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature":                "SSO",
+		"Roadmap":                "Q3",
+		"Team responsible":       "Identity",
+		"Plan":                   "Enterprise",
+		"Feature flag":           "sso-beta",
+		"Entitlements":           "sso",
+		"External documentation": "https://docs.example.com/sso",
+	}})[0]
+
+	tests := []struct {
+		name  string
+		order string
+		want  []string
+	}{
+		{"default order", "", []string{"Roadmap", "Team(s)", "Plan", "Feature Flag", "Entitlements", "External Documentation"}},
+		{"custom order", "docs, plan,FLAG", []string{"External Documentation", "Plan", "Feature Flag", "Roadmap", "Team(s)", "Entitlements"}},
+		{"unknown and repeated fields are ignored", "plan,nope,plan", []string{"Plan", "Roadmap", "Team(s)", "Feature Flag", "Entitlements", "External Documentation"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"FIELD_ORDER":             tt.order,
+				"SLACK_COMPACT_FIELDS":    "true",
+				"SLACK_MAX_FIELD_LINES":   "",
+				"SLACK_UNAVAILABLE_EMOJI": "",
+			})()
+			var got []string
+			for _, field := range renderFields(f, searchRequest{}) {
+				got = append(got, field.Title[strings.Index(field.Title, " ")+1:])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rendered fields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestFieldsIncludeScoredFields(t *testing.T) {
+	defer func(c string, m int, s string, h map[string][]string) {
+		searchColumn, minScore, resultSort, channelHiddenFields = c, m, s, h
+	}(searchColumn, minScore, resultSort, channelHiddenFields)
+	channelHiddenFields = parseScopes("C0123456789=flag")
+
+	tests := []struct {
+		name      string
+		column    string
+		minScore  int
+		sort      string
+		query     string
+		want      string
+		wantFetch bool
+	}{
+		{"search column scored by minimum score", "Summary", 1, "", "sso", "Summary", true},
+		{"search column scored by relevance", "Summary", 0, sortRelevance, "brief sso", "Summary", true},
+		{"scoped term in a hidden field", "", 1, "", "flag:beta", "Feature flag", true},
+		{"scoped term in a brief search", "", 1, "", "brief flag:beta", "Feature flag", true},
+		{"nothing scored without scoring", "Summary", 0, "", "brief sso", "Summary", false},
+		{"nothing scored without terms", "Summary", 1, "", "brief flagged:true", "Summary", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searchColumn, minScore, resultSort = tt.column, tt.minScore, tt.sort
+			search := parseQuery(tt.query)
+			search.ChannelID = "C0123456789"
+			if got := containsString(requestFields(search), tt.want); got != tt.wantFetch {
+				t.Errorf("requestFields(%q) = %v, want %q requested %v", tt.query, requestFields(search), tt.want, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestReportButton(t *testing.T) {
+	defer func(c, r bool, tbl, v string) {
+		copyLinkButton, reportButton, airtableTableID, airtableViewID = c, r, tbl, v
+	}(copyLinkButton, reportButton, airtableTableID, airtableViewID)
+	copyLinkButton, reportButton = false, true
+	airtableTableID, airtableViewID = "tblFeatures", "viwAll"
+
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "Single sign-on"}})[0]
+	b := actionsBlock(f)
+	if b == nil || len(b.Elements) != 1 {
+		t.Fatalf("actionsBlock() = %+v, want the report button", b)
+	}
+	button := b.Elements[0].(blockElement)
+	var got reportValue
+	if err := json.Unmarshal([]byte(button.Value), &got); err != nil {
+		t.Fatalf("button value %q isn't JSON: %v", button.Value, err)
+	}
+	want := reportValue{ID: "recSso00000000001", Feature: "Single sign-on", Link: "https://airtable.com/tblFeatures/viwAll/recSso00000000001"}
+	if button.ActionID != reportActionID || got != want {
+		t.Errorf("button %s carries %+v, want %s carrying %+v", button.ActionID, got, reportActionID, want)
+	}
+}
+
+func TestSourceFooter(t *testing.T) {
+	tests := []struct {
+		name  string
+		names string
+		want  string
+	}{
+		{"base and table named", "appProduct=Product, tblFeatures = Features", "Product › Features"},
+		{"only the base named", "appProduct=Product", "Product › tblFeatures"},
+		{"only the table named", "tblFeatures=Features", "appProduct › Features"},
+		{"names for other bases", "appOther=Other,tblOther=Others", ""},
+		{"nothing named", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"AIRTABLE_BASE_ID":      "appProduct",
+				"AIRTABLE_TABLE_ID":     "tblFeatures",
+				"AIRTABLE_SOURCE_NAMES": tt.names,
+			})()
+			if got := sourceFooter(); got != tt.want {
+				t.Errorf("sourceFooter() = %q, want %q", got, tt.want)
+			}
+
+			f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO"}})
+			res, err := buildSlackResponse(f, parseQuery("sso"))
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			for _, a := range res.Attachments {
+				if a.TitleLink != "" && a.Footer != tt.want {
+					t.Errorf("attachment footer = %q, want %q", a.Footer, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHeaderStyle(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO"}})
+	tests := []struct {
+		name  string
+		emoji string
+		bold  string
+		want  string
+	}{
+		{"plain", "", "", "Found 1 items! Click on any result to learn more."},
+		{"emoji", " :mag: ", "", ":mag: Found 1 items! Click on any result to learn more."},
+		{"bold", "", "true", "*Found 1 items! Click on any result to learn more.*"},
+		{"emoji and bold", ":mag:", "true", ":mag: *Found 1 items! Click on any result to learn more.*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"SLACK_HEADER_EMOJI": tt.emoji, "SLACK_HEADER_BOLD": tt.bold})()
+			res, err := buildSlackResponse(f, parseQuery("sso"))
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			if res.Text != tt.want {
+				t.Errorf("header = %q, want %q", res.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchTips(t *testing.T) {
+	records := map[string]map[string]interface{}{"recSso00000000001": {"Feature": "SSO"}}
+	found := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": records["recSso00000000001"]})
+
+	tests := []struct {
+		name     string
+		enabled  string
+		features []feature
+		want     bool
+	}{
+		{"zero results", "true", nil, true},
+		{"some results", "true", found, false},
+		{"tips turned off", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"SLACK_SEARCH_TIPS": tt.enabled})()
+			defer useFakeAirtable(newFakeAirtable(records))()
+			resetNameCache()
+			defer resetNameCache()
+
+			res, err := buildSlackResponse(tt.features, parseQuery("zzz"))
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			var got bool
+			for _, a := range res.Attachments {
+				if a.Title == "Search tips" {
+					got = true
+					for _, syntax := range []string{"exact phrase", "plan:enterprise", "`billing -legacy`"} {
+						if !strings.Contains(a.Fields[0].Value, syntax) {
+							t.Errorf("tips %q don't explain %q", a.Fields[0].Value, syntax)
+						}
+					}
+				}
+			}
+			if got != tt.want {
+				t.Errorf("tips shown = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTitleTruncation(t *testing.T) {
+	const name = "Single sign-on with SAML for every workspace"
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": name}})
+
+	tests := []struct {
+		name   string
+		length string
+		want   string
+	}{
+		{"truncated with an ellipsis", "20", "Single sign-on with…"},
+		{"trailing space trimmed before the ellipsis", "16", "Single sign-on…"},
+		{"very short limit", "3", "Si…"},
+		{"short enough", "100", name},
+		{"no limit", "", name},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"SLACK_TITLE_MAX_LENGTH": tt.length, "SLACK_NUMBER_RESULTS": "", "SLACK_RICH_TEXT_LIST": ""})()
+			res, err := buildSlackResponse(f, parseQuery("sso"))
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			for _, a := range res.Attachments {
+				if a.TitleLink == "" {
+					continue
+				}
+				if a.Title != tt.want {
+					t.Errorf("title = %q, want %q", a.Title, tt.want)
+				}
+				if !strings.HasPrefix(a.Fallback, name+": ") {
+					t.Errorf("fallback = %q, want the full name", a.Fallback)
+				}
+			}
+		})
+	}
+}
+
+func TestEntitlementBadges(t *testing.T) {
+	tests := []struct {
+		name   string
+		badges string
+		value  string
+		want   string
+	}{
+		{"separate badges", "sso=:lock:,audit logs=scroll", "SSO, Audit Logs\nexports", ":lock: `SSO`  :scroll: `Audit Logs`  `exports`"},
+		{"no badges configured", "", "SSO, Audit Logs", "SSO, Audit Logs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"ENTITLEMENT_BADGES": tt.badges, "SLACK_UNAVAILABLE_EMOJI": "", "BULLET_FIELDS": ""})()
+			if got := formatValue("Entitlements", tt.value); got != tt.want {
+				t.Errorf("formatValue() = %q, want %q", got, tt.want)
+			}
+			if got := formatValue("Plan", tt.value); strings.Contains(got, "`") {
+				t.Errorf("formatValue() of another field = %q, want no badges", got)
+			}
+		})
+	}
+}
+
+func TestMatchedFieldsBlock(t *testing.T) {
+	defer func(f []string, c string) { searchFields, searchColumn = f, c }(searchFields, searchColumn)
+
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature":                "SSO",
+		"Team responsible":       "Identity",
+		"External documentation": "https://docs.example.com/sso",
+	}})[0]
+	tests := []struct {
+		name    string
+		enabled string
+		query   string
+		channel string
+		want    string
+	}{
+		{"name and documentation", "true", "sso", "", "Matched in Feature, External Documentation"},
+		{"scoped term", "true", "team:identity", "", "Matched in Team(s)"},
+		{"each field listed once", "true", "sso docs:example", "", "Matched in Feature, External Documentation"},
+		{"field hidden in the channel", "true", "sso", "CPUBLIC0000", "Matched in Feature"},
+		{"only a hidden field matched", "true", "team:identity", "CPUBLIC0000", ""},
+		{"filter on its own", "true", "flagged:false", "", ""},
+		{"turned off", "", "sso", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_MATCHED_FIELDS":   tt.enabled,
+				"AIRTABLE_SEARCH_COLUMN": "",
+				"CHANNEL_HIDDEN_FIELDS":  "CPUBLIC0000=docs|team",
+			})()
+			defer useFakeAirtable(newFakeAirtable(nil))()
+			searchFields = []string{"Feature", "Team responsible", "External documentation"}
+
+			search := parseQuery(tt.query)
+			search.ChannelID = tt.channel
+			res, err := buildSlackResponse([]feature{f}, search)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, a := range res.Attachments {
+				for _, b := range a.Blocks {
+					if b.Type != "context" {
+						continue
+					}
+					for _, e := range b.Elements {
+						if el, ok := e.(textObject); ok && strings.HasPrefix(el.Text, "Matched in") {
+							got = el.Text
+						}
+					}
+				}
+			}
+			if got != tt.want {
+				t.Errorf("matched fields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxFieldLines(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+		"Feature":                "SSO",
+		"Roadmap":                "Q3",
+		"Team responsible":       "Identity",
+		"Plan":                   "Enterprise",
+		"Feature flag":           "sso-beta",
+		"Entitlements":           "sso",
+		"External documentation": "https://docs.example.com/sso",
+	}})[0]
+
+	tests := []struct {
+		name     string
+		max      string
+		compact  string
+		want     int
+		wantMore string
+	}{
+		{"unlimited", "", "true", 6, ""},
+		{"capped", "2", "true", 2, "_…4 fields not shown, open the feature to see them all_"},
+		{"one over", "5", "true", 5, "_…1 field not shown, open the feature to see them all_"},
+		{"cap above the populated fields", "10", "true", 6, ""},
+		{"capped in a single field", "2", "", 2, "_…4 fields not shown, open the feature to see them all_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_MAX_FIELD_LINES":   tt.max,
+				"SLACK_COMPACT_FIELDS":    tt.compact,
+				"FIELD_ORDER":             "",
+				"SLACK_UNAVAILABLE_EMOJI": "",
+			})()
+			fields := renderFields(f, searchRequest{})
+
+			var lines []string
+			var more string
+			for _, field := range fields {
+				for _, line := range strings.Split(strings.TrimSpace(field.Value), "\n") {
+					if strings.HasPrefix(line, "_…") {
+						more = line
+					} else {
+						lines = append(lines, line)
+					}
+				}
+			}
+			if len(lines) != tt.want {
+				t.Errorf("rendered %d field lines %q, want %d", len(lines), lines, tt.want)
+			}
+			if more != tt.wantMore {
+				t.Errorf("indicator = %q, want %q", more, tt.wantMore)
+			}
+		})
+	}
+}
+
+func TestLastEditor(t *testing.T) {
+	modified := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name          string
+		modifiedField string
+		editorField   string
+		editor        string
+		want          string
+	}{
+		{"editor and time", "Last modified", "Last modified by", "Ada Lovelace", "3 hours ago by Ada Lovelace"},
+		{"editor without time", "", "Last modified by", "Ada Lovelace", "by Ada Lovelace"},
+		{"editor field empty", "Last modified", "Last modified by", "", "3 hours ago"},
+		{"editor not tracked", "Last modified", "", "Ada Lovelace", "3 hours ago"},
+		{"nothing tracked", "", "", "Ada Lovelace", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"AIRTABLE_LAST_MODIFIED_FIELD":    tt.modifiedField,
+				"AIRTABLE_LAST_MODIFIED_BY_FIELD": tt.editorField,
+				"SLACK_COMPACT_FIELDS":            "true",
+				"SLACK_MAX_FIELD_LINES":           "",
+			})()
+			f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{
+				"Feature":          "SSO",
+				"Roadmap":          "Q3",
+				"Last modified":    modified,
+				"Last modified by": tt.editor,
+			}})[0]
+
+			var got string
+			for _, field := range renderFields(f, searchRequest{}) {
+				if strings.HasSuffix(field.Title, "Last updated") {
+					got = field.Value
+				}
+			}
+			if got != tt.want {
+				t.Errorf("last updated = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallToAction(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO"}})
+	tests := []struct {
+		name     string
+		cta      string
+		features []feature
+		want     bool
+	}{
+		{"with results", "Questions? Ask in #product", f, true},
+		{"without results", "Questions? Ask in #product", nil, true},
+		{"padded", "  Questions? Ask in #product ", f, true},
+		{"turned off", "", f, false},
+	}
+	const cta = "Questions? Ask in #product"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"SLACK_CALL_TO_ACTION": tt.cta, "SLACK_SHARE_BUTTON": "true"})()
+			defer useFakeAirtable(newFakeAirtable(nil))()
+			res, err := buildSlackResponse(tt.features, parseQuery("sso"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var count int
+			for _, a := range res.Attachments {
+				for _, b := range a.Blocks {
+					for _, e := range b.Elements {
+						if el, ok := e.(textObject); ok && b.Type == "context" && el.Text == cta {
+							count++
+						}
+					}
+				}
+			}
+			want := 0
+			if tt.want {
+				want = 1
+			}
+			if count != want {
+				t.Fatalf("call to action appears %d times, want %d", count, want)
+			}
+			if last := res.Attachments[len(res.Attachments)-1]; tt.want && last.Fallback != cta {
+				t.Errorf("last attachment = %+v, want the call to action at the bottom", last)
+			}
+		})
+	}
+}
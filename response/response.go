@@ -3,27 +3,198 @@ package response
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/smfsh/airtable-go"
 )
 
-// Variables used for the Airtable connection.
+// Version of Anerbot running, set at build time with
+// `-ldflags "-X github.com/smfsh/anerbot/response.Version=v2.1.0"`.
+var Version = "dev"
+
+// Variables used for the Airtable connection. The endpoint replaces the
+// scheme and host of the Airtable API, such as for a proxy, when set. The
+// time zone is the IANA name Airtable formats dates in, loaded as the
+// location timestamps returned by Airtable are read in.
+var (
+	airtableAPIKey   string
+	airtableBaseID   string
+	airtableTableID  string
+	airtableViewID   string
+	airtableEndpoint *url.URL
+	airtableTimeZone string
+	airtableLocation *time.Location
+)
+
+// Time zone Airtable formats dates in unless another is configured.
+const defaultTimeZone = "America/New_York"
+
+// Variables used for Slack validation. Response hosts are the hosts
+// results may be posted to, and dev mode also allows localhost so the
+// functions can be tested locally. The bot token is used to send direct
+// messages, such as subscription notifications.
+var (
+	slackSigSecret string
+	responseHosts  []string
+	devMode        bool
+	slackBotToken  string
+)
+
+// Variables used for outbound requests to Slack and Airtable. Requests
+// rate limited by Airtable are retried up to the number of retries,
+// waiting for the backoff when Airtable doesn't say how long to wait.
+// Each request to Slack gives up after the Slack timeout, so a slow
+// Slack can't use up the time left for searching Airtable, and each
+// request to Airtable, retries included, gives up after the Airtable
+// timeout.
+var (
+	userAgent        string
+	rateLimitRetries int
+	rateLimitBackoff time.Duration
+	slackTimeout     time.Duration
+	airtableTimeout  time.Duration
+)
+
+// Variables used for logging. When queries are redacted, only a hash
+// of each query is logged. Error codes are always logged, and are also
+// shown to users in failure messages when enabled.
+var (
+	redactQueries  bool
+	showErrorCodes bool
+)
+
+// Variables used to control how the Airtable search is performed. When
+// a search column is set, unscoped terms are only matched against that
+// single column, such as a formula field joining every searchable field.
+var (
+	caseSensitive        bool
+	maxQueryTokens       int
+	nameRefreshInterval  time.Duration
+	channelScopes        map[string][]string
+	searchColumn         string
+	maxConcurrentQueries int
+	minScore             int
+	trimPunctuation      string
+	queryCacheTTL        time.Duration
+	fieldWeights         map[string]int
+	quarterField         string
+	maxDigestQueries     int
+	stemming             bool
+	userTeams            map[string]string
+	clarifyScopes        bool
+	minAndTokenLength    int
+	stripSearchPrefix    bool
+)
+
+// Variables used to control how results are displayed in Slack.
+var (
+	resultSort          string
+	compactFields       bool
+	featureSelect       bool
+	appLinks            bool
+	copyLinkButton      bool
+	planTierRanks       map[string]int
+	bulletFields        map[string]bool
+	shownFields         map[string]bool
+	statusEmoji         map[string]string
+	maxPayloadBytes     int
+	lineDelimiter       string
+	lastModifiedField   string
+	numberResults       bool
+	resultGroup         string
+	visibilityRules     []visibilityRule
+	reportButton        bool
+	sourceNames         map[string]string
+	shareButton         bool
+	displayLanguage     string
+	headerEmoji         string
+	boldHeader          bool
+	imageFields         []string
+	duplicateMode       string
+	channelHiddenFields map[string][]string
+	searchTips          bool
+	richTextList        bool
+	maxTitleLength      int
+	entitlementBadges   map[string]string
+	maxResponseMessages int
+	viewAllButton       bool
+	showMatches         bool
+	subscribeButton     bool
+	maxFieldLines       int
+	lastModifiedByField string
+	callToAction        string
+	teamMentions        map[string]string
+	collapseFields      bool
+	pageSize            int
+	exposedFields       map[string]bool
+	exposeAllFields     bool
+)
+
+// Fields of a feature that are searched in Airtable.
+var searchFields = []string{
+	"Feature",
+	"Roadmap",
+	"Team responsible",
+	"Plan",
+	"Feature flag",
+	"Entitlements",
+	"External documentation",
+}
+
+// Variables used for answering permalinks to searches. Permalinks are
+// signed with the permalink secret, which is shared with the
+// anerbot-queue function generating them.
+var (
+	permalinkSecret string
+)
+
+// Secret the Cloud Scheduler job polling subscriptions sends as a bearer
+// token, so that only it can have subscribers notified.
+var pollSecret string
+
+// Variables used for tracking clicks on feature links. Links to the click
+// tracker are signed with the tracking secret so that only links Anerbot
+// posted are counted, and each source only has so many clicks a minute
+// counted.
+var (
+	trackingURL       string
+	trackingSecret    string
+	trackingRateLimit int
+)
+
+// Pattern matching a valid Airtable record ID.
+var recordIDPattern = regexp.MustCompile(`^rec[A-Za-z0-9]{14}$`)
+
+// Variables used for rendering emoji in Slack. Custom emoji listed as
+// unavailable are swapped for their fallback, which may be a standard
+// emoji, a unicode character or an empty string for plain text.
 var (
-	airtableAPIKey  string
-	airtableBaseID  string
-	airtableTableID string
-	airtableViewID  string
+	unavailableEmoji map[string]bool
+	emojiFallbacks   map[string]string
 )
 
+// Fallbacks used for custom emoji unless others are configured.
+var defaultEmojiFallbacks = map[string]string{
+	"one-team": ":busts_in_silhouette:",
+}
+
 // Struct to contain each "feature" returned from an Airtable query.
+// Extra holds every field returned, by name, so that fields whose
+// names are configured rather than fixed can still be looked up.
 type feature struct {
 	AirtableID string `json:"id"`
 	Fields     struct {
@@ -35,6 +206,57 @@ type feature struct {
 		Entitlements          string
 		ExternalDocumentation string `json:"External documentation"`
 	}
+	Extra map[string]string `json:"-"`
+}
+
+// Function to unmarshal a feature returned from Airtable, filling in
+// every field by name into Extra and the known fields from those values.
+// Fields holding several values, such as a multiple select of teams, are
+// joined into a comma-separated list.
+func (f *feature) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		ID     string                 `json:"id"`
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	extra := make(map[string]string)
+	for k, v := range raw.Fields {
+		extra[k] = fieldString(v)
+	}
+
+	// Unmarshal into a type without this method to avoid recursion.
+	type plainFeature feature
+	var p plainFeature
+	normalized, err := json.Marshal(map[string]interface{}{"id": raw.ID, "fields": extra})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(normalized, &p); err != nil {
+		return err
+	}
+
+	*f = feature(p)
+	f.Extra = extra
+	return nil
+}
+
+// Function to convert the value of a field returned from Airtable to a
+// string. Lists of values are joined with commas.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, fieldString(item))
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprint(v)
 }
 
 // Struct for the message to be sent to Slack.
@@ -54,14 +276,54 @@ type attachment struct {
 	Fallback  string            `json:"fallback"`
 	TitleLink string            `json:"title_link"`
 	Fields    []attachmentField `json:"fields"`
+	Blocks    []block           `json:"blocks,omitempty"`
+	Footer    string            `json:"footer,omitempty"`
+}
+
+// Struct for a Block Kit layout block. Only the properties used
+// by Anerbot are included.
+type block struct {
+	Type           string        `json:"type"`
+	BlockID        string        `json:"block_id,omitempty"`
+	Text           *textObject   `json:"text,omitempty"`
+	Elements       []interface{} `json:"elements,omitempty"`
+	ImageURL       string        `json:"image_url,omitempty"`
+	AltText        string        `json:"alt_text,omitempty"`
+	Accessory      interface{}   `json:"accessory,omitempty"`
+	Label          *textObject   `json:"label,omitempty"`
+	Element        interface{}   `json:"element,omitempty"`
+	DispatchAction bool          `json:"dispatch_action,omitempty"`
+}
+
+// Struct for a Block Kit text object, used for both plain text
+// and markdown.
+type textObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Struct for an interactive Block Kit element such as a button
+// or a select menu.
+type blockElement struct {
+	Type           string         `json:"type"`
+	ActionID       string         `json:"action_id,omitempty"`
+	Text           *textObject    `json:"text,omitempty"`
+	Placeholder    *textObject    `json:"placeholder,omitempty"`
+	Value          string         `json:"value,omitempty"`
+	URL            string         `json:"url,omitempty"`
+	Options        []selectOption `json:"options,omitempty"`
+	MinQueryLength *int           `json:"min_query_length,omitempty"`
 }
 
 // Struct to represent the information printed to the requester
-// in Slack for each "feature". The title field should always
-// be blank and value will always contain markdown.
+// in Slack for each "feature". By default the title field is blank
+// and value contains every detail as markdown. In the compact layout
+// each detail gets its own short field, titled with its label, so
+// Slack can render them side by side.
 type attachmentField struct {
 	Title string `json:"title"`
 	Value string `json:"value"`
+	Short bool   `json:"short"`
 }
 
 // Struct for the message to be received from the GCP Pub/Sub engine.
@@ -73,15 +335,230 @@ type PubSubMessage struct {
 type queueMessage struct {
 	Query       string `json:"query"`
 	ResponseUrl string `json:"response_url"`
+	ChannelID   string `json:"channel_id"`
+	Action      string `json:"action,omitempty"`
+	Value       string `json:"value,omitempty"`
+	RequestID   string `json:"request_id"`
+	Shared      bool   `json:"shared,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+	Page        int    `json:"page,omitempty"`
 }
 
 // init() runs at the beginning of our GCF and sets the variables needed
 // for the response process from the env variables set in the GCF.
 func init() {
-	airtableAPIKey = os.Getenv("AIRTABLE_API_KEY")
+	log.Printf("anerbot-response %s starting", Version)
+
+	if err := loadConfig(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Warm the query cache in the background once everything else has
+	// been configured, so the function can start serving right away.
+	if preload := parseList(os.Getenv("QUERY_CACHE_PRELOAD")); len(preload) > 0 && queryCacheTTL > 0 {
+		go preloadQueries(preload)
+	}
+}
+
+// Function to read the configuration of the response process from the
+// env variables set in the GCF. It runs at startup and again whenever an
+// admin asks for the configuration to be reloaded, so every setting is
+// reset before it is read rather than added to what was read last time.
+func loadConfig() error {
+	var err error
+	if airtableAPIKey, err = getSecret("AIRTABLE_API_KEY"); err != nil {
+		return err
+	}
 	airtableBaseID = os.Getenv("AIRTABLE_BASE_ID")
 	airtableTableID = os.Getenv("AIRTABLE_TABLE_ID")
 	airtableViewID = os.Getenv("AIRTABLE_VIEW_ID")
+	airtableEndpoint = nil
+	if v := os.Getenv("AIRTABLE_API_URL"); v != "" {
+		endpoint, err := url.Parse(v)
+		if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+			return fmt.Errorf("invalid AIRTABLE_API_URL %q: %v", v, err)
+		}
+		airtableEndpoint = endpoint
+	}
+	airtableTimeZone = strings.TrimSpace(os.Getenv("AIRTABLE_TIMEZONE"))
+	if airtableTimeZone == "" {
+		airtableTimeZone = defaultTimeZone
+	}
+	loc, err := time.LoadLocation(airtableTimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid AIRTABLE_TIMEZONE %q, expected an IANA time zone such as %q: %v", airtableTimeZone, defaultTimeZone, err)
+	}
+	airtableLocation = loc
+
+	if slackSigSecret, err = getSecret("SLACK_SIG_SECRET"); err != nil {
+		return err
+	}
+	responseHosts = nil
+	for _, v := range parseList(os.Getenv("SLACK_RESPONSE_HOSTS")) {
+		responseHosts = append(responseHosts, strings.ToLower(v))
+	}
+	if len(responseHosts) == 0 {
+		responseHosts = []string{defaultResponseHosts}
+	}
+	devMode = parseBool(os.Getenv("DEV_MODE"))
+	slackBotToken = os.Getenv("SLACK_BOT_TOKEN")
+
+	userAgent = os.Getenv("HTTP_USER_AGENT")
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	rateLimitRetries = parseInt(os.Getenv("AIRTABLE_RATE_LIMIT_RETRIES"), 3)
+	rateLimitBackoff = parseDuration(os.Getenv("AIRTABLE_RATE_LIMIT_BACKOFF"), 5*time.Second)
+	slackTimeout = parseDuration(os.Getenv("SLACK_REQUEST_TIMEOUT"), 10*time.Second)
+	airtableTimeout = parseDuration(os.Getenv("AIRTABLE_REQUEST_TIMEOUT"), 30*time.Second)
+
+	redactQueries = parseBool(os.Getenv("LOG_REDACT_QUERIES"))
+	showErrorCodes = parseBool(os.Getenv("SLACK_SHOW_ERROR_CODES"))
+
+	caseSensitive = parseBool(os.Getenv("AIRTABLE_CASE_SENSITIVE"))
+	stemming = parseBool(os.Getenv("QUERY_STEMMING"))
+	maxQueryTokens = parseInt(os.Getenv("QUERY_MAX_TOKENS"), 10)
+	minAndTokenLength = parseInt(os.Getenv("QUERY_MIN_AND_TOKEN_LENGTH"), 0)
+	maxDigestQueries = parseInt(os.Getenv("QUERY_MAX_DIGEST"), 5)
+	lastModifiedField = os.Getenv("AIRTABLE_LAST_MODIFIED_FIELD")
+	lastModifiedByField = os.Getenv("AIRTABLE_LAST_MODIFIED_BY_FIELD")
+	lineDelimiter = parseDelimiter(os.Getenv("FIELD_LINE_DELIMITER"), "\n")
+	maxPayloadBytes = parseInt(os.Getenv("SLACK_MAX_PAYLOAD_BYTES"), 30000)
+	maxResponseMessages = parseInt(os.Getenv("SLACK_MAX_RESPONSE_MESSAGES"), 5)
+	nameRefreshInterval = parseDuration(os.Getenv("FEATURE_NAME_REFRESH_INTERVAL"), 10*time.Minute)
+	channelScopes = parseScopes(os.Getenv("CHANNEL_DEFAULT_SCOPES"))
+	userTeams = parseMap(os.Getenv("USER_TEAMS"))
+	clarifyScopes = parseBool(os.Getenv("QUERY_CLARIFY_SCOPES"))
+	searchColumn = strings.TrimSpace(os.Getenv("AIRTABLE_SEARCH_COLUMN"))
+	quarterField = strings.TrimSpace(os.Getenv("ROADMAP_QUARTER_FIELD"))
+	if quarterField == "" {
+		quarterField = "Roadmap"
+	}
+	stripSearchPrefix = true
+	if v, ok := os.LookupEnv("QUERY_STRIP_SEARCH_PREFIX"); ok {
+		stripSearchPrefix = parseBool(v)
+	}
+	trimPunctuation = "?!.,;"
+	if v, ok := os.LookupEnv("QUERY_TRIM_PUNCTUATION"); ok {
+		trimPunctuation = v
+	}
+	minScore = parseInt(os.Getenv("MIN_RESULT_SCORE"), 0)
+	fieldWeights = parseWeights(os.Getenv("FIELD_WEIGHTS"))
+	maxConcurrentQueries = parseInt(os.Getenv("AIRTABLE_MAX_CONCURRENT_QUERIES"), 0)
+	airtableSlots = nil
+	if maxConcurrentQueries > 0 {
+		airtableSlots = make(chan struct{}, maxConcurrentQueries)
+	}
+
+	resultSort = strings.ToLower(os.Getenv("RESULT_SORT"))
+	resultGroup = strings.ToLower(os.Getenv("RESULT_GROUP"))
+	duplicateMode = strings.ToLower(os.Getenv("DUPLICATE_FEATURES"))
+	visibilityRules = parseVisibility(os.Getenv("RESULT_VISIBILITY"))
+	trackingURL = os.Getenv("TRACKING_URL")
+	if trackingSecret, err = getSecret("TRACKING_SECRET"); err != nil {
+		return err
+	}
+	if trackingURL != "" && trackingSecret == "" {
+		log.Printf("warning: TRACKING_URL is set but TRACKING_SECRET isn't, so feature links go straight to Airtable")
+	}
+	trackingRateLimit = parseInt(os.Getenv("TRACKING_RATE_LIMIT"), 30)
+	viewCounts = nil
+	if v := strings.TrimSpace(os.Getenv("VIEW_EVENTS_TABLE")); v != "" {
+		viewCounts = airtableCounterStore{table: v}
+	} else if resultSort == sortPopularity {
+		log.Printf("warning: RESULT_SORT is %s but VIEW_EVENTS_TABLE isn't set, so features are sorted by name", sortPopularity)
+	}
+	compactFields = parseBool(os.Getenv("SLACK_COMPACT_FIELDS"))
+	collapseFields = parseBool(os.Getenv("SLACK_COLLAPSE_FIELDS"))
+	featureSelect = parseBool(os.Getenv("SLACK_FEATURE_SELECT"))
+	if permalinkSecret, err = getSecret("PERMALINK_SECRET"); err != nil {
+		return err
+	}
+	if pollSecret, err = getSecret("POLL_SECRET"); err != nil {
+		return err
+	}
+	shareButton = parseBool(os.Getenv("SLACK_SHARE_BUTTON"))
+	viewAllButton = parseBool(os.Getenv("SLACK_VIEW_ALL_BUTTON"))
+	searchTips = parseBool(os.Getenv("SLACK_SEARCH_TIPS"))
+	callToAction = strings.TrimSpace(os.Getenv("SLACK_CALL_TO_ACTION"))
+	exposedFields = make(map[string]bool)
+	exposeAllFields = false
+	for _, v := range parseList(os.Getenv("SEARCH_EXPOSED_FIELDS")) {
+		if strings.EqualFold(v, exposeAllKeyword) {
+			exposeAllFields = true
+			continue
+		}
+		// Fields other than the known ones, such as the last modified
+		// field, are allowed by their exact name in Airtable.
+		if fields := resolveFields([]string{v}); len(fields) > 0 {
+			v = fields[0]
+		}
+		exposedFields[v] = true
+	}
+	headerEmoji = strings.TrimSpace(os.Getenv("SLACK_HEADER_EMOJI"))
+	boldHeader = parseBool(os.Getenv("SLACK_HEADER_BOLD"))
+	displayLanguage = strings.ToLower(strings.TrimSpace(os.Getenv("DISPLAY_LANGUAGE")))
+	appLinks = parseBool(os.Getenv("AIRTABLE_APP_LINKS"))
+	copyLinkButton = parseBool(os.Getenv("SLACK_COPY_LINK_BUTTON"))
+	reportButton = parseBool(os.Getenv("SLACK_REPORT_BUTTON"))
+	subscribeButton = parseBool(os.Getenv("SLACK_SUBSCRIBE_BUTTON"))
+	subscriptions = nil
+	if v := strings.TrimSpace(os.Getenv("SUBSCRIPTIONS_TABLE")); v != "" {
+		subscriptions = airtableSubscriptionStore{table: v}
+	} else if subscribeButton {
+		log.Printf("warning: SLACK_SUBSCRIBE_BUTTON is set but SUBSCRIPTIONS_TABLE isn't, so the subscribe button is left out")
+		subscribeButton = false
+	}
+	numberResults = parseBool(os.Getenv("SLACK_NUMBER_RESULTS"))
+	maxTitleLength = parseInt(os.Getenv("SLACK_TITLE_MAX_LENGTH"), 0)
+	maxFieldLines = parseInt(os.Getenv("SLACK_MAX_FIELD_LINES"), 0)
+	pageSize = parseInt(os.Getenv("SLACK_PAGE_SIZE"), 0)
+	richTextList = parseBool(os.Getenv("SLACK_RICH_TEXT_LIST"))
+	showMatches = parseBool(os.Getenv("SLACK_MATCHED_FIELDS"))
+	imageFields = parseList(os.Getenv("IMAGE_FIELDS"))
+	bulletFields = make(map[string]bool)
+	for _, v := range resolveFields(parseList(os.Getenv("BULLET_FIELDS"))) {
+		bulletFields[v] = true
+	}
+	shownFields = nil
+	if fields := resolveFields(parseList(os.Getenv("DISPLAY_FIELDS"))); len(fields) > 0 {
+		shownFields = make(map[string]bool)
+		for _, v := range fields {
+			shownFields[v] = true
+		}
+	}
+	channelHiddenFields = parseScopes(os.Getenv("CHANNEL_HIDDEN_FIELDS"))
+	displayFields = orderFields(defaultDisplayFields, resolveFields(parseList(os.Getenv("FIELD_ORDER"))))
+	statusEmoji = make(map[string]string)
+	for k, v := range parseMap(os.Getenv("ROADMAP_STATUS_EMOJI")) {
+		statusEmoji[foldCase(k)] = v
+	}
+	sourceNames = parseMap(os.Getenv("AIRTABLE_SOURCE_NAMES"))
+	teamMentions = make(map[string]string)
+	for k, v := range parseMap(os.Getenv("TEAM_MENTIONS")) {
+		teamMentions[foldCase(k)] = v
+	}
+	entitlementBadges = make(map[string]string)
+	for k, v := range parseMap(os.Getenv("ENTITLEMENT_BADGES")) {
+		entitlementBadges[foldCase(k)] = strings.Trim(v, ":")
+	}
+	planTierRanks = parseRanks(os.Getenv("PLAN_TIER_RANKS"), "Enterprise=1,Team=2,Free=3")
+
+	unavailableEmoji = make(map[string]bool)
+	for _, v := range parseList(os.Getenv("SLACK_UNAVAILABLE_EMOJI")) {
+		unavailableEmoji[strings.Trim(v, ":")] = true
+	}
+	emojiFallbacks = make(map[string]string)
+	for k, v := range defaultEmojiFallbacks {
+		emojiFallbacks[k] = v
+	}
+	for k, v := range parseMap(os.Getenv("SLACK_EMOJI_FALLBACKS")) {
+		emojiFallbacks[strings.Trim(k, ":")] = v
+	}
+
+	queryCacheTTL = parseDuration(os.Getenv("QUERY_CACHE_TTL"), 0)
+
+	return nil
 }
 
 // main() does not run in GCF. It is left here strictly for testing
@@ -89,6 +566,10 @@ func init() {
 // to "main" and run `go build`.
 func main() {
 	http.HandleFunc("/response", LocalResponse)
+	http.HandleFunc("/track", Track)
+	http.HandleFunc("/options", Options)
+	http.HandleFunc("/search", Search)
+	http.HandleFunc("/poll", Poll)
 
 	err := http.ListenAndServe(":1234", nil)
 	if err != nil {
@@ -106,30 +587,108 @@ func Response(ctx context.Context, m PubSubMessage) error {
 	var message queueMessage
 	err := json.Unmarshal(m.Data, &message)
 	if err != nil {
-		return fmt.Errorf("could not unmarshal message: %v", err)
+		return codeErrorf(errInvalidMessage, "could not unmarshal message: %v", err)
+	}
+	if !responseURLAllowed(message.ResponseUrl) {
+		return codeErrorf(errInvalidMessage, "request %s: response URL %q is not an allowed Slack URL", message.RequestID, message.ResponseUrl)
+	}
+	switch message.Action {
+	case reloadAction:
+		return handleReload(message)
+	case subscribeAction:
+		return handleSubscribe(message)
+	case lookupAction:
+		return handleLookup(message)
+	case fieldDetailsAction:
+		return handleFieldDetails(message)
+	case needsDocsAction:
+		return handleNeedsDocs(message)
+	}
+
+	// Run each query of a digest, such as "sso; billing", on its own and
+	// combine their results into a single response, unless digests
+	// have been turned off.
+	if queries := splitDigest(message.Query); maxDigestQueries > 0 && len(queries) > 1 {
+		log.Printf("request %s: searching for a digest of %d queries", message.RequestID, len(queries))
+		return respondWithDigest(message, queries)
 	}
 
 	// Perform the search in Airtable, passing in the original query term.
 	// Respond with a failure message if Airtable is unreachable for any reason.
-	atr, err := queryAirtable(message.Query)
+	log.Printf("request %s: searching for %s", message.RequestID, logQuery(message.Query))
+	search := parseQuery(message.Query)
+	search.ChannelID = message.ChannelID
+	search.Shared = message.Shared
+	search.Page = message.Page
+	search.scopeToUser(message.UserID)
+
+	// Ask the user which field they meant rather than searching for a
+	// scope that isn't known as if it were part of the query.
+	if text := scopeClarification(search); text != "" && clarifyScopes {
+		res := &slackResponse{
+			ReplaceOriginal: strconv.FormatBool(true),
+			ResponseType:    responseEphemeral,
+			Text:            text,
+		}
+		if err := postToSlack(message.ResponseUrl, res); err != nil {
+			return codeErrorf(errSlack, "request %s: %v", message.RequestID, err)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	atr, err := queryAirtable(search)
 	if err != nil {
-		sendFailureMessage(message.ResponseUrl)
-		return fmt.Errorf("error querying Airtable: %v", err)
+		ce := airtableError(message.RequestID, err)
+		sendFailureMessage(message.ResponseUrl, ce.Code)
+		return ce
 	}
+	search.Elapsed = time.Since(start)
 
 	// Build the full response object to be sent back to Slack.
-	res, err := buildSlackResponse(atr)
+	res, err := buildSlackResponse(atr, search)
 	if err != nil {
-		return fmt.Errorf("unable to build slack response: %v", err)
+		sendFailureMessage(message.ResponseUrl, errRender)
+		return codeErrorf(errRender, "request %s: unable to build slack response: %v", message.RequestID, err)
 	}
 
+	return postResults(message, res, len(atr))
+}
+
+// Function to split the response to a search into as many messages as
+// needed to stay under Slack's size limits and post each of them, in
+// order, to the ResponseUrl that was in the original message. The count
+// is the number of features found.
+func postResults(message queueMessage, res *slackResponse, count int) error {
+	chunks, err := chunkResponse(res)
+	if err != nil {
+		sendFailureMessage(message.ResponseUrl, errRender)
+		return codeErrorf(errRender, "request %s: unable to split slack message: %v", message.RequestID, err)
+	}
+
+	// Fall back to a single message linking to the view in Airtable when
+	// the results need more messages than are allowed.
+	if maxResponseMessages > 0 && len(chunks) > maxResponseMessages {
+		log.Printf("request %s: results need %d messages, sending a link to Airtable instead", message.RequestID, len(chunks))
+		chunks = []*slackResponse{oversizedResponse(res, count)}
+	}
+	for _, c := range chunks {
+		if err := postToSlack(message.ResponseUrl, c); err != nil {
+			return codeErrorf(errSlack, "request %s: %v", message.RequestID, err)
+		}
+	}
+	return nil
+}
+
+// Function to post a response object to Slack at a response URL.
+func postToSlack(url string, res *slackResponse) error {
 	// Marshal the response object into JSON and prepare the request to be
-	// sent to the ResponseUrl that was in the original message.
+	// sent to the URL passed into this function.
 	body, err := json.Marshal(res)
 	if err != nil {
 		return fmt.Errorf("unable to convert slack message to JSON: %v", err)
 	}
-	req, err := http.NewRequest("POST", message.ResponseUrl, bytes.NewBuffer(body))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("unable to build new HTTP request: %v", err)
 	}
@@ -137,7 +696,7 @@ func Response(ctx context.Context, m PubSubMessage) error {
 
 	// Perform the request (posting our message to Slack,) and
 	// close out the response body sent back.
-	client := &http.Client{}
+	client := newHTTPClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("unable to send message to Slack: %v", err)
@@ -146,36 +705,39 @@ func Response(ctx context.Context, m PubSubMessage) error {
 	return nil
 }
 
+// Function to render a query for logging. When redaction is enabled only
+// a short hash of the query is logged, matching the hash logged by the
+// anerbot-queue function, so identical queries can still be grouped
+// without revealing what was searched.
+func logQuery(query string) string {
+	if !redactQueries {
+		return fmt.Sprintf("%q", query)
+	}
+	sum := sha256.Sum256([]byte(query))
+	return fmt.Sprintf("[redacted %s]", hex.EncodeToString(sum[:])[:12])
+}
+
 // Function to send a message to Slack informing the user that the program
-// was unable to communicate with Slack.
-func sendFailureMessage(url string) {
+// was unable to gather their results. The code of the failure is included
+// in the message when error codes are shown to users.
+func sendFailureMessage(url string, code errorCode) {
 	// Prepare message to be sent to Slack.
+	text := failureMessages[code]
+	if showErrorCodes {
+		text += fmt.Sprintf(" (error: %s)", code)
+	}
 	message := slackResponse{
 		ResponseType: "ephemeral",
-		Text:         "Failed to fetch records from Airtable :sob:",
-	}
-
-	// Marshal the message into JSON and prepare the request to be sent
-	// to the URL passed into this function. This should always be the
-	// ResponseUrl field from the original message.
-	body, err := json.Marshal(message)
-	if err != nil {
-		log.Fatalf("unable to convert slack message to JSON: %v", err)
-	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		log.Fatalf("unable to build new HTTP request: %v", err)
+		Text:         text,
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	// Perform the request (posting our message to Slack,) and
-	// close out the response body sent back.
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("unable to send message to Slack: %v", err)
+	// Post the message to the URL passed into this function, which should
+	// always be the ResponseUrl field from the original message. The
+	// failure being reported has already been logged, so a message that
+	// can't be sent is only logged too.
+	if err := postToSlack(url, &message); err != nil {
+		log.Printf("unable to send failure message to Slack: %v", err)
 	}
-	defer resp.Body.Close()
 }
 
 // Function utilized strictly for local testing of the response object
@@ -186,7 +748,9 @@ func LocalResponse(w http.ResponseWriter, r *http.Request) {
 	// create a readable buffer for other functions to use.
 	bodyBytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Fatalf("Couldn't read request body: %v", err)
+		log.Printf("Couldn't read request body: %v", err)
+		http.Error(w, "Couldn't read request body", 400)
+		return
 	}
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 
@@ -194,14 +758,16 @@ func LocalResponse(w http.ResponseWriter, r *http.Request) {
 	// from Slack should not come in any other method.
 	if r.Method != "POST" {
 		http.Error(w, "Only POST requests are accepted", 405)
+		return
 	}
 
 	// Parse the body of the POST request and gather the data
 	// into a new field on the request called Form (accessed
 	// via r.Form)
 	if err := r.ParseForm(); err != nil {
+		log.Printf("ParseForm: %v", err)
 		http.Error(w, "Couldn't parse form", 400)
-		log.Fatalf("ParseForm: %v", err)
+		return
 	}
 
 	// Reset r.Body field as ParseForm depletes it by reading
@@ -212,21 +778,28 @@ func LocalResponse(w http.ResponseWriter, r *http.Request) {
 	// an empty query and omit the word "search" if present
 	// to maintain backwards compatibility with Anerbot 1.0.
 	queryText := r.Form["text"][0]
-	if strings.HasPrefix(queryText, "search") {
-		queryText = strings.TrimPrefix(queryText, "search ")
-	}
+	queryText = trimSearchPrefix(queryText)
 
 	// Perform the search in Airtable, passing in the original query term.
 	// Respond with a failure message if Airtable is unreachable for any reason.
-	atr, err := queryAirtable(queryText)
+	search := parseQuery(queryText)
+	search.ChannelID = r.FormValue("channel_id")
+	search.scopeToUser(r.FormValue("user_id"))
+	start := time.Now()
+	atr, err := queryAirtable(search)
 	if err != nil {
-		log.Fatalf("error querying Airtable: %v", err)
+		log.Printf("error querying Airtable: %v", err)
+		http.Error(w, "Failed to search Airtable", 502)
+		return
 	}
+	search.Elapsed = time.Since(start)
 
 	// Build the full response object to be sent back to Slack.
-	res, err := buildSlackResponse(atr)
+	res, err := buildSlackResponse(atr, search)
 	if err != nil {
-		log.Fatalf("unable to build slack response: %v", err)
+		log.Printf("unable to build slack response: %v", err)
+		http.Error(w, "Failed to build response", 500)
+		return
 	}
 
 	// Marshal our response struct into JSON and respond to the request.
@@ -234,58 +807,312 @@ func LocalResponse(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(res)
 	if err != nil {
-		log.Fatalf("json.Marshal: %v", err)
+		log.Printf("json.Marshal: %v", err)
+	}
+}
+
+// Word removed from the start of queries for backwards compatibility with
+// Anerbot 1.0.
+const searchPrefix = "search"
+
+// Function to remove the word "search" from the start of a query, in any
+// casing, to maintain backwards compatibility with Anerbot 1.0. The word
+// is only removed when it stands on its own and something follows it, so
+// "searchable" and a query of just "search" are searched as they are.
+// Nothing is removed when stripping the prefix is turned off.
+func trimSearchPrefix(query string) string {
+	if !stripSearchPrefix {
+		return query
 	}
+	trimmed := strings.TrimLeftFunc(query, unicode.IsSpace)
+	words := strings.Fields(trimmed)
+	if len(words) < 2 || !strings.EqualFold(words[0], searchPrefix) {
+		return query
+	}
+	return strings.TrimLeftFunc(trimmed[len(words[0]):], unicode.IsSpace)
+}
+
+// Entry point for GCF anerbot-search function. Permalinks to a search
+// generated by the "link" keyword point here with the query encoded in
+// the "q" query string. The search is run again and the results are
+// returned as the same JSON object that would be sent to Slack. Only
+// permalinks signed with the permalink secret, or requests bearing the
+// secret itself, are answered.
+func Search(w http.ResponseWriter, r *http.Request) {
+	queryText := r.URL.Query().Get("q")
+	if !verifySearch(r, queryText, permalinkSecret) {
+		http.Error(w, "Unable to validate request", 401)
+		return
+	}
+	if strings.TrimSpace(queryText) == "" {
+		http.Error(w, "Missing query", 400)
+		return
+	}
+
+	// Perform the search in Airtable, passing in the query from the link.
+	search := parseQuery(queryText)
+	atr, err := queryAirtable(search)
+	if err != nil {
+		log.Printf("error querying Airtable: %v", err)
+		http.Error(w, "Failed to fetch records from Airtable", 502)
+		return
+	}
+
+	// Build the full response object as it would be sent to Slack, with
+	// only the fields allowed to be exposed.
+	if !exposeAllFields {
+		atr = restrictFields(atr, exposedFields)
+	}
+	res, err := buildSlackResponse(atr, search)
+	if err != nil {
+		log.Printf("unable to build slack response: %v", err)
+		http.Error(w, "Unable to build response", 500)
+		return
+	}
+
+	// Marshal our response struct into JSON and respond to the request.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("json.Marshal: %v", err)
+	}
+}
+
+// Function to check that a request to the anerbot-search function came
+// from a permalink signed with the secret passed in, or bears the secret
+// itself as a bearer token. Every request is rejected when no secret has
+// been configured so the base is never searchable by anyone.
+func verifySearch(r *http.Request, query, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" {
+		return verifyBearer(r, secret)
+	}
+	return verifyLinkSignature(query, secret, r.URL.Query().Get("sig"))
+}
+
+// Entry point for GCF anerbot-track function. When click tracking is
+// enabled, feature links in Slack point here instead of directly to
+// Airtable. The click is recorded against the feature and the user is
+// redirected on to the feature in Airtable.
+func Track(w http.ResponseWriter, r *http.Request) {
+	// Only accept well-formed record IDs. The redirect target is always
+	// built from the ID so this can't be used as an open redirect.
+	id := r.URL.Query().Get("id")
+	if !recordIDPattern.MatchString(id) {
+		http.Error(w, "Invalid feature ID", 400)
+		return
+	}
+
+	// Only count clicks on links Anerbot signed, and only so many from
+	// each source a minute, so the counts can't be inflated by calling
+	// the tracker directly. A failure to record shouldn't stop the user
+	// from reaching the feature, so the user is redirected either way.
+	query := r.URL.Query().Get("q")
+	source := clickSource(r)
+	switch {
+	case !verifyLinkSignature(trackedValue(id, query), trackingSecret, r.URL.Query().Get("sig")):
+		log.Printf("not recording view for %s: invalid signature", id)
+	case !clickLimiter.allow(source, trackingRateLimit, time.Now()):
+		log.Printf("not recording view for %s: too many clicks from %s", id, source)
+	case viewCounts != nil:
+		if err := viewCounts.Increment(id); err != nil {
+			log.Printf("unable to record view for %s: %v", id, err)
+		}
+	}
+
+	http.Redirect(w, r, featureLink(id), http.StatusFound)
+}
+
+// Function to generate a link to a specific feature in Airtable.
+func featureLink(id string) string {
+	return fmt.Sprintf("https://airtable.com/%s/%s/%s", airtableTableID, airtableViewID, id)
+}
+
+// Function to generate a deep link opening a specific feature in the
+// Airtable desktop app.
+func appLink(id string) string {
+	return fmt.Sprintf("airtable://airtable.com/%s/%s/%s", airtableTableID, airtableViewID, id)
+}
+
+// Function to generate the link used for a feature's title in Slack,
+// found by searching for the query passed in. This points at the click
+// tracker, signed with the tracking secret, when one is configured,
+// otherwise directly at the feature in Airtable.
+func titleLink(id, query string) string {
+	if trackingURL == "" || trackingSecret == "" {
+		return featureLink(id)
+	}
+	values := url.Values{
+		"id":  {id},
+		"q":   {query},
+		"sig": {linkSignature(trackedValue(id, query), trackingSecret)},
+	}
+	return fmt.Sprintf("%s?%s", trackingURL, values.Encode())
+}
+
+// Function to join the feature ID and query of a link to the click
+// tracker into the value its signature covers.
+func trackedValue(id, query string) string {
+	return id + "\n" + query
+}
+
+// Function to sign a value with a secret, such as the feature ID and
+// query of a link to the click tracker.
+func linkSignature(value, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Function to check that a signature was made by signing the value with
+// the secret passed in. Nothing is valid when no secret is configured.
+func verifyLinkSignature(value, secret, signature string) bool {
+	if secret == "" {
+		return false
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return hmac.Equal(sig, mac.Sum(nil))
 }
 
 // Function to build the response to be sent to Slack. The slackResponse
 // object will contain all the data needed for Slack to display the message.
-func buildSlackResponse(f []feature) (*slackResponse, error) {
+func buildSlackResponse(f []feature, search searchRequest) (*slackResponse, error) {
+	// A breakdown only reports how many features each team has.
+	if search.Keyword == breakdownKeyword {
+		res := buildBreakdownResponse(f, search)
+		if !search.Section {
+			appendCallToAction(res)
+			appendDebug(res, search)
+		}
+		return res, nil
+	}
+
+	// Handle features sharing the same name before anything is counted.
+	f = handleDuplicates(f)
+
 	// Prepare the top level statement of our results which reports
 	// whether there were any results from Airtable or not by counting
 	// the slice of features (f) passed into the function.
 	var text string
-	if len(f) == 0 {
+	var showTips bool
+	switch {
+	case len(f) == 0 && viewEmpty():
+		// Nothing can match when the view has no features at all, which
+		// usually means Anerbot is pointed at the wrong view or table.
+		log.Printf("warning: Airtable view %s in table %s has no features, check the Airtable configuration", airtableViewID, airtableTableID)
+		text = "Anerbot couldn't find any features in Airtable at all, so it may be misconfigured. The maintainers have been warned! :warning:"
+	case len(f) == 0:
 		text = "No items found, try another search term"
-	} else {
+
+		// Suggest the closest feature names in case of a typo.
+		if s := suggestFeatures(search.text()); len(s) > 0 {
+			text += fmt.Sprintf(`. Did you mean "%s"?`, strings.Join(s, `" or "`))
+		}
+		showTips = searchTips
+	default:
 		text = fmt.Sprintf("Found %d items! Click on any result to learn more.", len(f))
 	}
 
+	// Let the user know which page of the results they're looking at
+	// when there are too many to show at once. Digest sections aren't
+	// paged since their pages couldn't be moved between on their own.
+	pages := pageCount(len(f))
+	if search.Section {
+		pages = 1
+	}
+	page := clampPage(search.Page, pages)
+	if pages > 1 {
+		text += fmt.Sprintf(" Showing page %d of %d.", page, pages)
+	}
+
+	// Let the user know whose features were searched when they asked for
+	// their own team's features.
+	if search.Keyword == mineKeyword {
+		if search.Team != "" {
+			text += fmt.Sprintf(" Only features owned by %s were searched.", search.Team)
+		} else {
+			text += " Anerbot doesn't know which team you're on, so features from every team were searched."
+		}
+	}
+
+	// Let the user know when only part of their query was searched.
+	if search.Truncated {
+		text += fmt.Sprintf(" Your query was too long, so only the first %d words were searched.", maxQueryTokens)
+	}
+
 	// Initialize the response object with some default values.
 	res := &slackResponse{
 		ReplaceOriginal: strconv.FormatBool(true),
 		ResponseType:    "ephemeral",
-		Text:            text,
+		Text:            styleHeader(text),
 		Attachments:     nil,
 	}
+	applyVisibility(res, len(f), search)
 
-	// Prepare an attachment object for each feature in the feature slice.
-	for _, v := range f {
-		// Generate a link to this specific feature in Airtable.
-		link := fmt.Sprintf("https://airtable.com/%s/%s/%s", airtableTableID, airtableViewID, v.AirtableID)
-
-		// Create a single string that represents each possible field from
-		// Airtable. Each part is concatenated to the previous part. Fields
-		// are visually separated in Slack via the inclusion of `\r\n` which
-		// represents a return and new line.
-		var value string
-		if v.Fields.Roadmap != "" {
-			value += fmt.Sprintf(":sparkles: *Roadmap:* %s\r\n", v.Fields.Roadmap)
-		}
-		if v.Fields.TeamResponsible != "" {
-			value += fmt.Sprintf(":one-team: *Team(s):* %s\r\n", v.Fields.TeamResponsible)
-		}
-		if v.Fields.Plan != "" {
-			value += fmt.Sprintf(":moneybag: *Plan:* %s\r\n", v.Fields.Plan)
-		}
-		if v.Fields.FeatureFlag != "" {
-			value += fmt.Sprintf(":triangular_flag_on_post: *Feature Flag:* %s\r\n", v.Fields.FeatureFlag)
-		}
-		if v.Fields.Entitlements != "" {
-			value += fmt.Sprintf(":crown: *Entitlements:* %s\r\n", v.Fields.Entitlements)
+	// Changing page replaces the results being paged through rather than
+	// posting them again.
+	if search.Page > 0 {
+		res.ReplaceOriginal = strconv.FormatBool(true)
+	}
+
+	// Prepare an attachment object for each feature in the feature slice,
+	// ordered by the configured sort mode. When results are grouped by
+	// plan tier, a header is added above the first feature of each group.
+	sorted := sortFeatures(f, search)
+	grouped := resultGroup == groupPlanTier
+	if grouped {
+		sorted = groupFeatures(sorted)
+	}
+
+	// Only the features on the page being shown are rendered, numbered
+	// by their position in every result rather than on the page.
+	var offset int
+	if pages > 1 {
+		sorted, offset = pageFeatures(sorted, page)
+	}
+
+	// Results shown as a Slack-native list are a single list attachment
+	// rather than an attachment per feature.
+	if richTextList && len(sorted) > 0 {
+		res.Attachments = append(res.Attachments, listAttachment(sorted, search, offset))
+		sorted = nil
+	}
+
+	var group string
+	for i, v := range sorted {
+		if grouped {
+			if name, _ := planGroup(v.Fields.Plan); name != group {
+				group = name
+				res.Attachments = append(res.Attachments, groupHeader(group))
+			}
 		}
-		if v.Fields.ExternalDocumentation != "" {
-			value += fmt.Sprintf(":books: *External Documentation:* %s\r\n", v.Fields.ExternalDocumentation)
+
+		// Show the feature in the requested language where translations
+		// are available.
+		v = localizeFeature(v, search.language())
+
+		// Generate a link to this specific feature in Airtable.
+		link := featureLink(v.AirtableID)
+
+		// Render the details of the feature as the fields of the
+		// attachment, in either the compact or full layout. Brief
+		// results are only the linked name of the feature.
+		brief := search.Keyword == briefKeyword
+		// Collapsed fields are rendered as a block instead.
+		var fields []attachmentField
+		var collapsed *block
+		if !brief && collapseFields {
+			collapsed = collapsedFieldsBlock(v, search.ChannelID)
+		} else if !brief {
+			fields = renderFields(v, search)
 		}
 
 		// Create a fallback title to be used in the case that rich markdown
@@ -293,73 +1120,170 @@ func buildSlackResponse(f []feature) (*slackResponse, error) {
 		// following format: "Name of Feature: https://url.to/feature/in/airtable"
 		fallback := fmt.Sprintf("%s: %s", v.Fields.Feature, link)
 
-		// Add all of our crafted items to fields of an attachment object.
-		// Add the attachment object to the attachments field of the response.
-		res.Attachments = append(res.Attachments, attachment{
-			Title:     v.Fields.Feature,
+		// Number the title and fallback when results are numbered, so
+		// features can be referred to by their position in the list.
+		title := featureTitle(v)
+		if numberResults {
+			title = fmt.Sprintf("%d. %s", offset+i+1, title)
+			fallback = fmt.Sprintf("%d. %s", offset+i+1, fallback)
+		}
+
+		// Add all of our crafted items to fields of an attachment object,
+		// along with any buttons enabled for the feature. Add the
+		// attachment object to the attachments field of the response.
+		a := attachment{
+			Title:     title,
 			Fallback:  fallback,
-			TitleLink: link,
-			Fields: []attachmentField{
-				{
-					Title: "",
-					Value: value,
-				},
-			},
-		})
+			TitleLink: titleLink(v.AirtableID, search.Query),
+			Fields:    fields,
+		}
+		if collapsed != nil {
+			a.Blocks = append(a.Blocks, *collapsed)
+		}
+		if !brief {
+			a.Footer = sourceFooter()
+			a.Blocks = append(a.Blocks, imageBlocks(v, search.ChannelID)...)
+			if showMatches {
+				if b := matchesBlock(v, search); b != nil {
+					a.Blocks = append(a.Blocks, *b)
+				}
+			}
+			if b := actionsBlock(v); b != nil {
+				a.Blocks = append(a.Blocks, *b)
+			}
+		}
+		res.Attachments = append(res.Attachments, a)
+	}
+
+	// Everything around the results is added once for a whole digest
+	// rather than to each of its sections.
+	if search.Section {
+		return res, nil
 	}
 
+	// Offer a way to move between the pages of results.
+	if pages > 1 {
+		res.Attachments = append(res.Attachments, paginationAttachment(search, page, pages))
+	}
+
+	appendChrome(res, search, len(f), showTips)
+
+	// Explain how the query was parsed when debugging.
+	appendDebug(res, search)
+
 	// Return the Slack response object.
 	return res, nil
 }
 
-// Function to query Airtable for a search term.
-func queryAirtable(query string) ([]feature, error) {
-	// Initiate an Airtable client that will allow further operations.
-	client, err := airtable.New(airtableAPIKey, airtableBaseID)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create new airtable client: %v", err)
+// Function to add everything around the results of a search rather than
+// belonging to any one result: the feature select menu, search tips when
+// they should be shown, the share and view buttons and the call to
+// action. The count is the number of features found.
+func appendChrome(res *slackResponse, search searchRequest, count int, showTips bool) {
+	// Offer a menu to jump straight to a feature by name, with options
+	// loaded from the anerbot-options function as the user types.
+	if featureSelect {
+		res.Attachments = append(res.Attachments, attachment{
+			Fallback: "Jump to a feature",
+			Blocks:   []block{featureSelectBlock()},
+		})
 	}
 
-	// Convert our query to lowercase to gather the most results.
-	query = strings.ToLower(query)
+	// Explain how to refine a query when nothing was found.
+	if showTips {
+		res.Attachments = append(res.Attachments, tipsAttachment())
+	}
+
+	// Offer to share results only the user can see with the rest of the
+	// channel, and to open the view they came from in Airtable.
+	var actions []interface{}
+	if shareButton && count > 0 && res.ResponseType == responseEphemeral {
+		actions = append(actions, shareElement(search))
+	}
+	if viewAllButton && count > 0 {
+		actions = append(actions, viewAllElement())
+	}
+	if len(actions) > 0 {
+		res.Attachments = append(res.Attachments, attachment{
+			Fallback: "Share these results or open the Airtable view",
+			Blocks:   []block{{Type: "actions", Elements: actions}},
+		})
+	}
+
+	// Point users somewhere to follow up on the results.
+	appendCallToAction(res)
+}
+
+// Interface for anything able to list records from an Airtable table,
+// satisfied by *airtable.Client.
+type recordLister interface {
+	ListRecords(tableName string, recordsHolder interface{}, listParams ...airtable.ListParameters) error
+}
+
+// Interface for anything able to add records to an Airtable table as well
+// as list them, satisfied by *airtable.Client.
+type recordEditor interface {
+	recordLister
+	RetrieveRecord(tableName string, recordID string, recordHolder interface{}) error
+	CreateRecord(tableName string, record interface{}) error
+	UpdateRecord(tableName, recordID string, updatedFields map[string]interface{}, record interface{}) error
+}
 
-	// Create a slice of strings containing each of the fields
-	// that should be queried in Airtable.
-	var fields = []string{
-		"Feature",
-		"Roadmap",
-		"Team responsible",
-		"Plan",
-		"Feature flag",
-		"Entitlements",
-		"External documentation",
+// Function used to create the recordEditor for each change made to
+// Airtable. Changes aren't limited by the concurrent query limit.
+var newEditor = func() (recordEditor, error) {
+	client, err := airtable.New(airtableAPIKey, airtableBaseID)
+	if err != nil {
+		return nil, err
 	}
+	client.HTTPClient = newAirtableHTTPClient()
+	client.ShouldRetryIfRateLimited = false
+	return client, nil
+}
 
-	// Create an empty slice of strings that will be filled with
-	// strings representing an Airtable-compatible query-statement.
-	// There will be one statement created for each of the fields
-	// in the fields slice.
-	var searchStatements []string
-	for _, v := range fields {
-		statement := fmt.Sprintf("SEARCH('%s', LOWER({%s})) > 0", query, v)
-		searchStatements = append(searchStatements, statement)
+// Function used to create the recordLister for each Airtable query.
+var newLister = func() (recordLister, error) {
+	client, err := airtable.New(airtableAPIKey, airtableBaseID)
+	if err != nil {
+		return nil, err
 	}
+	// Rate limited requests are retried by the HTTP client, which waits
+	// as long as Airtable asks rather than a fixed delay.
+	client.HTTPClient = newAirtableHTTPClient()
+	client.ShouldRetryIfRateLimited = false
+	return limitLister(client), nil
+}
 
-	// Create a single string, formula, by combining each of the elements
-	// in the searchStatements slice, separated by a comma.
-	var formula = fmt.Sprintf("OR(%s)", strings.Join(searchStatements, ", "))
+// Function to query Airtable for a search request.
+func queryAirtable(search searchRequest) ([]feature, error) {
+	// Create a single string, formula, representing an Airtable-compatible
+	// query-statement that searches each of the fields in scope.
+	var formula = buildFormula(search, defaultScope(search.ChannelID))
 
 	// Initialize and populate the listParams object that will be
 	// used by the Airtable client to create a result set.
 	listParams := airtable.ListParameters{
 		CellFormat:      "string",
-		Fields:          fields,
+		Fields:          requestFields(search),
 		FilterByFormula: formula,
-		TimeZone:        "American/Boston",
+		TimeZone:        airtableTimeZone,
 		UserLocale:      "en-US",
 		View:            airtableViewID,
 	}
 
+	// Serve the results from the query cache when the same query was
+	// sent to Airtable recently.
+	key := cacheKey(search, listParams)
+	if features, ok := cachedFeatures(key); ok {
+		return filterByScore(features, search), nil
+	}
+
+	// Initiate an Airtable client that will allow further operations.
+	client, err := newLister()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new airtable client: %v", err)
+	}
+
 	// Initialize an empty slice of features to contain our results.
 	var features []feature
 
@@ -368,7 +1292,176 @@ func queryAirtable(query string) ([]feature, error) {
 	if err != nil {
 		return nil, err
 	}
+	cacheFeatures(key, features)
+
+	// Return the slice of features for further processing, leaving out
+	// any matches too weak to be worth showing.
+	return filterByScore(features, search), nil
+}
+
+// Function to read a secret, such as a signing secret or API key. When
+// the NAME_FILE env variable names a file, such as a secret from Secret
+// Manager mounted as a volume, the secret is read from it rather than
+// from the env variable itself. The file is read every time the
+// configuration is loaded, so a secret rotated in the file is picked up
+// on reload, which the env variables of a running function never are.
+func getSecret(name string) (string, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name), nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s_FILE: %v", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Function to split a comma-separated env variable into a slice of
+// trimmed values. Empty values are dropped.
+func parseList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
 
-	// Return the slice of features for further processing.
-	return features, nil
+// Function to split a comma-separated env variable of "key=value" pairs
+// into a map. Pairs without an equals sign are dropped.
+func parseMap(s string) map[string]string {
+	m := make(map[string]string)
+	for _, v := range parseList(s) {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m
+}
+
+// Function to parse a boolean env variable. Unset or unparsable values
+// are treated as false.
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// Function to parse an integer env variable, returning the default value
+// passed in when the variable is unset or unparsable.
+func parseInt(s string, def int) int {
+	i, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return def
+	}
+	return i
+}
+
+// Function to parse a duration env variable such as "10m", returning the
+// default value passed in when the variable is unset or unparsable.
+func parseDuration(s string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// Function to parse a comma-separated env variable of "name=rank" pairs
+// into a map keyed by the lowercase name. The default value passed in is
+// parsed instead when the variable is unset. Unparsable ranks are dropped.
+func parseRanks(s string, def string) map[string]int {
+	if strings.TrimSpace(s) == "" {
+		s = def
+	}
+	ranks := make(map[string]int)
+	for k, v := range parseMap(s) {
+		if r, err := strconv.Atoi(v); err == nil {
+			ranks[strings.ToLower(k)] = r
+		}
+	}
+	return ranks
+}
+
+// Function to parse the search weights of fields from a comma-separated
+// env variable of "field=weight" pairs, where field is a field alias or
+// field name, such as "feature=3,docs=2". Fields that aren't known are
+// kept by name so that other searched fields, such as the search column,
+// can be weighted too. Weights that aren't whole numbers are dropped.
+func parseWeights(s string) map[string]int {
+	weights := make(map[string]int)
+	for k, v := range parseMap(s) {
+		w, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		fields := resolveFields([]string{k})
+		if len(fields) == 0 {
+			fields = []string{k}
+		}
+		for _, field := range fields {
+			weights[field] = w
+		}
+	}
+	return weights
+}
+
+// Function to parse the per-channel default search scopes from a comma-
+// separated env variable of "channel=fields" pairs, where fields is a
+// "|" separated list of field aliases or field names, such as
+// "C2147483705=plan|entitlements". Unknown fields are dropped.
+func parseScopes(s string) map[string][]string {
+	scopes := make(map[string][]string)
+	for channel, v := range parseMap(s) {
+		fields := resolveFields(strings.Split(v, "|"))
+		scopes[normalizeID(channel)] = fields
+	}
+	return scopes
+}
+
+// Function to normalize a Slack ID for comparison, in the same way as
+// the anerbot-queue function. Slack IDs are always uppercase, but
+// configuration may not be.
+func normalizeID(id string) string {
+	return strings.ToUpper(strings.TrimSpace(id))
+}
+
+// Function to resolve a list of field aliases or field names, in any
+// case, into their field names in Airtable. Unknown fields are dropped.
+func resolveFields(names []string) []string {
+	var fields []string
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if field, ok := fieldAliases[strings.ToLower(n)]; ok {
+			fields = append(fields, field)
+			continue
+		}
+		for _, field := range searchFields {
+			if strings.EqualFold(field, n) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// Function to parse a delimiter env variable. Escape sequences such as
+// "\r\n" are interpreted since env variables can't easily contain new
+// lines. The default value passed in is returned when the variable is
+// unset or can't be interpreted.
+func parseDelimiter(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	d, err := strconv.Unquote(fmt.Sprintf(`"%s"`, s))
+	if err != nil {
+		return def
+	}
+	return d
 }
@@ -0,0 +1,251 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Function to sign a query as anerbot-queue does for its permalinks.
+func testSignature(query, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(query))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		query  string
+		sig    string
+		auth   string
+		want   bool
+	}{
+		{"no secret rejects everything", "", "sso", testSignature("sso", ""), "", false},
+		{"signed permalink", "s3cret", "sso", testSignature("sso", "s3cret"), "", true},
+		{"missing signature", "s3cret", "sso", "", "", false},
+		{"signature for another query", "s3cret", "billing", testSignature("sso", "s3cret"), "", false},
+		{"signature with another secret", "s3cret", "sso", testSignature("sso", "other"), "", false},
+		{"malformed signature", "s3cret", "sso", "not-hex", "", false},
+		{"bearer secret", "s3cret", "sso", "", "Bearer s3cret", true},
+		{"wrong bearer secret", "s3cret", "sso", testSignature("sso", "s3cret"), "Bearer wrong", false},
+		{"other authorization scheme", "s3cret", "sso", "", "Basic s3cret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?"+url.Values{"q": {tt.query}, "sig": {tt.sig}}.Encode(), nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			if got := verifySearch(r, tt.query, tt.secret); got != tt.want {
+				t.Errorf("verifySearch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchRequiresSignature(t *testing.T) {
+	defer func(s string) { permalinkSecret = s }(permalinkSecret)
+	permalinkSecret = "s3cret"
+	defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{
+		"recSso00000000001": {"Feature": "Single sign-on"},
+	}))()
+
+	tests := []struct {
+		name string
+		sig  string
+		want int
+	}{
+		{"unsigned", "", http.StatusUnauthorized},
+		{"signed", testSignature("sso", "s3cret"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Search(w, httptest.NewRequest("GET", "/?"+url.Values{"q": {"sso"}, "sig": {tt.sig}}.Encode(), nil))
+			if w.Code != tt.want {
+				t.Errorf("Search() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+// Function to deliver a queued message to the function as Pub/Sub would.
+// Dev mode is turned on so the message can be answered on a fake Slack.
+func respond(t *testing.T, message queueMessage) error {
+	t.Helper()
+	defer func(d bool) { devMode = d }(devMode)
+	devMode = true
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Response(context.Background(), PubSubMessage{Data: data})
+}
+
+// Function to capture everything logged while running a function.
+func captureLogs(f func()) string {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	f()
+	return buf.String()
+}
+
+func TestQueryLogRedaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		redact   string
+		wantRaw  bool
+		wantHash bool
+	}{
+		{"queries logged by default", "", true, false},
+		{"queries hashed when redacted", "true", false, true},
+	}
+	const query = "secret project codename"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"LOG_REDACT_QUERIES": tt.redact, "QUERY_CACHE_TTL": ""})()
+			a := newFakeAirtable(map[string]map[string]interface{}{"recSso00000000001": {"Feature": "Single sign-on"}})
+			defer useFakeAirtable(a)()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			logs := captureLogs(func() {
+				if err := respond(t, queueMessage{Query: query, ResponseUrl: slack.URL, RequestID: "test"}); err != nil {
+					t.Errorf("Response() error = %v", err)
+				}
+			})
+			if !strings.Contains(logs, "request test:") {
+				t.Errorf("logs don't name the request:\n%s", logs)
+			}
+			if got := strings.Contains(logs, query); got != tt.wantRaw {
+				t.Errorf("logs contain the query = %t, want %t:\n%s", got, tt.wantRaw, logs)
+			}
+			redactQueries = true
+			if got := strings.Contains(logs, logQuery(query)); got != tt.wantHash {
+				t.Errorf("logs contain the hash of the query = %t, want %t:\n%s", got, tt.wantHash, logs)
+			}
+		})
+	}
+}
+
+func TestTrimSearchPrefix(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"lowercase", "search sso", "sso"},
+		{"title case", "Search sso", "sso"},
+		{"uppercase", "SEARCH sso", "sso"},
+		{"mixed case", "sEaRcH single sign on", "single sign on"},
+		{"part of a word", "searchable fields", "searchable fields"},
+		{"prefix on its own", "Search", "Search"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimSearchPrefix(tt.query); got != tt.want {
+				t.Errorf("trimSearchPrefix(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigResetsSettings(t *testing.T) {
+	restore := useEnv(t, map[string]string{
+		"CHANNEL_DEFAULT_SCOPES": "CSALES00000=plan",
+		"ENTITLEMENT_BADGES":     "sso=lock",
+		"SLACK_RESPONSE_HOSTS":   "hooks.example.com",
+		"AIRTABLE_API_URL":       "https://airtable.internal",
+	})
+	if len(channelScopes) != 1 || len(entitlementBadges) != 1 || !containsString(responseHosts, "hooks.example.com") || airtableEndpoint == nil {
+		t.Fatalf("settings weren't loaded: %v %v %v %v", channelScopes, entitlementBadges, responseHosts, airtableEndpoint)
+	}
+	defer restore()
+
+	defer useEnv(t, map[string]string{
+		"CHANNEL_DEFAULT_SCOPES": "",
+		"ENTITLEMENT_BADGES":     "",
+		"SLACK_RESPONSE_HOSTS":   "",
+		"AIRTABLE_API_URL":       "",
+	})()
+	if len(channelScopes) != 0 || len(entitlementBadges) != 0 || containsString(responseHosts, "hooks.example.com") || airtableEndpoint != nil {
+		t.Errorf("settings kept after loading again: %v %v %v %v", channelScopes, entitlementBadges, responseHosts, airtableEndpoint)
+	}
+	if !containsString(responseHosts, defaultResponseHosts) {
+		t.Errorf("responseHosts = %v, want the default host", responseHosts)
+	}
+}
+
+func TestAirtableTimeZoneConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string
+		want    string
+		wantErr bool
+	}{
+		{"default", "", "America/New_York", false},
+		{"valid zone", " Europe/Berlin ", "Europe/Berlin", false},
+		{"UTC", "UTC", "UTC", false},
+		{"misspelled zone", "American/Boston", "", true},
+		{"offset instead of a zone", "+02:00", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, ok := os.LookupEnv("AIRTABLE_TIMEZONE")
+			os.Setenv("AIRTABLE_TIMEZONE", tt.zone)
+			err := loadConfig()
+			if ok {
+				os.Setenv("AIRTABLE_TIMEZONE", old)
+			} else {
+				os.Unsetenv("AIRTABLE_TIMEZONE")
+			}
+			defer loadConfig()
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("invalid AIRTABLE_TIMEZONE %q", tt.zone)) || !strings.Contains(err.Error(), "America/New_York") {
+					t.Errorf("loadConfig() error = %v, want a clear error naming the zone and an example", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if airtableTimeZone != tt.want || airtableLocation.String() != tt.want {
+				t.Errorf("time zone = %q (%s), want %q", airtableTimeZone, airtableLocation, tt.want)
+			}
+
+			a := newFakeAirtable(nil)
+			defer useFakeAirtable(a)()
+			resetQueryCache()
+			if _, err := queryAirtable(parseQuery("sso")); err != nil {
+				t.Fatalf("queryAirtable() error = %v", err)
+			}
+			if len(a.queries) != 1 || a.queries[0].TimeZone != tt.want {
+				t.Errorf("queries = %+v, want the time zone %q sent to Airtable", a.queries, tt.want)
+			}
+
+			slack := newFakeSlack()
+			defer slack.Close()
+			if err := handleLookup(queueMessage{ResponseUrl: slack.URL, Action: lookupAction, Value: "recSso00000000001"}); err != nil {
+				t.Fatalf("handleLookup() error = %v", err)
+			}
+			if len(a.queries) < 2 || a.queries[1].TimeZone != tt.want {
+				t.Errorf("queries = %+v, want the time zone %q sent to Airtable when looking up a feature", a.queries, tt.want)
+			}
+		})
+	}
+}
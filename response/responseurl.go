@@ -0,0 +1,34 @@
+package response
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Hosts Slack response URLs are accepted from unless others are configured.
+const defaultResponseHosts = "hooks.slack.com"
+
+// Hosts accepted for response URLs in dev mode, so the functions can be
+// tested locally against a fake Slack.
+var localHosts = map[string]bool{
+	"localhost": true,
+	"127.0.0.1": true,
+	"::1":       true,
+}
+
+// Function to check that a response URL from a queued message points at
+// Slack before anything is posted to it, so a message that didn't come
+// from the anerbot-queue function can't make this function post results
+// anywhere else. Only HTTPS URLs on an allowed host are accepted, along
+// with any localhost URL in dev mode.
+func responseURLAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if devMode && localHosts[host] && (u.Scheme == "http" || u.Scheme == "https") {
+		return true
+	}
+	return u.Scheme == "https" && containsString(responseHosts, host)
+}
@@ -0,0 +1,36 @@
+package response
+
+import "testing"
+
+func TestResponseURLAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		dev   string
+		hosts string
+		url   string
+		want  bool
+	}{
+		{"Slack", "", "", "https://hooks.slack.com/commands/T1/2/abc", true},
+		{"Slack in capitals", "", "", "https://HOOKS.slack.com/commands/T1/2/abc", true},
+		{"Slack over plain HTTP", "", "", "http://hooks.slack.com/commands/T1/2/abc", false},
+		{"another host", "", "", "https://example.com/hook", false},
+		{"lookalike host", "", "", "https://hooks.slack.com.example.com/hook", false},
+		{"configured host", "", "hooks.example.com", "https://hooks.example.com/hook", true},
+		{"default host once others are configured", "", "hooks.example.com", "https://hooks.slack.com/commands/T1/2/abc", false},
+		{"localhost outside dev mode", "", "", "http://localhost:8080/hook", false},
+		{"localhost in dev mode", "true", "", "http://localhost:8080/hook", true},
+		{"loopback address in dev mode", "true", "", "http://127.0.0.1:8080/hook", true},
+		{"IPv6 loopback in dev mode", "true", "", "http://[::1]:8080/hook", true},
+		{"localhost without HTTP in dev mode", "true", "", "ftp://localhost/hook", false},
+		{"another host in dev mode", "true", "", "http://example.com/hook", false},
+		{"unparsable", "true", "", "http://[::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"DEV_MODE": tt.dev, "SLACK_RESPONSE_HOSTS": tt.hosts})()
+			if got := responseURLAllowed(tt.url); got != tt.want {
+				t.Errorf("responseURLAllowed(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package response
+
+// Struct for an element of a Slack rich text block, used for the list
+// itself, each item of the list and the link inside each item. Offset is
+// the number of items a numbered list starts after.
+type richTextElement struct {
+	Type     string            `json:"type"`
+	Style    string            `json:"style,omitempty"`
+	Offset   int               `json:"offset,omitempty"`
+	Elements []richTextElement `json:"elements,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Text     string            `json:"text,omitempty"`
+}
+
+// Function to build an attachment holding every feature as an item of a
+// Slack-native list, each item linking to the feature. The list is
+// numbered when results are numbered, continuing from the offset, and
+// bulleted otherwise.
+func listAttachment(f []feature, search searchRequest, offset int) attachment {
+	style := "bullet"
+	if numberResults {
+		style = "ordered"
+	}
+
+	list := richTextElement{Type: "rich_text_list", Style: style, Offset: offset}
+	var fallback string
+	for _, v := range f {
+		v = localizeFeature(v, search.language())
+		list.Elements = append(list.Elements, richTextElement{
+			Type: "rich_text_section",
+			Elements: []richTextElement{
+				{Type: "link", URL: titleLink(v.AirtableID, search.Query), Text: featureTitle(v)},
+			},
+		})
+		fallback += v.Fields.Feature + lineDelimiter
+	}
+
+	return attachment{
+		Fallback: fallback,
+		Blocks: []block{
+			{
+				Type:     "rich_text",
+				Elements: []interface{}{list},
+			},
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package response
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRichTextList(t *testing.T) {
+	var records []map[string]interface{}
+	for _, name := range []string{"Audit logs", "Billing", "Custom roles"} {
+		records = append(records, map[string]interface{}{"id": "rec" + name[:1], "fields": map[string]interface{}{"Feature": name}})
+	}
+	f := testFeatures(t, records...)
+
+	tests := []struct {
+		name     string
+		numbered string
+		pageSize string
+		page     int
+		want     string
+	}{
+		{"bulleted", "", "", 0, `{"title":"","fallback":"Audit logs\nBilling\nCustom roles\n","title_link":"","fields":null,"blocks":[{"type":"rich_text","elements":[` +
+			`{"type":"rich_text_list","style":"bullet","elements":[` +
+			`{"type":"rich_text_section","elements":[{"type":"link","url":"https://airtable.com/tblFeatures/viwAll/recA","text":"Audit logs"}]},` +
+			`{"type":"rich_text_section","elements":[{"type":"link","url":"https://airtable.com/tblFeatures/viwAll/recB","text":"Billing"}]},` +
+			`{"type":"rich_text_section","elements":[{"type":"link","url":"https://airtable.com/tblFeatures/viwAll/recC","text":"Custom roles"}]}]}]}]}`},
+		{"numbered from a later page", "true", "2", 2, `{"title":"","fallback":"Custom roles\n","title_link":"","fields":null,"blocks":[{"type":"rich_text","elements":[` +
+			`{"type":"rich_text_list","style":"ordered","offset":2,"elements":[` +
+			`{"type":"rich_text_section","elements":[{"type":"link","url":"https://airtable.com/tblFeatures/viwAll/recC","text":"Custom roles"}]}]}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_RICH_TEXT_LIST": "true",
+				"SLACK_NUMBER_RESULTS": tt.numbered,
+				"SLACK_PAGE_SIZE":      tt.pageSize,
+				"AIRTABLE_TABLE_ID":    "tblFeatures",
+				"AIRTABLE_VIEW_ID":     "viwAll",
+				"TRACKING_URL":         "",
+				"FIELD_LINE_DELIMITER": "",
+			})()
+			search := parseQuery("")
+			search.Page = tt.page
+			res, err := buildSlackResponse(f, search)
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			var lists []string
+			for _, a := range res.Attachments {
+				if len(a.Blocks) > 0 && a.Blocks[0].Type == "rich_text" {
+					b, err := json.Marshal(a)
+					if err != nil {
+						t.Fatal(err)
+					}
+					lists = append(lists, string(b))
+				}
+			}
+			if len(lists) != 1 || lists[0] != tt.want {
+				t.Errorf("list attachments =\n%v\nwant\n%s", lists, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package response
+
+import (
+	"strings"
+)
+
+// Weights used to score how relevant a feature is to a search. Matches in
+// the feature name count for much more than matches in its other fields,
+// and a name starting with the term counts for more than a name merely
+// containing it.
+const (
+	scoreExactName  = 20
+	scoreNamePrefix = 10
+	scoreName       = 5
+	scoreField      = 1
+)
+
+// Function to score how relevant a feature is to the terms of a search
+// request. Each term adds to the score for every field it appears in,
+// with the feature name weighted above the other fields. Each match is
+// multiplied by the weight configured for its field, if any.
+func scoreFeature(f feature, search searchRequest) int {
+	var score int
+	for _, t := range search.Terms {
+		text := t.Text
+		if !caseSensitive {
+			text = foldCase(text)
+		}
+
+		fields := defaultScope(search.ChannelID)
+		if t.Field != "" {
+			fields = []string{t.Field}
+		}
+		for _, name := range fields {
+			value := f.fieldValue(name)
+			if !caseSensitive {
+				value = foldCase(value)
+			}
+			score += bestMatchScore(name, value, text) * fieldWeight(name)
+		}
+	}
+	return score
+}
+
+// Function to return every field the terms of a search request are
+// scored against, so they can be requested from Airtable even when they
+// aren't displayed. No fields are needed when there are no terms.
+func scoredFields(search searchRequest) []string {
+	var fields []string
+	for _, t := range search.Terms {
+		names := defaultScope(search.ChannelID)
+		if t.Field != "" {
+			names = []string{t.Field}
+		}
+		for _, name := range names {
+			if !containsString(fields, name) {
+				fields = append(fields, name)
+			}
+		}
+	}
+	return fields
+}
+
+// Function to score the best match of any variant of a term against the
+// value of one field, so a stemmed variant still scores.
+func bestMatchScore(name, value, text string) int {
+	var best int
+	for _, variant := range termVariants(text) {
+		if s := matchScore(name, value, variant); s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// Function to score a single term matched against the value of one field.
+func matchScore(name, value, text string) int {
+	switch {
+	case name == "Feature" && value == text:
+		return scoreExactName
+	case name == "Feature" && strings.HasPrefix(value, text):
+		return scoreNamePrefix
+	case name == "Feature" && strings.Contains(value, text):
+		return scoreName
+	case strings.Contains(value, text):
+		return scoreField
+	}
+	return 0
+}
+
+// Function to return the weight configured for a field, which is 1 unless
+// another weight has been configured.
+func fieldWeight(name string) int {
+	if w, ok := fieldWeights[name]; ok {
+		return w
+	}
+	return 1
+}
+
+// Function to drop the features scoring below the configured minimum
+// score. Every feature is kept when no minimum has been configured, or
+// when there are no terms to score against, such as a filter on its own.
+func filterByScore(f []feature, search searchRequest) []feature {
+	if minScore <= 0 || len(search.Terms) == 0 {
+		return f
+	}
+	var kept []feature
+	for _, v := range f {
+		if scoreFeature(v, search) >= minScore {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// Function to find the fields of a feature matched by the terms of a
+// search request, in the order they are searched. Terms that weren't
+// scoped to a field are checked against every searchable field, even
+// when a search column is used, so the fields themselves can be named.
+func matchedFields(f feature, search searchRequest) []string {
+	scope := defaultScope(search.ChannelID)
+	if searchColumn != "" && len(scope) == 1 && scope[0] == searchColumn {
+		scope = searchFields
+	}
+
+	var matched []string
+	for _, t := range search.Terms {
+		text := t.Text
+		if !caseSensitive {
+			text = foldCase(text)
+		}
+
+		fields := scope
+		if t.Field != "" {
+			fields = []string{t.Field}
+		}
+		for _, name := range fields {
+			value := f.fieldValue(name)
+			if !caseSensitive {
+				value = foldCase(value)
+			}
+			if text != "" && bestMatchScore(name, value, text) > 0 && !containsString(matched, name) {
+				matched = append(matched, name)
+			}
+		}
+	}
+	return matched
+}
+
+// Function to build the context block listing the fields of a feature
+// matched by a search, labelled as they are displayed. Only the feature
+// name and the fields displayed in the channel are named, so a match in a
+// field hidden from the channel isn't given away. Nil is returned when
+// none of them matched, such as when only a filter was searched.
+func matchesBlock(f feature, search searchRequest) *block {
+	visible := visibleFields(search.ChannelID)
+	var labels []string
+	for _, name := range matchedFields(f, search) {
+		if name == "Feature" {
+			labels = append(labels, name)
+			continue
+		}
+		for _, d := range visible {
+			if d.Name == name {
+				labels = append(labels, d.Label)
+			}
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+
+	return &block{
+		Type: "context",
+		Elements: []interface{}{
+			textObject{Type: "mrkdwn", Text: "Matched in " + strings.Join(labels, ", ")},
+		},
+	}
+}
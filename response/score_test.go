@@ -0,0 +1,117 @@
+package response
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMinimumScore(t *testing.T) {
+	records := map[string]map[string]interface{}{
+		"recExact":   {"Feature": "SSO"},
+		"recPrefix":  {"Feature": "SSO for teams"},
+		"recName":    {"Feature": "Enforced SSO"},
+		"recRoadmap": {"Feature": "Login", "Roadmap": "Add SSO"},
+		"recNothing": {"Feature": "Billing"},
+	}
+
+	tests := []struct {
+		name     string
+		minScore string
+		query    string
+		want     []string
+	}{
+		{"no minimum keeps every match", "", "sso", []string{"recExact", "recName", "recNothing", "recPrefix", "recRoadmap"}},
+		{"minimum of one drops non-matches", "1", "sso", []string{"recExact", "recName", "recPrefix", "recRoadmap"}},
+		{"minimum above a field match", "5", "sso", []string{"recExact", "recName", "recPrefix"}},
+		{"minimum above a name match", "10", "sso", []string{"recExact", "recPrefix"}},
+		{"minimum above every match", "100", "sso", nil},
+		{"filters on their own aren't scored", "100", "flagged:true", []string{"recExact", "recName", "recNothing", "recPrefix", "recRoadmap"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"MIN_RESULT_SCORE":       tt.minScore,
+				"FIELD_WEIGHTS":          "",
+				"AIRTABLE_SEARCH_COLUMN": "",
+				"CHANNEL_DEFAULT_SCOPES": "",
+				"QUERY_CACHE_TTL":        "",
+			})()
+			a := newFakeAirtable(records)
+			defer useFakeAirtable(a)()
+
+			f, err := queryAirtable(parseQuery(tt.query))
+			if err != nil {
+				t.Fatalf("queryAirtable() error = %v", err)
+			}
+			var got []string
+			for _, v := range f {
+				got = append(got, v.AirtableID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelevanceTiers(t *testing.T) {
+	defer func(s string, c string, w map[string]int, f []string) {
+		resultSort, searchColumn, fieldWeights, searchFields = s, c, w, f
+	}(resultSort, searchColumn, fieldWeights, searchFields)
+	resultSort, searchColumn, fieldWeights = sortRelevance, "", nil
+	searchFields = []string{"Feature", "External documentation"}
+
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recDocs", "fields": map[string]interface{}{"Feature": "Login", "External documentation": "https://docs.example.com/sso"}},
+		map[string]interface{}{"id": "recMid", "fields": map[string]interface{}{"Feature": "Enforced SSO"}},
+		map[string]interface{}{"id": "recPrefix", "fields": map[string]interface{}{"Feature": "SSO for teams"}},
+		map[string]interface{}{"id": "recExact", "fields": map[string]interface{}{"Feature": "SSO"}},
+	)
+	search := parseQuery("sso")
+
+	scores := make(map[string]int)
+	for _, v := range f {
+		scores[v.AirtableID] = scoreFeature(v, search)
+	}
+	if !(scores["recExact"] > scores["recPrefix"] && scores["recPrefix"] > scores["recMid"] && scores["recMid"] > scores["recDocs"] && scores["recDocs"] > 0) {
+		t.Errorf("scores = %v, want exact > prefix > mid-string > documentation > 0", scores)
+	}
+	want := []string{"SSO", "SSO for teams", "Enforced SSO", "Login"}
+	if got := featureNamesOf(sortFeatures(f, search)); !reflect.DeepEqual(got, want) {
+		t.Errorf("sortFeatures() = %q, want %q", got, want)
+	}
+}
+
+func TestFieldWeights(t *testing.T) {
+	defer func(s string, c string, f []string) {
+		resultSort, searchColumn, searchFields = s, c, f
+	}(resultSort, searchColumn, searchFields)
+
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recName", "fields": map[string]interface{}{"Feature": "Enforced SSO"}},
+		map[string]interface{}{"id": "recDocs", "fields": map[string]interface{}{"Feature": "Login", "External documentation": "https://docs.example.com/sso"}},
+	)
+	tests := []struct {
+		name    string
+		weights string
+		want    []string
+	}{
+		{"unweighted", "", []string{"Enforced SSO", "Login"}},
+		{"documentation weighted above the name", "docs=10", []string{"Login", "Enforced SSO"}},
+		{"weighted by field name", "External documentation=10", []string{"Login", "Enforced SSO"}},
+		{"name weighted", "feature=2,docs=3", []string{"Enforced SSO", "Login"}},
+		{"unparsable weight ignored", "docs=lots", []string{"Enforced SSO", "Login"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"FIELD_WEIGHTS": tt.weights, "MIN_RESULT_SCORE": ""})()
+			resultSort, searchColumn = sortRelevance, ""
+			searchFields = []string{"Feature", "External documentation"}
+			if got := featureNamesOf(sortFeatures(append([]feature(nil), f...), parseQuery("sso"))); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortFeatures() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package response
+
+import "strings"
+
+// Value of SEARCH_EXPOSED_FIELDS including every field in the JSON of the
+// anerbot-search function, rather than only the fields listed.
+const exposeAllKeyword = "all"
+
+// Function to leave only the allowed fields in features returned by the
+// anerbot-search function, so fields meant for internal use, such as the
+// feature flag, aren't exposed to whoever follows a permalink. The name
+// and ID of each feature are always kept, and are all that is kept when
+// no fields are allowed. Copies of the features are returned and the
+// features passed in are left untouched.
+func restrictFields(f []feature, allowed map[string]bool) []feature {
+	restricted := make([]feature, len(f))
+	for i, v := range f {
+		fields := map[string]*string{
+			"Roadmap":                &v.Fields.Roadmap,
+			"Team responsible":       &v.Fields.TeamResponsible,
+			"Plan":                   &v.Fields.Plan,
+			"Feature flag":           &v.Fields.FeatureFlag,
+			"Entitlements":           &v.Fields.Entitlements,
+			"External documentation": &v.Fields.ExternalDocumentation,
+		}
+		for name, value := range fields {
+			if !allowed[name] {
+				*value = ""
+			}
+		}
+
+		// Translations of a field, such as "Plan_fr", are only kept along
+		// with the field.
+		extra := make(map[string]string)
+		for name, value := range v.Extra {
+			base := name
+			if i := strings.LastIndex(name, languageSeparator); i > 0 {
+				base = name[:i]
+			}
+			if name == "Feature" || base == "Feature" || allowed[name] || allowed[base] {
+				extra[name] = value
+			}
+		}
+		v.Extra = extra
+		restricted[i] = v
+	}
+	return restricted
+}
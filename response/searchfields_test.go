@@ -0,0 +1,133 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRestrictFields(t *testing.T) {
+	var f feature
+	f.AirtableID = "recSso00000000001"
+	f.Fields.Feature = "Single sign-on"
+	f.Fields.Plan = "Enterprise"
+	f.Fields.FeatureFlag = "sso_v2"
+	f.Extra = map[string]string{
+		"Feature":      "Single sign-on",
+		"Feature_fr":   "Authentification unique",
+		"Plan":         "Enterprise",
+		"Plan_fr":      "Entreprise",
+		"Feature flag": "sso_v2",
+		"Internal":     "secret",
+	}
+
+	tests := []struct {
+		name      string
+		allowed   map[string]bool
+		wantPlan  string
+		wantFlag  string
+		wantExtra []string
+	}{
+		{"nothing allowed keeps the name and ID", nil, "", "", []string{"Feature", "Feature_fr"}},
+		{"allowed field is kept with its translations", map[string]bool{"Plan": true}, "Enterprise", "", []string{"Feature", "Feature_fr", "Plan", "Plan_fr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := restrictFields([]feature{f}, tt.allowed)[0]
+			if got.AirtableID != f.AirtableID || got.Fields.Feature != f.Fields.Feature {
+				t.Errorf("restrictFields() = %+v, want the ID and name kept", got)
+			}
+			if got.Fields.Plan != tt.wantPlan || got.Fields.FeatureFlag != tt.wantFlag {
+				t.Errorf("plan %q flag %q, want %q %q", got.Fields.Plan, got.Fields.FeatureFlag, tt.wantPlan, tt.wantFlag)
+			}
+			var extra []string
+			for _, name := range []string{"Feature", "Feature_fr", "Plan", "Plan_fr", "Feature flag", "Internal"} {
+				if _, ok := got.Extra[name]; ok {
+					extra = append(extra, name)
+				}
+			}
+			if !reflect.DeepEqual(extra, tt.wantExtra) {
+				t.Errorf("extra fields = %v, want %v", extra, tt.wantExtra)
+			}
+		})
+	}
+	if f.Fields.FeatureFlag != "sso_v2" || f.Extra["Internal"] != "secret" {
+		t.Errorf("restrictFields() changed the features passed in")
+	}
+}
+
+func TestExposedFieldsConfig(t *testing.T) {
+	defer func(v string, ok bool) {
+		if ok {
+			os.Setenv("SEARCH_EXPOSED_FIELDS", v)
+		} else {
+			os.Unsetenv("SEARCH_EXPOSED_FIELDS")
+		}
+		loadConfig()
+	}(os.LookupEnv("SEARCH_EXPOSED_FIELDS"))
+
+	tests := []struct {
+		value   string
+		wantAll bool
+		want    map[string]bool
+	}{
+		{"", false, map[string]bool{}},
+		{"plan,Internal", false, map[string]bool{"Plan": true, "Internal": true}},
+		{"ALL", true, map[string]bool{}},
+	}
+	for _, tt := range tests {
+		os.Setenv("SEARCH_EXPOSED_FIELDS", tt.value)
+		loadConfig()
+		if exposeAllFields != tt.wantAll || !reflect.DeepEqual(exposedFields, tt.want) {
+			t.Errorf("SEARCH_EXPOSED_FIELDS=%q: all %t fields %v, want %t %v", tt.value, exposeAllFields, exposedFields, tt.wantAll, tt.want)
+		}
+	}
+}
+
+func TestSearchOmitsRestrictedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		exposed string
+		want    []string
+		omitted []string
+	}{
+		{"nothing exposed", "", []string{"Single sign-on"}, []string{"Enterprise", "sso_v2", "Identity"}},
+		{"plan exposed", "plan", []string{"Single sign-on", "Enterprise"}, []string{"sso_v2", "Identity"}},
+		{"everything exposed", "all", []string{"Single sign-on", "Enterprise", "sso_v2", "Identity"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SEARCH_EXPOSED_FIELDS": tt.exposed,
+				"PERMALINK_SECRET":      "s3cret",
+				"QUERY_CACHE_TTL":       "",
+			})()
+			defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{
+				"recSso00000000001": {"Feature": "Single sign-on", "Plan": "Enterprise", "Feature flag": "sso_v2", "Team responsible": "Identity"},
+			}))()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/?"+url.Values{"q": {"sso"}}.Encode(), nil)
+			r.Header.Set("Authorization", "Bearer s3cret")
+			Search(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Search() status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, v := range tt.want {
+				if !strings.Contains(body, v) {
+					t.Errorf("JSON %s is missing %q", body, v)
+				}
+			}
+			for _, v := range tt.omitted {
+				if strings.Contains(body, v) {
+					t.Errorf("JSON %s includes %q, which isn't exposed", body, v)
+				}
+			}
+		})
+	}
+}
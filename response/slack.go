@@ -0,0 +1,277 @@
+package response
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Variables used for Slack validation that will not change.
+const (
+	version                     = "v0"
+	slackRequestTimestampHeader = "X-Slack-Request-Timestamp"
+	slackSignatureHeader        = "X-Slack-Signature"
+)
+
+// Action IDs of the interactive components added to results. Actions
+// are handled by the anerbot-queue function, which receives every
+// interaction from Slack.
+const (
+	featureSelectActionID = "feature_select"
+	copyLinkActionID      = "copy_link"
+	reportActionID        = "report_incorrect"
+	shareActionID         = "share_results"
+	viewAllActionID       = "view_all"
+	subscribeActionID     = "subscribe_updates"
+	fieldDetailsActionID  = "field_details"
+	pageActionID          = "change_page"
+	goToPageActionID      = "go_to_page"
+)
+
+// Struct for the value of a "report incorrect data" button, identifying
+// the feature that was reported.
+type reportValue struct {
+	ID      string `json:"id"`
+	Feature string `json:"feature"`
+	Link    string `json:"link"`
+}
+
+// Maximum number of options Slack accepts in an overflow menu, and the
+// maximum length of the text of each option.
+const (
+	maxOverflowOptions = 5
+	maxOptionLength    = 75
+)
+
+// Maximum number of options Slack accepts in an options-load response.
+const maxSelectOptions = 100
+
+// Struct for the options-load request Slack sends as the user types into
+// an external select menu.
+type suggestionPayload struct {
+	Type     string `json:"type"`
+	ActionID string `json:"action_id"`
+	Value    string `json:"value"`
+}
+
+// Struct for the response to an options-load request.
+type optionsResponse struct {
+	Options []selectOption `json:"options"`
+}
+
+// Struct for a single option in an options-load response.
+type selectOption struct {
+	Text  textObject `json:"text"`
+	Value string     `json:"value"`
+}
+
+// Entry point for GCF anerbot-options function. Slack calls this as a
+// user types into the feature select menu and expects a list of options
+// matching what has been typed so far.
+func Options(w http.ResponseWriter, r *http.Request) {
+	// Check if the method of the request was a "POST". Messages
+	// from Slack should not come in any other method.
+	if r.Method != "POST" {
+		http.Error(w, "Only POST requests are accepted", 405)
+		return
+	}
+
+	// Reject every request while the signing secret hasn't been
+	// configured, as any request signed with an empty secret would
+	// otherwise be trusted.
+	if slackSigSecret == "" {
+		log.Printf("SLACK_SIG_SECRET is not set, unable to validate requests")
+		http.Error(w, "Signing secret is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Validate that our request is legitimate and actually came
+	// from Slack before parsing the form out of the body.
+	ok, err := verifyWebHook(r, slackSigSecret)
+	if err != nil {
+		log.Printf("verifyWebhook: %v", err)
+	}
+	if !ok {
+		http.Error(w, "Unable to validate request", 401)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Couldn't parse form", 400)
+		return
+	}
+
+	// The details of the request are sent as JSON in the payload field.
+	var p suggestionPayload
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &p); err != nil {
+		http.Error(w, "Couldn't parse payload", 400)
+		return
+	}
+
+	names, err := featureNameList()
+	if err != nil {
+		log.Printf("unable to load feature names for options: %v", err)
+	}
+
+	// Marshal our response struct into JSON and respond to the request.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(buildOptions(p.Value, names))
+	if err != nil {
+		log.Printf("json.Marshal: %v", err)
+	}
+}
+
+// Function to build the options matching what the user has typed so far.
+// Names starting with the typed value are listed ahead of names that
+// only contain it elsewhere. The value of each option is the feature's
+// record ID so picking it shows exactly that feature.
+func buildOptions(value string, names []featureName) optionsResponse {
+	value = foldCase(strings.TrimSpace(value))
+
+	var prefixed, contained []selectOption
+	for _, name := range names {
+		n := foldCase(name.Name)
+		if !strings.Contains(n, value) {
+			continue
+		}
+
+		// Slack limits option text to 75 characters, so longer names
+		// are shortened the same way as long titles.
+		o := selectOption{
+			Text:  textObject{Type: "plain_text", Text: ellipsize(name.Name, 75)},
+			Value: name.ID,
+		}
+		if strings.HasPrefix(n, value) {
+			prefixed = append(prefixed, o)
+		} else {
+			contained = append(contained, o)
+		}
+	}
+
+	res := optionsResponse{Options: append(prefixed, contained...)}
+	if res.Options == nil {
+		res.Options = []selectOption{}
+	}
+	if len(res.Options) > maxSelectOptions {
+		res.Options = res.Options[:maxSelectOptions]
+	}
+	return res
+}
+
+// Function to build the block containing the feature select menu, which
+// loads its options from the anerbot-options function as the user types.
+func featureSelectBlock() block {
+	minQueryLength := 2
+	return block{
+		Type: "actions",
+		Elements: []interface{}{
+			blockElement{
+				Type:           "external_select",
+				ActionID:       featureSelectActionID,
+				Placeholder:    &textObject{Type: "plain_text", Text: "Jump to a feature"},
+				MinQueryLength: &minQueryLength,
+			},
+		},
+	}
+}
+
+// Function to build the button that shares the results of a search with
+// the rest of the channel. The query is the value of the button so the
+// search can be run again and posted to the channel.
+func shareElement(search searchRequest) blockElement {
+	return blockElement{
+		Type:     "button",
+		ActionID: shareActionID,
+		Text:     &textObject{Type: "plain_text", Text: "Share to channel"},
+		Value:    search.Query,
+	}
+}
+
+// Function to build the button opening the Airtable view the results came
+// from. Slack opens the link itself, so the click needs no handling beyond
+// being acknowledged.
+func viewAllElement() blockElement {
+	return blockElement{
+		Type:     "button",
+		ActionID: viewAllActionID,
+		Text:     &textObject{Type: "plain_text", Text: "Open in Airtable"},
+		URL:      viewURL(),
+	}
+}
+
+// Function to validate that the request we received was actually from Slack.
+func verifyWebHook(r *http.Request, slackSigningSecret string) (bool, error) {
+	// Set basic control data  from the request itself.
+	timeStamp := r.Header.Get(slackRequestTimestampHeader)
+	slackSignature := r.Header.Get(slackSignatureHeader)
+
+	// Convert the timestamp into an integer for comparing.
+	t, err := strconv.ParseInt(timeStamp, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("strconv.ParseInt(%s): %v", timeStamp, err)
+	}
+
+	// Validate that the time this message was sent was within the last five minutes.
+	if ageOk, age := checkTimestamp(t); !ageOk {
+		return false, fmt.Errorf("checkTimestamp(%v): %v %v", t, ageOk, age)
+	}
+
+	// Verify that the headers actually contained the needed controls.
+	if timeStamp == "" || slackSignature == "" {
+		return false, fmt.Errorf("either timeStamp or signature headers were blank")
+	}
+
+	// Generate a slice of bytes representing the body for hashing.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return false, fmt.Errorf("ioutil.ReadAll(%v): %v", r.Body, err)
+	}
+
+	// Reset the body so other calls won't fail.
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+
+	// Create the string used to validate the signature. The string is
+	// based on the Slack version (which is always "v0"), the timestamp,
+	// and the body itself.
+	baseString := fmt.Sprintf("%s:%s:%s", version, timeStamp, body)
+
+	// Generate the signature of this request based on all the parts and the
+	// original signing secret from Slack.
+	signature := getSignature([]byte(baseString), []byte(slackSigningSecret))
+
+	// Drop the "v0=" off the front of the signature since the computed
+	// one will not have it. Convert the trimmed hex string into bytes.
+	trimmed := strings.TrimPrefix(slackSignature, fmt.Sprintf("%s=", version))
+	signatureInHeader, err := hex.DecodeString(trimmed)
+	if err != nil {
+		return false, fmt.Errorf("hex.DecodeString(%v): %v", trimmed, err)
+	}
+
+	// Compare the two values and return true if they are a match.
+	return hmac.Equal(signature, signatureInHeader), nil
+}
+
+// Function to validate the time of the request being set.
+func checkTimestamp(timeStamp int64) (bool, time.Duration) {
+	t := time.Since(time.Unix(timeStamp, 0))
+
+	// Arbitrarily trusting messages sent within the last five minutes.
+	return t.Minutes() <= 5, t
+}
+
+// Function to generate a checksum used to compare the secrets.
+func getSignature(base []byte, secret []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(base)
+
+	return h.Sum(nil)
+}
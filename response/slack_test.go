@@ -0,0 +1,126 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildOptionsUsesRecordIDs(t *testing.T) {
+	names := []featureName{
+		{ID: "recSso00000000001", Name: "Single sign-on"},
+		{ID: "recSso00000000002", Name: "Single sign-on"},
+		{ID: "recBill0000000000", Name: "Billing sign-up"},
+		{ID: "recAudit000000000", Name: "Audit log"},
+	}
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"single", []string{"recSso00000000001", "recSso00000000002"}},
+		{"sign", []string{"recSso00000000001", "recSso00000000002", "recBill0000000000"}},
+		{"up", []string{"recBill0000000000"}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, o := range buildOptions(tt.value, names).Options {
+			got = append(got, o.Value)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("buildOptions(%q) values = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLongOptionNamesShortened(t *testing.T) {
+	name := strings.Repeat("a", 80)
+	got := buildOptions("a", []featureName{{ID: "recLong0000000000", Name: name}}).Options
+	if want := strings.Repeat("a", 74) + "…"; len(got) != 1 || got[0].Text.Text != want {
+		t.Errorf("options = %+v, want the name shortened to %q", got, want)
+	}
+}
+
+// Function to build a request signed with the signing secret as Slack
+// would sign it.
+func signedSlackRequest(form url.Values, secret string) *http.Request {
+	body := form.Encode()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := getSignature([]byte(fmt.Sprintf("%s:%s:%s", version, ts, body)), []byte(secret))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set(slackRequestTimestampHeader, ts)
+	r.Header.Set(slackSignatureHeader, fmt.Sprintf("%s=%x", version, sig))
+	return r
+}
+
+func TestOptionsLoad(t *testing.T) {
+	defer func(s string) { slackSigSecret = s }(slackSigSecret)
+	slackSigSecret = "test-signing-secret"
+	a := newFakeAirtable(map[string]map[string]interface{}{
+		"recAudit000000000": {"Feature": "Audit log"},
+		"recSso00000000001": {"Feature": "Single sign-on"},
+		"recBill0000000000": {"Feature": "Billing sign-up"},
+	})
+	defer useFakeAirtable(a)()
+	resetNameCache()
+	defer resetNameCache()
+
+	tests := []struct {
+		name       string
+		secret     string
+		value      string
+		wantStatus int
+		want       []string
+	}{
+		{"names starting with the value come first", slackSigSecret, "si", http.StatusOK, []string{"Single sign-on", "Billing sign-up"}},
+		{"nothing matches", slackSigSecret, "roadmap", http.StatusOK, nil},
+		{"unsigned requests are rejected", "wrong-secret", "sign", http.StatusUnauthorized, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := fmt.Sprintf(`{"type":"block_suggestion","action_id":%q,"value":%q}`, featureSelectActionID, tt.value)
+			w := httptest.NewRecorder()
+			Options(w, signedSlackRequest(url.Values{"payload": {payload}}, tt.secret))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var res optionsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("unable to parse options %q: %v", w.Body.String(), err)
+			}
+			var got []string
+			for _, o := range res.Options {
+				got = append(got, o.Text.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("options = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionsWithoutSigningSecret(t *testing.T) {
+	defer func(s string) { slackSigSecret = s }(slackSigSecret)
+	slackSigSecret = ""
+
+	// A request signed with an empty secret would pass validation if the
+	// missing secret were used as is.
+	payload := fmt.Sprintf(`{"type":"block_suggestion","action_id":%q,"value":"sign"}`, featureSelectActionID)
+	w := httptest.NewRecorder()
+	Options(w, signedSlackRequest(url.Values{"payload": {payload}}, ""))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
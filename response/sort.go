@@ -0,0 +1,116 @@
+package response
+
+import (
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Sort modes that can be configured to order the results sent to Slack.
+// Results are sorted alphabetically by name unless another sort mode is
+// configured, while the Airtable sort mode leaves results in the order
+// Airtable returned them.
+const (
+	sortName       = "name"
+	sortPopularity = "popularity"
+	sortPlanTier   = "plan"
+	sortRelevance  = "relevance"
+	sortAirtable   = "airtable"
+)
+
+// Function to order a slice of features by the configured sort mode,
+// scoring relevance against the search request passed in. The slice
+// passed in is left untouched and a sorted copy is returned. Features
+// tied in every sort mode but the Airtable mode are ordered by name and
+// then ID, so the same results are always shown in the same order
+// whether they were fetched from Airtable or served from the cache.
+func sortFeatures(f []feature, search searchRequest) []feature {
+	sorted := make([]feature, len(f))
+	copy(sorted, f)
+
+	switch resultSort {
+	case sortPopularity:
+		// Gather the view counts for every feature and order the most
+		// viewed features first. Fall back to ordering by name if the
+		// counts can't be loaded since the results are still valid.
+		ids := make([]string, len(sorted))
+		for i, v := range sorted {
+			ids[i] = v.AirtableID
+		}
+		var counts map[string]int
+		if viewCounts != nil {
+			var err error
+			counts, err = viewCounts.Counts(ids)
+			if err != nil {
+				log.Printf("unable to load view counts: %v", err)
+			}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := counts[sorted[i].AirtableID], counts[sorted[j].AirtableID]
+			if a != b {
+				return a > b
+			}
+			return lessByName(sorted[i], sorted[j])
+		})
+	case sortPlanTier:
+		// Order features by the rank of their plan tier, lowest rank
+		// first. Features with an unknown plan sort last.
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := planRank(sorted[i].Fields.Plan), planRank(sorted[j].Fields.Plan)
+			if a != b {
+				return a < b
+			}
+			return lessByName(sorted[i], sorted[j])
+		})
+	case sortRelevance:
+		// Order the most relevant features first, scoring each feature
+		// once up front rather than on every comparison.
+		scores := make(map[string]int, len(sorted))
+		for _, v := range sorted {
+			scores[v.AirtableID] = scoreFeature(v, search)
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := scores[sorted[i].AirtableID], scores[sorted[j].AirtableID]
+			if a != b {
+				return a > b
+			}
+			return lessByName(sorted[i], sorted[j])
+		})
+	case sortAirtable:
+		// Leave the results in the order Airtable returned them.
+	default:
+		// Order features alphabetically by name, ignoring case.
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return lessByName(sorted[i], sorted[j])
+		})
+	}
+
+	return sorted
+}
+
+// Function to order two features alphabetically by name, ignoring case,
+// falling back to their exact names and then their IDs so that no two
+// different features are ever tied.
+func lessByName(a, b feature) bool {
+	if x, y := foldCase(a.Fields.Feature), foldCase(b.Fields.Feature); x != y {
+		return x < y
+	}
+	if a.Fields.Feature != b.Fields.Feature {
+		return a.Fields.Feature < b.Fields.Feature
+	}
+	return a.AirtableID < b.AirtableID
+}
+
+// Function to find the rank of a plan from the configured plan tier
+// ranks. A feature available on several comma-separated plans takes the
+// best rank of them. Unknown plans rank after every configured tier.
+func planRank(plan string) int {
+	rank := math.MaxInt32
+	for _, p := range strings.Split(plan, ",") {
+		if r, ok := planTierRanks[strings.ToLower(strings.TrimSpace(p))]; ok && r < rank {
+			rank = r
+		}
+	}
+	return rank
+}
@@ -0,0 +1,91 @@
+package response
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Function to return the names of features in order.
+func featureNamesOf(f []feature) []string {
+	var names []string
+	for _, v := range f {
+		names = append(names, v.Fields.Feature)
+	}
+	return names
+}
+
+func TestPlanTierSort(t *testing.T) {
+	defer func(s string, r map[string]int) { resultSort, planTierRanks = s, r }(resultSort, planTierRanks)
+	resultSort = sortPlanTier
+
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recAudit000000000", "fields": map[string]interface{}{"Feature": "Audit log", "Plan": "Team"}},
+		map[string]interface{}{"id": "recBeta0000000000", "fields": map[string]interface{}{"Feature": "Beta flags"}},
+		map[string]interface{}{"id": "recBill0000000000", "fields": map[string]interface{}{"Feature": "Billing", "Plan": "Free, Enterprise"}},
+		map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "Single sign-on", "Plan": "Enterprise"}},
+		map[string]interface{}{"id": "recZapier00000000", "fields": map[string]interface{}{"Feature": "Zapier", "Plan": "Legacy"}},
+	)
+	tests := []struct {
+		name  string
+		ranks string
+		want  []string
+	}{
+		{"default ranks", "", []string{"Billing", "Single sign-on", "Audit log", "Beta flags", "Zapier"}},
+		{"configured ranks", "free=1,team=2", []string{"Billing", "Audit log", "Beta flags", "Single sign-on", "Zapier"}},
+		{"unknown plans last with sparse ranks", "team=10,enterprise=20", []string{"Audit log", "Billing", "Single sign-on", "Beta flags", "Zapier"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planTierRanks = parseRanks(tt.ranks, "Enterprise=1,Team=2,Free=3")
+			if got := featureNamesOf(sortFeatures(f, searchRequest{})); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlphabeticalSort(t *testing.T) {
+	defer func(s string, n, r bool, g string) {
+		resultSort, numberResults, richTextList, resultGroup = s, n, r, g
+	}(resultSort, numberResults, richTextList, resultGroup)
+	numberResults, richTextList, resultGroup = false, false, ""
+
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recC", "fields": map[string]interface{}{"Feature": "exports"}},
+		map[string]interface{}{"id": "recA", "fields": map[string]interface{}{"Feature": "Billing"}},
+		map[string]interface{}{"id": "recD", "fields": map[string]interface{}{"Feature": "Audit logs"}},
+		map[string]interface{}{"id": "recB", "fields": map[string]interface{}{"Feature": "Éclair"}},
+		map[string]interface{}{"id": "recE", "fields": map[string]interface{}{"Feature": "Dashboards"}},
+	)
+
+	tests := []struct {
+		name string
+		sort string
+		want []string
+	}{
+		{"alphabetical by default", "", []string{"Audit logs", "Billing", "Dashboards", "exports", "Éclair"}},
+		{"alphabetical by name", sortName, []string{"Audit logs", "Billing", "Dashboards", "exports", "Éclair"}},
+		{"Airtable order", sortAirtable, []string{"exports", "Billing", "Audit logs", "Éclair", "Dashboards"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resultSort = tt.sort
+			res, err := buildSlackResponse(f, parseQuery(""))
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			var got []string
+			for _, a := range res.Attachments {
+				if a.TitleLink != "" {
+					got = append(got, a.Title)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attachments = %q, want %q", got, tt.want)
+			}
+			if names := featureNamesOf(f); names[0] != "exports" {
+				t.Errorf("features passed in were reordered to %q", names)
+			}
+		})
+	}
+}
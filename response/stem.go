@@ -0,0 +1,45 @@
+package response
+
+import (
+	"strings"
+)
+
+// Function to reduce a plural word to its singular form using a few
+// light rules, such as "integrations" to "integration" and "policies" to
+// "policy". Short words and words that only look plural, such as
+// "status" or "analysis", are left as they are.
+func stemWord(word string) string {
+	lower := strings.ToLower(word)
+	switch {
+	case len(lower) > 4 && strings.HasSuffix(lower, "ies"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(lower, "sses"):
+		return word[:len(word)-2]
+	case len(lower) > 4 && (strings.HasSuffix(lower, "xes") || strings.HasSuffix(lower, "zes") ||
+		strings.HasSuffix(lower, "ches") || strings.HasSuffix(lower, "shes")):
+		return word[:len(word)-2]
+	case len(lower) > 3 && strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") &&
+		!strings.HasSuffix(lower, "us") && !strings.HasSuffix(lower, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// Function to return the variants of a term's text that are searched for
+// it. With stemming enabled, the text with every word reduced to its
+// singular form is searched alongside the text itself, so "integrations"
+// also matches "integration".
+func termVariants(text string) []string {
+	if !stemming {
+		return []string{text}
+	}
+
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = stemWord(w)
+	}
+	if stemmed := strings.Join(words, " "); stemmed != text && stemmed != "" {
+		return []string{text, stemmed}
+	}
+	return []string{text}
+}
@@ -0,0 +1,60 @@
+package response
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStemWord(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"integrations", "integration"},
+		{"Integrations", "Integration"},
+		{"policies", "policy"},
+		{"addresses", "address"},
+		{"boxes", "box"},
+		{"searches", "search"},
+		{"status", "status"},
+		{"analysis", "analysis"},
+		{"access", "access"},
+		{"ties", "tie"},
+		{"sso", "sso"},
+	}
+	for _, tt := range tests {
+		if got := stemWord(tt.word); got != tt.want {
+			t.Errorf("stemWord(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestTermVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		stemming string
+		text     string
+		want     []string
+	}{
+		{"stemming off", "", "integrations", []string{"integrations"}},
+		{"plural", "true", "integrations", []string{"integrations", "integration"}},
+		{"every word is stemmed", "true", "audit logs exports", []string{"audit logs exports", "audit log export"}},
+		{"already singular", "true", "integration", []string{"integration"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"QUERY_STEMMING": tt.stemming})()
+			if got := termVariants(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("termVariants(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStemmedFormula(t *testing.T) {
+	defer useEnv(t, map[string]string{"QUERY_STEMMING": "true", "AIRTABLE_CASE_SENSITIVE": ""})()
+	want := `OR(SEARCH('integrations', LOWER({Feature})) > 0, SEARCH('integration', LOWER({Feature})) > 0)`
+	if got := buildFormula(parseQuery("integrations"), []string{"Feature"}); got != want {
+		t.Errorf("buildFormula() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package response
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Interface for a store that keeps a running count of how many times each
+// feature has been viewed, keyed by the feature's Airtable record ID.
+// Views are recorded and read by different functions, so the counts must
+// be kept somewhere all of them can reach rather than in the memory of
+// any one of them.
+type counterStore interface {
+	Increment(id string) error
+	Counts(ids []string) (map[string]int, error)
+}
+
+// Store used to track feature views across requests and functions. It is
+// nil unless a table to keep the views in has been configured.
+var viewCounts counterStore
+
+// Name of the field holding the record ID of the feature viewed in the
+// table of views.
+const viewFeatureField = "Feature ID"
+
+// Maximum number of features whose views are counted in a single
+// Airtable request, keeping the formula short.
+const viewCountBatchSize = 50
+
+// Struct for a counterStore keeping one record for every view in an
+// Airtable table of its own. Views are only ever added, so views landing
+// at the same moment are all counted, and the feature records themselves
+// are never changed, so their last modified time and editor are left as
+// they are.
+type airtableCounterStore struct {
+	table string
+}
+
+// Struct for a single view as kept in the table of views.
+type viewRecord struct {
+	Fields struct {
+		FeatureID string `json:"Feature ID"`
+	} `json:"fields"`
+}
+
+// Function to add a view of a feature to the table of views.
+func (s airtableCounterStore) Increment(id string) error {
+	client, err := newEditor()
+	if err != nil {
+		return fmt.Errorf("unable to create new airtable client: %v", err)
+	}
+
+	var view viewRecord
+	view.Fields.FeatureID = id
+	return client.CreateRecord(s.table, &view)
+}
+
+// Function to return the view count for each of the features requested,
+// counting their records in the table of views. Features that have never
+// been viewed are omitted.
+func (s airtableCounterStore) Counts(ids []string) (map[string]int, error) {
+	client, err := newEditor()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new airtable client: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for start := 0; start < len(ids); start += viewCountBatchSize {
+		end := start + viewCountBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var statements []string
+		for _, id := range ids[start:end] {
+			statements = append(statements, fmt.Sprintf("{%s} = '%s'", viewFeatureField, formulaString(id)))
+		}
+		var views []viewRecord
+		err = client.ListRecords(s.table, &views, airtable.ListParameters{
+			Fields:          []string{viewFeatureField},
+			FilterByFormula: fmt.Sprintf("OR(%s)", strings.Join(statements, ", ")),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range views {
+			counts[v.Fields.FeatureID]++
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,127 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// Struct for a counterStore keeping counts in memory, optionally failing
+// every read.
+type fakeCounterStore struct {
+	counts map[string]int
+	err    error
+}
+
+func (s *fakeCounterStore) Increment(id string) error {
+	s.counts[id]++
+	return nil
+}
+
+func (s *fakeCounterStore) Counts(ids []string) (map[string]int, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	counts := make(map[string]int)
+	for _, id := range ids {
+		if c := s.counts[id]; c > 0 {
+			counts[id] = c
+		}
+	}
+	return counts, nil
+}
+
+func TestPopularitySort(t *testing.T) {
+	defer func(s string, c counterStore) { resultSort, viewCounts = s, c }(resultSort, viewCounts)
+	resultSort = sortPopularity
+
+	f := testFeatures(t,
+		map[string]interface{}{"id": "recGamma000000000", "fields": map[string]interface{}{"Feature": "Gamma"}},
+		map[string]interface{}{"id": "recAlpha000000000", "fields": map[string]interface{}{"Feature": "Alpha"}},
+		map[string]interface{}{"id": "recBeta0000000000", "fields": map[string]interface{}{"Feature": "Beta"}},
+	)
+	tests := []struct {
+		name  string
+		store counterStore
+		want  []string
+	}{
+		{"most viewed first", &fakeCounterStore{counts: map[string]int{"recGamma000000000": 5, "recBeta0000000000": 2}}, []string{"Gamma", "Beta", "Alpha"}},
+		{"ties ordered by name", &fakeCounterStore{counts: map[string]int{"recGamma000000000": 1, "recBeta0000000000": 1}}, []string{"Beta", "Gamma", "Alpha"}},
+		{"unreadable counts ordered by name", &fakeCounterStore{err: errors.New("unavailable")}, []string{"Alpha", "Beta", "Gamma"}},
+		{"no store ordered by name", nil, []string{"Alpha", "Beta", "Gamma"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viewCounts = tt.store
+			var got []string
+			for _, v := range sortFeatures(f, searchRequest{}) {
+				got = append(got, v.Fields.Feature)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAirtableCounterStore(t *testing.T) {
+	a := newFakeAirtable(map[string]map[string]interface{}{
+		"recAlpha000000000": {"Feature": "Alpha"},
+		"recBeta0000000000": {"Feature": "Beta"},
+	})
+	defer useFakeAirtable(a)()
+	s := airtableCounterStore{table: "Views"}
+
+	for _, id := range []string{"recAlpha000000000", "recBeta0000000000", "recBeta0000000000"} {
+		if err := s.Increment(id); err != nil {
+			t.Fatalf("Increment(%s) error = %v", id, err)
+		}
+	}
+
+	got, err := s.Counts([]string{"recAlpha000000000", "recBeta0000000000", "recGamma000000000"})
+	if err != nil {
+		t.Fatalf("Counts() error = %v", err)
+	}
+	if want := map[string]int{"recAlpha000000000": 1, "recBeta0000000000": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %v, want %v", got, want)
+	}
+	if n := len(a.tables["Views"]); n != 3 {
+		t.Errorf("views table has %d records, want one for each of the 3 views", n)
+	}
+	for id, fields := range a.records {
+		if len(fields) != 1 {
+			t.Errorf("feature %s was changed to %v, want it left untouched", id, fields)
+		}
+	}
+}
+
+func TestAirtableCounterStoreBatches(t *testing.T) {
+	a := newFakeAirtable(nil)
+	defer useFakeAirtable(a)()
+	s := airtableCounterStore{table: "Views"}
+
+	ids := make([]string, viewCountBatchSize+1)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("rec%014d", i)
+	}
+	if _, err := s.Counts(ids); err != nil {
+		t.Fatalf("Counts() error = %v", err)
+	}
+	if len(a.queries) != 2 {
+		t.Errorf("Counts() of %d features made %d queries, want 2", len(ids), len(a.queries))
+	}
+}
+
+func TestViewEventsConfig(t *testing.T) {
+	defer func(c counterStore) { viewCounts = c }(viewCounts)
+
+	defer useEnv(t, map[string]string{"VIEW_EVENTS_TABLE": ""})()
+	if viewCounts != nil {
+		t.Errorf("viewCounts = %v without VIEW_EVENTS_TABLE, want nil", viewCounts)
+	}
+	defer useEnv(t, map[string]string{"VIEW_EVENTS_TABLE": " Views "})()
+	if want := (airtableCounterStore{table: "Views"}); viewCounts != want {
+		t.Errorf("viewCounts = %v, want %v", viewCounts, want)
+	}
+}
@@ -0,0 +1,155 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/smfsh/airtable-go"
+)
+
+// Action sent by the anerbot-queue function when a user subscribes to
+// updates on a feature.
+const subscribeAction = "subscribe"
+
+// Struct for the value of a "subscribe to updates" button, identifying
+// the feature and the roadmap it had when the results were shown, which
+// later roadmaps are compared against.
+type subscribeValue struct {
+	ID      string `json:"id"`
+	Feature string `json:"feature"`
+	Roadmap string `json:"roadmap"`
+}
+
+// Struct for a user's subscription to updates on a feature. The roadmap
+// is the last roadmap the user was told about.
+type subscription struct {
+	UserID    string
+	FeatureID string
+	Feature   string
+	Roadmap   string
+}
+
+// Interface for a store that keeps the subscriptions users have made to
+// updates on features. Subscriptions are made through anerbot-response
+// and read by anerbot-poll, so they must be kept somewhere both of them
+// can reach rather than in the memory of either.
+type subscriptionStore interface {
+	Subscribe(s subscription) error
+	Subscriptions() ([]subscription, error)
+}
+
+// Store used to keep subscriptions across requests and functions. It is
+// nil unless a table to keep them in has been configured.
+var subscriptions subscriptionStore
+
+// Struct for a subscriptionStore keeping each subscription as a record in
+// its own Airtable table, in the same base as the features, with "User",
+// "Feature ID", "Feature" and "Roadmap" text fields.
+type airtableSubscriptionStore struct {
+	table string
+}
+
+// Struct for a subscription as it is kept in Airtable.
+type subscriptionRecord struct {
+	AirtableID string `json:"id"`
+	Fields     struct {
+		User      string
+		FeatureID string `json:"Feature ID"`
+		Feature   string
+		Roadmap   string
+	} `json:"fields"`
+}
+
+// Function to record a subscription. Subscribing to the same feature again
+// replaces the earlier subscription.
+func (st airtableSubscriptionStore) Subscribe(s subscription) error {
+	client, err := newEditor()
+	if err != nil {
+		return fmt.Errorf("unable to create new airtable client: %v", err)
+	}
+
+	var existing []subscriptionRecord
+	err = client.ListRecords(st.table, &existing, airtable.ListParameters{
+		FilterByFormula: fmt.Sprintf("AND({User} = '%s', {Feature ID} = '%s')", formulaString(s.UserID), formulaString(s.FeatureID)),
+		MaxRecords:      1,
+	})
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"User":       s.UserID,
+		"Feature ID": s.FeatureID,
+		"Feature":    s.Feature,
+		"Roadmap":    s.Roadmap,
+	}
+	if len(existing) > 0 {
+		return client.UpdateRecord(st.table, existing[0].AirtableID, fields, nil)
+	}
+	return client.CreateRecord(st.table, &struct {
+		Fields map[string]interface{} `json:"fields"`
+	}{fields})
+}
+
+// Function to return every subscription, in no particular order.
+func (st airtableSubscriptionStore) Subscriptions() ([]subscription, error) {
+	client, err := newEditor()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new airtable client: %v", err)
+	}
+
+	var records []subscriptionRecord
+	if err := client.ListRecords(st.table, &records); err != nil {
+		return nil, err
+	}
+	subs := make([]subscription, 0, len(records))
+	for _, r := range records {
+		subs = append(subs, subscription{
+			UserID:    r.Fields.User,
+			FeatureID: r.Fields.FeatureID,
+			Feature:   r.Fields.Feature,
+			Roadmap:   r.Fields.Roadmap,
+		})
+	}
+	return subs, nil
+}
+
+// Function to handle a user subscribing to updates on a feature, letting
+// them know once the subscription has been recorded.
+func handleSubscribe(message queueMessage) error {
+	var v subscribeValue
+	if err := json.Unmarshal([]byte(message.Value), &v); err != nil {
+		return codeErrorf(errInvalidMessage, "request %s: unable to parse subscription: %v", message.RequestID, err)
+	}
+	if message.UserID == "" || !recordIDPattern.MatchString(v.ID) {
+		return codeErrorf(errInvalidMessage, "request %s: subscription is missing a user or a valid feature", message.RequestID)
+	}
+
+	log.Printf("request %s: subscribing %s to %s", message.RequestID, message.UserID, v.ID)
+	text := fmt.Sprintf(`You'll get a message when the roadmap for "%s" changes :bell:`, v.Feature)
+	err := fmt.Errorf("SUBSCRIPTIONS_TABLE is not set")
+	if subscriptions != nil {
+		err = subscriptions.Subscribe(subscription{
+			UserID:    message.UserID,
+			FeatureID: v.ID,
+			Feature:   v.Feature,
+			Roadmap:   v.Roadmap,
+		})
+	}
+	if err != nil {
+		log.Printf("request %s: unable to record subscription: %v", message.RequestID, err)
+		text = fmt.Sprintf(`Anerbot couldn't subscribe you to "%s", try again! :cry:`, v.Feature)
+	}
+
+	res := &slackResponse{
+		ReplaceOriginal: strconv.FormatBool(false),
+		ResponseType:    responseEphemeral,
+		Text:            text,
+	}
+	if err := postToSlack(message.ResponseUrl, res); err != nil {
+		return codeErrorf(errSlack, "request %s: %v", message.RequestID, err)
+	}
+	return nil
+}
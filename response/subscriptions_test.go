@@ -0,0 +1,141 @@
+package response
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAirtableSubscriptionStore(t *testing.T) {
+	a := newFakeAirtable(nil)
+	defer useFakeAirtable(a)()
+	store := airtableSubscriptionStore{table: "Subscriptions"}
+
+	steps := []struct {
+		sub  subscription
+		want []subscription
+	}{
+		{
+			subscription{UserID: "U1", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"},
+			[]subscription{{UserID: "U1", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"}},
+		},
+		{
+			// Another user subscribing to the same feature is kept apart.
+			subscription{UserID: "U2", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"},
+			[]subscription{
+				{UserID: "U1", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"},
+				{UserID: "U2", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"},
+			},
+		},
+		{
+			// Subscribing again replaces the earlier subscription.
+			subscription{UserID: "U1", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q2"},
+			[]subscription{
+				{UserID: "U1", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q2"},
+				{UserID: "U2", FeatureID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"},
+			},
+		},
+	}
+	for i, s := range steps {
+		if err := store.Subscribe(s.sub); err != nil {
+			t.Fatalf("step %d: Subscribe() error = %v", i, err)
+		}
+		got, err := store.Subscriptions()
+		if err != nil {
+			t.Fatalf("step %d: Subscriptions() error = %v", i, err)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].UserID < got[j].UserID })
+		if !reflect.DeepEqual(got, s.want) {
+			t.Errorf("step %d: Subscriptions() = %+v, want %+v", i, got, s.want)
+		}
+	}
+}
+
+func TestHandleSubscribe(t *testing.T) {
+	defer func(s subscriptionStore) { subscriptions = s }(subscriptions)
+	value, _ := json.Marshal(subscribeValue{ID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"})
+
+	tests := []struct {
+		name    string
+		store   subscriptionStore
+		userID  string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"stored", airtableSubscriptionStore{table: "Subscriptions"}, "U1", string(value), "You'll get a message", false},
+		{"no store configured", nil, "U1", string(value), "couldn't subscribe you", false},
+		{"missing user", airtableSubscriptionStore{table: "Subscriptions"}, "", string(value), "", true},
+		{"invalid feature", airtableSubscriptionStore{table: "Subscriptions"}, "U1", `{"id":"nope"}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newFakeAirtable(nil)
+			defer useFakeAirtable(a)()
+			subscriptions = tt.store
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			err := handleSubscribe(queueMessage{ResponseUrl: slack.URL, RequestID: "test", UserID: tt.userID, Value: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handleSubscribe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if text := slack.text(); !strings.Contains(text, tt.want) {
+				t.Errorf("posted %s, want %q", text, tt.want)
+			}
+			if stored := len(a.table("Subscriptions")) > 0; stored != (tt.store != nil) {
+				t.Errorf("stored = %v, want %v", stored, tt.store != nil)
+			}
+		})
+	}
+}
+
+func TestSubscribeButton(t *testing.T) {
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO", "Roadmap": "Q1"}})[0]
+	tests := []struct {
+		name   string
+		button string
+		table  string
+		want   bool
+	}{
+		{"enabled", "true", "Subscriptions", true},
+		{"enabled without a store", "true", "", false},
+		{"disabled", "", "Subscriptions", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_SUBSCRIBE_BUTTON": tt.button,
+				"SUBSCRIPTIONS_TABLE":    tt.table,
+				"SLACK_COPY_LINK_BUTTON": "",
+				"SLACK_REPORT_BUTTON":    "",
+			})()
+
+			var found bool
+			if b := actionsBlock(f); b != nil {
+				for _, e := range b.Elements {
+					button, ok := e.(blockElement)
+					if !ok || button.ActionID != subscribeActionID {
+						continue
+					}
+					found = true
+					var got subscribeValue
+					if err := json.Unmarshal([]byte(button.Value), &got); err != nil {
+						t.Fatalf("button value %q isn't JSON: %v", button.Value, err)
+					}
+					if want := (subscribeValue{ID: "recSso00000000001", Feature: "SSO", Roadmap: "Q1"}); got != want {
+						t.Errorf("button carries %+v, want %+v", got, want)
+					}
+				}
+			}
+			if found != tt.want {
+				t.Errorf("subscribe button shown = %v, want %v", found, tt.want)
+			}
+		})
+	}
+}
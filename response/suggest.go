@@ -0,0 +1,99 @@
+package response
+
+import (
+	"log"
+	"strings"
+)
+
+// Maximum number of feature names suggested when nothing was found.
+const maxSuggestions = 3
+
+// Function to suggest the feature names closest to a query that returned
+// no results, such as when the query contains a typo. Names are compared
+// by edit distance, both as a whole and word by word, and only names that
+// are close enough to plausibly be what the user meant are returned.
+func suggestFeatures(query string) []string {
+	query = foldCase(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	names, err := featureNames()
+	if err != nil {
+		log.Printf("unable to load feature names for suggestions: %v", err)
+		return nil
+	}
+
+	// Allow roughly one edit for every three characters in the query.
+	limit := len([]rune(query)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	// Keep every name sharing the smallest distance found so far.
+	best := limit + 1
+	var suggestions []string
+	for _, name := range names {
+		d := nameDistance(query, foldCase(name))
+		switch {
+		case d < best:
+			best = d
+			suggestions = []string{name}
+		case d == best && len(suggestions) < maxSuggestions:
+			suggestions = append(suggestions, name)
+		}
+	}
+
+	return suggestions
+}
+
+// Function to find the edit distance between a query and a feature name,
+// using whichever is closer of the whole name or any single word in it.
+func nameDistance(query, name string) int {
+	d := levenshtein(query, name)
+	for _, w := range strings.Fields(name) {
+		if wd := levenshtein(query, w); wd < d {
+			d = wd
+		}
+	}
+	return d
+}
+
+// Function to compute the Levenshtein distance between two strings: the
+// minimum number of single character insertions, deletions and
+// substitutions needed to turn one into the other.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Only the previous row of the distance matrix is needed at a time.
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// Function to return the smallest of three integers.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
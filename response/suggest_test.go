@@ -0,0 +1,69 @@
+package response
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Function to empty the cache of feature names, so the next names are
+// fetched from whichever Airtable the test is using.
+func resetNameCache() {
+	nameCache.mu.Lock()
+	nameCache.names, nameCache.fetchedAt = nil, time.Time{}
+	nameCache.mu.Unlock()
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"sso", "", 3},
+		{"biling", "billing", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestFeatures(t *testing.T) {
+	a := newFakeAirtable(map[string]map[string]interface{}{
+		"recAudit000000000": {"Feature": "Audit log"},
+		"recBill0000000000": {"Feature": "Billing portal"},
+		"recSso00000000001": {"Feature": "Single sign-on"},
+	})
+	defer useFakeAirtable(a)()
+	resetNameCache()
+	defer resetNameCache()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"biling", []string{"Billing portal"}},
+		{"Audit lgo", []string{"Audit log"}},
+		{"single sign-in", []string{"Single sign-on"}},
+		{"roadmap", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := suggestFeatures(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggestFeatures(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	res, err := buildSlackResponse(nil, parseQuery("biling"))
+	if err != nil {
+		t.Fatalf("buildSlackResponse() error = %v", err)
+	}
+	if want := `Did you mean "Billing portal"?`; !strings.Contains(res.Text, want) {
+		t.Errorf("header = %q, want it to contain %q", res.Text, want)
+	}
+}
@@ -0,0 +1,73 @@
+package response
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderTeams(t *testing.T) {
+	defer func(m map[string]string) { teamMentions = m }(teamMentions)
+	teamMentions = map[string]string{
+		foldCase("Billing"):  "S0123ABCD",
+		foldCase("Identity"): "C0123ABCDEF",
+		foldCase("Docs"):     "https://example.com/docs",
+		foldCase("Support"):  "Support team",
+		foldCase("Sales"):    "Sales <!channel>",
+		foldCase("Core"):     "C0re",
+		foldCase("Search"):   "s0123abcd",
+	}
+
+	tests := []struct {
+		teams []string
+		want  []string
+	}{
+		{[]string{"Billing"}, []string{"<!subteam^S0123ABCD>"}},
+		{[]string{":one-team: Identity"}, []string{"<#C0123ABCDEF>"}},
+		{[]string{"Docs"}, []string{"<https://example.com/docs|Docs>"}},
+		{[]string{"Support"}, []string{"Support team"}},
+		{[]string{"Sales"}, []string{"Sales &lt;!channel&gt;"}},
+		{[]string{"Core"}, []string{"C0re"}},
+		{[]string{"Search"}, []string{"s0123abcd"}},
+		{[]string{"Unmapped", " ", ":emoji:"}, []string{"Unmapped"}},
+	}
+	for _, tt := range tests {
+		if got := renderTeams(tt.teams); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("renderTeams(%q) = %q, want %q", tt.teams, got, tt.want)
+		}
+	}
+}
+
+func TestMultiTeamField(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		bullets string
+		want    string
+	}{
+		{"comma-separated", "Billing, Identity", "", ":one-team: *Team(s):* <!subteam^S0123ABCD>, <#C0123ABCDEF>\n"},
+		{"one team per line", "Billing\nIdentity\n", "", ":one-team: *Team(s):* <!subteam^S0123ABCD>, <#C0123ABCDEF>\n"},
+		{"emoji already on each team", ":one-team: Billing, :one-team: Unmapped", "", ":one-team: *Team(s):* <!subteam^S0123ABCD>, Unmapped\n"},
+		{"bulleted", "Billing, Unmapped", "team", ":one-team: *Team(s):* \n• <!subteam^S0123ABCD>\n• Unmapped\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"TEAM_MENTIONS":           "Billing=S0123ABCD,Identity=C0123ABCDEF",
+				"BULLET_FIELDS":           tt.bullets,
+				"DISPLAY_FIELDS":          "team",
+				"SLACK_COMPACT_FIELDS":    "",
+				"SLACK_UNAVAILABLE_EMOJI": "",
+				"SLACK_MAX_FIELD_LINES":   "",
+			})()
+			f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO", "Team responsible": tt.value}})[0]
+
+			fields := renderFields(f, searchRequest{})
+			if len(fields) != 1 {
+				t.Fatalf("rendered %d fields, want 1", len(fields))
+			}
+			if fields[0].Value != tt.want {
+				t.Errorf("rendered %q, want %q", fields[0].Value, tt.want)
+			}
+		})
+	}
+}
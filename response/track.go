@@ -0,0 +1,61 @@
+package response
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Struct for a limit on how many times each source may do something in a
+// minute. Attempts are counted in the memory of the instance, so each
+// instance of a function keeps its own counts.
+type rateLimiter struct {
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+// Limiter for the clicks counted from each source by the click tracker.
+var clickLimiter = &rateLimiter{}
+
+// Function to record an attempt from a source, reporting whether it is
+// within the limit passed in for the minute it happened in. A limit of 0
+// or less allows every attempt.
+func (l *rateLimiter) allow(source string, limit int, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Start counting again every minute, which also drops the counts of
+	// sources that haven't been seen since.
+	if window := now.Truncate(time.Minute); !window.Equal(l.window) {
+		l.window = window
+		l.counts = make(map[string]int)
+	}
+	if l.counts[source] >= limit {
+		return false
+	}
+	l.counts[source]++
+	return true
+}
+
+// Function to return the address a request to the click tracker came
+// from. GCF sits behind a proxy which adds the address it received the
+// request from to the end of X-Forwarded-For, so the last address is
+// used; any before it were sent by the client and can't be trusted.
+func clickSource(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		addrs := strings.Split(forwarded, ",")
+		return strings.TrimSpace(addrs[len(addrs)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
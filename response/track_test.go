@@ -0,0 +1,251 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Function to build a request to the click tracker for the link Anerbot
+// would post for a feature found by the query passed in.
+func trackRequest(t *testing.T, id, query string) *http.Request {
+	t.Helper()
+	link, err := url.Parse(titleLink(id, query))
+	if err != nil {
+		t.Fatalf("unable to parse title link: %v", err)
+	}
+	r := httptest.NewRequest("GET", "/?"+link.RawQuery, nil)
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	return r
+}
+
+// Function to configure the click tracker for a test, returning a
+// function to put the previous configuration back.
+func useTracker(limit int) func() {
+	restore := func(u, s string, l int, tbl, v string, c *rateLimiter) func() {
+		return func() {
+			trackingURL, trackingSecret, trackingRateLimit = u, s, l
+			airtableTableID, airtableViewID = tbl, v
+			clickLimiter = c
+		}
+	}(trackingURL, trackingSecret, trackingRateLimit, airtableTableID, airtableViewID, clickLimiter)
+	trackingURL, trackingSecret, trackingRateLimit = "https://example.com/track", "test-tracking-secret", limit
+	airtableTableID, airtableViewID = "tblFeatures", "viwAll"
+	clickLimiter = &rateLimiter{}
+	return restore
+}
+
+func TestTrackChangesPopularityOrder(t *testing.T) {
+	defer useTracker(0)()
+	tests := []struct {
+		name   string
+		clicks []string
+		want   []string
+	}{
+		{"no clicks keeps name order", nil, []string{"Alpha", "Beta", "Gamma"}},
+		{"clicked feature moves first", []string{"recGamma000000000"}, []string{"Gamma", "Alpha", "Beta"}},
+		{"most clicked feature first", []string{"recBeta0000000000", "recGamma000000000", "recBeta0000000000"}, []string{"Beta", "Gamma", "Alpha"}},
+	}
+
+	defer func(s string, c counterStore) { resultSort, viewCounts = s, c }(resultSort, viewCounts)
+	resultSort = sortPopularity
+	viewCounts = airtableCounterStore{table: "Views"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newFakeAirtable(map[string]map[string]interface{}{
+				"recAlpha000000000": {"Feature": "Alpha"},
+				"recBeta0000000000": {"Feature": "Beta"},
+				"recGamma000000000": {"Feature": "Gamma"},
+			})
+			defer useFakeAirtable(a)()
+
+			for _, id := range tt.clicks {
+				w := httptest.NewRecorder()
+				Track(w, trackRequest(t, id, "roadmap"))
+				if w.Code != http.StatusFound {
+					t.Fatalf("Track(%s) status = %d, want %d", id, w.Code, http.StatusFound)
+				}
+			}
+
+			var f []feature
+			if err := a.ListRecords(airtableTableID, &f); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range sortFeatures(f, searchRequest{}) {
+				got = append(got, v.Fields.Feature)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackRejectsInvalidIDs(t *testing.T) {
+	tests := []string{"", "rec123", "https://example.com", "recAAAAAAAAAAAAAA/../x"}
+	for _, id := range tests {
+		w := httptest.NewRecorder()
+		Track(w, httptest.NewRequest("GET", "/?id="+url.QueryEscape(id), nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Track(%q) status = %d, want %d", id, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestTrackOnlyCountsSignedClicks(t *testing.T) {
+	defer useTracker(0)()
+	defer func(c counterStore) { viewCounts = c }(viewCounts)
+
+	signed := trackRequest(t, "recAlpha000000000", "roadmap").URL.Query()
+	tests := []struct {
+		name     string
+		query    url.Values
+		wantHits int
+	}{
+		{"signed link is counted", signed, 1},
+		{"unsigned link", url.Values{"id": {"recAlpha000000000"}, "q": {"roadmap"}}, 0},
+		{"signature for another query", url.Values{"id": {"recAlpha000000000"}, "q": {"billing"}, "sig": signed["sig"]}, 0},
+		{"signature for another feature", url.Values{"id": {"recBeta0000000000"}, "q": {"roadmap"}, "sig": signed["sig"]}, 0},
+		{"malformed signature", url.Values{"id": {"recAlpha000000000"}, "q": {"roadmap"}, "sig": {"not-hex"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeCounterStore{counts: map[string]int{}}
+			viewCounts = store
+			w := httptest.NewRecorder()
+			Track(w, httptest.NewRequest("GET", "/?"+tt.query.Encode(), nil))
+			if w.Code != http.StatusFound {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+			}
+			if want := featureLink(tt.query.Get("id")); w.Header().Get("Location") != want {
+				t.Errorf("Location = %q, want %q", w.Header().Get("Location"), want)
+			}
+			hits := 0
+			for _, c := range store.counts {
+				hits += c
+			}
+			if hits != tt.wantHits {
+				t.Errorf("recorded %d clicks, want %d", hits, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestTrackWithoutSecretCountsNothing(t *testing.T) {
+	defer useTracker(0)()
+	defer func(c counterStore) { viewCounts = c }(viewCounts)
+	r := trackRequest(t, "recAlpha000000000", "roadmap")
+
+	trackingSecret = ""
+	store := &fakeCounterStore{counts: map[string]int{}}
+	viewCounts = store
+	Track(httptest.NewRecorder(), r)
+	if store.counts["recAlpha000000000"] != 0 {
+		t.Errorf("recorded a click without a tracking secret, want none")
+	}
+}
+
+func TestTrackRateLimitsEachSource(t *testing.T) {
+	defer useTracker(2)()
+	defer func(c counterStore) { viewCounts = c }(viewCounts)
+	store := &fakeCounterStore{counts: map[string]int{}}
+	viewCounts = store
+
+	for i := 0; i < 3; i++ {
+		Track(httptest.NewRecorder(), trackRequest(t, "recAlpha000000000", "roadmap"))
+	}
+	other := trackRequest(t, "recAlpha000000000", "roadmap")
+	other.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.8")
+	w := httptest.NewRecorder()
+	Track(w, other)
+
+	if w.Code != http.StatusFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	if got := store.counts["recAlpha000000000"]; got != 3 {
+		t.Errorf("recorded %d clicks, want 2 from the first source and 1 from the second", got)
+	}
+}
+
+func TestRateLimiterWindow(t *testing.T) {
+	l := &rateLimiter{}
+	now := time.Date(2020, 1, 1, 12, 0, 10, 0, time.UTC)
+	tests := []struct {
+		name   string
+		source string
+		at     time.Time
+		want   bool
+	}{
+		{"first attempt", "a", now, true},
+		{"second attempt", "a", now.Add(time.Second), true},
+		{"over the limit", "a", now.Add(2 * time.Second), false},
+		{"other source", "b", now.Add(3 * time.Second), true},
+		{"next minute", "a", now.Add(time.Minute), true},
+	}
+	for _, tt := range tests {
+		if got := l.allow(tt.source, 2, tt.at); got != tt.want {
+			t.Errorf("%s: allow() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+	if !l.allow("a", 0, now) {
+		t.Errorf("allow() with no limit = false, want true")
+	}
+}
+
+func TestClickSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded string
+		want      string
+	}{
+		{"remote address", "", "192.0.2.1"},
+		{"forwarded by the proxy", "203.0.113.7", "203.0.113.7"},
+		{"address sent by the client is ignored", "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := clickSource(r); got != tt.want {
+			t.Errorf("%s: clickSource() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTitleLinkUsesTracker(t *testing.T) {
+	defer useTracker(0)()
+
+	f := testFeatures(t, map[string]interface{}{"id": "recAlpha000000000", "fields": map[string]interface{}{"Feature": "Alpha"}})
+	tests := []struct {
+		name    string
+		tracker string
+		secret  string
+		want    string
+	}{
+		{"no tracker", "", "test-tracking-secret", "https://airtable.com/tblFeatures/viwAll/recAlpha000000000"},
+		{"no secret", "https://example.com/track", "", "https://airtable.com/tblFeatures/viwAll/recAlpha000000000"},
+		{"signed tracker link", "https://example.com/track", "test-tracking-secret", "https://example.com/track?id=recAlpha000000000&q=alpha&sig=" +
+			linkSignature("recAlpha000000000\nalpha", "test-tracking-secret")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trackingURL, trackingSecret = tt.tracker, tt.secret
+			if got := titleLink("recAlpha000000000", "alpha"); got != tt.want {
+				t.Errorf("titleLink() = %q, want %q", got, tt.want)
+			}
+			res, err := buildSlackResponse(f, parseQuery("alpha"))
+			if err != nil {
+				t.Fatalf("buildSlackResponse() error = %v", err)
+			}
+			if got := res.Attachments[0].TitleLink; got != tt.want {
+				t.Errorf("title link = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
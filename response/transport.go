@@ -0,0 +1,144 @@
+package response
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default User-Agent sent with every outbound request.
+const defaultUserAgent = "Anerbot (+https://github.com/smfsh/anerbot)"
+
+// Longest Anerbot waits before retrying a rate limited request, however
+// long Airtable asks it to wait.
+const maxRetryWait = 30 * time.Second
+
+// Struct for an http.RoundTripper that sets the configured User-Agent on
+// every request before passing it on to the next transport.
+type userAgentTransport struct {
+	next http.RoundTripper
+}
+
+// Function to set the User-Agent on a request and send it. The request
+// passed in is cloned rather than modified, as RoundTrippers must not
+// change the requests they are given.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", userAgent)
+	return t.next.RoundTrip(req)
+}
+
+// Struct for an http.RoundTripper that retries requests rejected with a
+// 429 Too Many Requests, waiting as long as the Retry-After header asks
+// before each retry.
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+// Function to send a request, retrying it up to the configured number of
+// times while it is rate limited. The last rate limited response is
+// returned once the retries run out, or as soon as waiting to retry
+// would take the request past its deadline. Only requests without a
+// body, or with a body that can be read again, are retried.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= rateLimitRetries {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+			return resp, nil
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// Function to find how long to wait before retrying a rate limited
+// request from its Retry-After header, which is either a number of
+// seconds or a date. The configured backoff is used when the header is
+// missing or can't be parsed, and waits are capped at maxRetryWait.
+func retryAfter(header string, now time.Time) time.Duration {
+	wait := rateLimitBackoff
+	header = strings.TrimSpace(header)
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+	}
+	if wait > maxRetryWait {
+		wait = maxRetryWait
+	}
+	return wait
+}
+
+// Struct for an http.RoundTripper that sends requests to a different
+// endpoint, such as a proxy in front of the Airtable API, keeping the
+// rest of the request as it is.
+type endpointTransport struct {
+	endpoint *url.URL
+	next     http.RoundTripper
+}
+
+// Function to point a request at the configured endpoint and send it.
+// The path of the endpoint, if any, is placed before the request's path.
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.endpoint.Scheme
+	req.URL.Host = t.endpoint.Host
+	req.URL.Path = strings.TrimSuffix(t.endpoint.Path, "/") + req.URL.Path
+	req.URL.RawPath = ""
+	req.Host = t.endpoint.Host
+	return t.next.RoundTrip(req)
+}
+
+// Function to create the HTTP client used for every outbound request to
+// Slack, which gives up on each request after the configured timeout.
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &userAgentTransport{next: http.DefaultTransport},
+		Timeout:   slackTimeout,
+	}
+}
+
+// Function to create the HTTP client used for every request to Airtable,
+// which also retries requests that were rate limited and sends requests
+// to the configured API endpoint, if any. Each request gives up after the
+// Airtable timeout, including the time spent waiting to retry it.
+func newAirtableHTTPClient() *http.Client {
+	var transport http.RoundTripper = &userAgentTransport{next: http.DefaultTransport}
+	if airtableEndpoint != nil {
+		transport = &endpointTransport{endpoint: airtableEndpoint, next: transport}
+	}
+	return &http.Client{
+		Transport: &retryTransport{next: transport},
+		Timeout:   airtableTimeout,
+	}
+}
@@ -0,0 +1,374 @@
+package response
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Struct for a fake endpoint recording the User-Agent of every request
+// it receives, answering each with an empty list of Airtable records.
+type userAgentRecorder struct {
+	*httptest.Server
+	mu     sync.Mutex
+	agents []string
+}
+
+// Function to start an endpoint recording User-Agents. Call Close once
+// the test is finished.
+func newUserAgentRecorder() *userAgentRecorder {
+	u := &userAgentRecorder{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.agents = append(u.agents, r.UserAgent())
+		u.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"records":[]}`))
+	}))
+	return u
+}
+
+// Function to return the User-Agent of every request received so far.
+func (u *userAgentRecorder) received() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.agents...)
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name  string
+		agent string
+		want  string
+	}{
+		{"default", "", defaultUserAgent},
+		{"configured", "Anerbot/2.0 (+https://example.com/anerbot)", "Anerbot/2.0 (+https://example.com/anerbot)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newUserAgentRecorder()
+			defer u.Close()
+			defer useEnv(t, map[string]string{
+				"HTTP_USER_AGENT":  tt.agent,
+				"AIRTABLE_API_URL": u.URL,
+				"AIRTABLE_API_KEY": "keyTest0000000000",
+				"AIRTABLE_BASE_ID": "appTest0000000000",
+			})()
+
+			if err := postToSlack(u.URL, &slackResponse{Text: "results"}); err != nil {
+				t.Fatalf("postToSlack() error = %v", err)
+			}
+			sendFailureMessage(u.URL, errAirtable)
+			client, err := newLister()
+			if err != nil {
+				t.Fatalf("newLister() error = %v", err)
+			}
+			var f []feature
+			if err := client.ListRecords("tblFeatures", &f); err != nil {
+				t.Fatalf("ListRecords() error = %v", err)
+			}
+
+			agents := u.received()
+			if len(agents) != 3 {
+				t.Fatalf("received %d requests, want one each from Slack, the failure message and Airtable", len(agents))
+			}
+			for i, got := range agents {
+				if got != tt.want {
+					t.Errorf("request %d User-Agent = %q, want %q", i, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	defer func(b time.Duration) { rateLimitBackoff = b }(rateLimitBackoff)
+	rateLimitBackoff = 5 * time.Second
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"seconds", "2", 2 * time.Second},
+		{"padded seconds", " 2 ", 2 * time.Second},
+		{"no wait", "0", 0},
+		{"date", now.Add(3 * time.Second).Format(http.TimeFormat), 3 * time.Second},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"missing header", "", 5 * time.Second},
+		{"unparsable header", "soon", 5 * time.Second},
+		{"negative seconds", "-1", 5 * time.Second},
+		{"capped", "3600", maxRetryWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.header, now); got != tt.want {
+				t.Errorf("retryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTransportHonorsRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	var retryAfterHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n == 1 {
+			w.Header().Set("Retry-After", retryAfterHeader)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		timeout    time.Duration
+		wantStatus int
+		wantWait   time.Duration
+		want       int
+	}{
+		{"waits as long as asked before retrying", "1", 3 * time.Second, http.StatusOK, time.Second, 2},
+		{"retries once the wait is over", "0", time.Second, http.StatusOK, 0, 2},
+		{"gives up when the wait outlasts the deadline", "2", 200 * time.Millisecond, http.StatusTooManyRequests, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"AIRTABLE_RATE_LIMIT_RETRIES": "3"})()
+			mu.Lock()
+			requests, retryAfterHeader = 0, tt.header
+			mu.Unlock()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			resp, err := newAirtableHTTPClient().Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			elapsed := time.Since(start)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if elapsed < tt.wantWait || elapsed >= tt.timeout {
+				t.Errorf("answered after %s, want at least %s and within the deadline of %s", elapsed, tt.wantWait, tt.timeout)
+			}
+			if got := count(); got != tt.want {
+				t.Errorf("server received %d requests, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAirtableEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"records":[{"id":"recSso00000000001","fields":{"Feature":"SSO"}}]}`))
+	}))
+	defer proxy.Close()
+
+	defer useEnv(t, map[string]string{
+		"AIRTABLE_API_URL": proxy.URL + "/airtable/",
+		"AIRTABLE_API_KEY": "keyTest0000000000",
+		"AIRTABLE_BASE_ID": "appTest0000000000",
+	})()
+	client, err := newLister()
+	if err != nil {
+		t.Fatalf("newLister() error = %v", err)
+	}
+	var f []feature
+	if err := client.ListRecords("tblFeatures", &f); err != nil {
+		t.Fatalf("ListRecords() error = %v", err)
+	}
+	if len(f) != 1 || f[0].Fields.Feature != "SSO" {
+		t.Errorf("features = %+v, want the record from the proxy", f)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 1 || !strings.HasPrefix(paths[0], "/airtable/") || !strings.Contains(paths[0], "/appTest0000000000/tblFeatures") {
+		t.Errorf("proxy received %q, want the table path under the endpoint's path", paths)
+	}
+}
+
+func TestInvalidAirtableEndpoint(t *testing.T) {
+	for _, v := range []string{"airtable.internal", "://nope", "/v0"} {
+		old, ok := os.LookupEnv("AIRTABLE_API_URL")
+		os.Setenv("AIRTABLE_API_URL", v)
+		err := loadConfig()
+		if ok {
+			os.Setenv("AIRTABLE_API_URL", old)
+		} else {
+			os.Unsetenv("AIRTABLE_API_URL")
+		}
+		if err == nil || !strings.Contains(err.Error(), "invalid AIRTABLE_API_URL") {
+			t.Errorf("loadConfig() with AIRTABLE_API_URL=%q error = %v, want it rejected", v, err)
+		}
+	}
+	if err := loadConfig(); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+}
+
+func TestSlackRequestTimeout(t *testing.T) {
+	delay := 200 * time.Millisecond
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"records":[]}`))
+	}))
+	defer slow.Close()
+
+	tests := []struct {
+		name    string
+		timeout string
+		wantErr bool
+	}{
+		{"Slack slower than its deadline", "50ms", true},
+		{"Slack within its deadline", "2s", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_REQUEST_TIMEOUT": tt.timeout,
+				"AIRTABLE_API_URL":      slow.URL,
+				"AIRTABLE_API_KEY":      "keyTest0000000000",
+				"AIRTABLE_BASE_ID":      "appTest0000000000",
+			})()
+
+			start := time.Now()
+			err := postToSlack(slow.URL, &slackResponse{Text: "results"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("postToSlack() error = %v, want an error %t", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); tt.wantErr && elapsed >= delay {
+				t.Errorf("postToSlack() gave up after %s, want it to give up at its own deadline", elapsed)
+			}
+
+			// Airtable isn't held to the Slack deadline.
+			client, err := newLister()
+			if err != nil {
+				t.Fatalf("newLister() error = %v", err)
+			}
+			var f []feature
+			if err := client.ListRecords("tblFeatures", &f); err != nil {
+				t.Errorf("ListRecords() error = %v, want Airtable to get its own allowance", err)
+			}
+		})
+	}
+}
+
+func TestAirtableRequestTimeout(t *testing.T) {
+	delay := 200 * time.Millisecond
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"records":[]}`))
+	}))
+	defer slow.Close()
+
+	tests := []struct {
+		name    string
+		timeout string
+		wantErr bool
+	}{
+		{"Airtable slower than its deadline", "50ms", true},
+		{"Airtable within its deadline", "2s", false},
+		{"no deadline", "0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"AIRTABLE_REQUEST_TIMEOUT": tt.timeout,
+				"AIRTABLE_API_URL":         slow.URL,
+				"AIRTABLE_API_KEY":         "keyTest0000000000",
+				"AIRTABLE_BASE_ID":         "appTest0000000000",
+			})()
+			client, err := newLister()
+			if err != nil {
+				t.Fatalf("newLister() error = %v", err)
+			}
+
+			start := time.Now()
+			var f []feature
+			err = client.ListRecords("tblFeatures", &f)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListRecords() error = %v, want an error %t", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); tt.wantErr && elapsed >= delay {
+				t.Errorf("ListRecords() gave up after %s, want it to give up at its deadline", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryCappedByAirtableTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	defer useEnv(t, map[string]string{
+		"AIRTABLE_REQUEST_TIMEOUT":    "1s",
+		"AIRTABLE_RATE_LIMIT_RETRIES": "3",
+	})()
+
+	req, err := http.NewRequest("GET", limited.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	resp, err := newAirtableHTTPClient().Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); resp.StatusCode != http.StatusTooManyRequests || elapsed >= time.Second {
+		t.Errorf("answered %d after %s, want the rate limited response within the timeout", resp.StatusCode, elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("server received %d requests, want no retry that would outlast the timeout", requests)
+	}
+}
@@ -0,0 +1,27 @@
+package response
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Function to generate a link to the configured Airtable view as a whole,
+// used by the "Open in Airtable" button and in place of oversized results.
+// The Airtable web app doesn't filter a view from its URL, so this is the
+// view itself rather than only the features matching a search.
+func viewURL() string {
+	return fmt.Sprintf("https://airtable.com/%s/%s/%s", airtableBaseID, airtableTableID, airtableViewID)
+}
+
+// Function to build the single message sent in place of results too large
+// to send to Slack. It suggests narrowing the search and links to the
+// Airtable view as a whole, since the link can't be limited to the
+// features found.
+func oversizedResponse(res *slackResponse, count int) *slackResponse {
+	text := fmt.Sprintf("Found %d items, which is too many to show in Slack. Try a narrower search, or <%s|open the Airtable view> to look through every feature.", count, viewURL())
+	return &slackResponse{
+		ReplaceOriginal: strconv.FormatBool(true),
+		ResponseType:    res.ResponseType,
+		Text:            styleHeader(text),
+	}
+}
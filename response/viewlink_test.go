@@ -0,0 +1,158 @@
+package response
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostResultsLinksOversizedResultsToTheView(t *testing.T) {
+	defer func(m int, b, tb, v string) {
+		maxResponseMessages, airtableBaseID, airtableTableID, airtableViewID = m, b, tb, v
+	}(maxResponseMessages, airtableBaseID, airtableTableID, airtableViewID)
+	airtableBaseID, airtableTableID, airtableViewID = "appBase", "tblTable", "viwView"
+
+	tests := []struct {
+		name         string
+		maxMessages  int
+		attachments  int
+		wantMessages int
+		wantLink     bool
+	}{
+		{"fits in one message", 2, 10, 1, false},
+		{"split across allowed messages", 2, maxAttachments + 1, 2, false},
+		{"too many messages", 1, maxAttachments + 1, 1, true},
+		{"no limit", 0, 3*maxAttachments + 1, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxResponseMessages = tt.maxMessages
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			res := &slackResponse{ResponseType: responseEphemeral, Text: "Results"}
+			for i := 0; i < tt.attachments; i++ {
+				res.Attachments = append(res.Attachments, attachment{Title: "Feature"})
+			}
+			if err := postResults(queueMessage{ResponseUrl: slack.URL, RequestID: "test"}, res, tt.attachments); err != nil {
+				t.Fatal(err)
+			}
+
+			posted := slack.posted()
+			if len(posted) != tt.wantMessages {
+				t.Fatalf("posted %d messages, want %d", len(posted), tt.wantMessages)
+			}
+			link := "https://airtable.com/appBase/tblTable/viwView"
+			if got := strings.Contains(posted[0].Text, link); got != tt.wantLink {
+				t.Errorf("first message %q links to %s = %v, want %v", posted[0].Text, link, got, tt.wantLink)
+			}
+			if strings.Contains(slack.text(), "filterByFormula") {
+				t.Errorf("posted a filtered view link, which Airtable ignores")
+			}
+		})
+	}
+}
+
+func TestViewAllButtonOpensTheView(t *testing.T) {
+	defer func(v bool, b, tb, vw string) {
+		viewAllButton, airtableBaseID, airtableTableID, airtableViewID = v, b, tb, vw
+	}(viewAllButton, airtableBaseID, airtableTableID, airtableViewID)
+	airtableBaseID, airtableTableID, airtableViewID = "appBase", "tblTable", "viwView"
+
+	f := testFeatures(t, map[string]interface{}{"id": "recSso00000000001", "fields": map[string]interface{}{"Feature": "SSO"}})
+	tests := []struct {
+		name     string
+		enabled  bool
+		features []feature
+		want     bool
+	}{
+		{"enabled with results", true, f, true},
+		{"enabled without results", true, nil, false},
+		{"disabled", false, f, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viewAllButton = tt.enabled
+			defer useFakeAirtable(newFakeAirtable(nil))()
+			res, err := buildSlackResponse(tt.features, parseQuery("sso"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found bool
+			for _, a := range res.Attachments {
+				for _, b := range a.Blocks {
+					for _, e := range b.Elements {
+						if el, ok := e.(blockElement); ok && el.ActionID == viewAllActionID {
+							found = true
+							if el.URL != "https://airtable.com/appBase/tblTable/viwView" {
+								t.Errorf("button links to %s, want the view", el.URL)
+							}
+						}
+					}
+				}
+			}
+			if found != tt.want {
+				t.Errorf("button shown = %v, want %v", found, tt.want)
+			}
+		})
+	}
+}
+
+func TestOversizedResponse(t *testing.T) {
+	defer func(b, tb, v string) {
+		airtableBaseID, airtableTableID, airtableViewID = b, tb, v
+	}(airtableBaseID, airtableTableID, airtableViewID)
+	airtableBaseID, airtableTableID, airtableViewID = "appBase", "tblTable", "viwView"
+
+	tests := []struct {
+		name         string
+		responseType string
+		count        int
+		wantText     string
+	}{
+		{"ephemeral", responseEphemeral, 250, "Found 250 items, which is too many to show in Slack. Try a narrower search, or <https://airtable.com/appBase/tblTable/viwView|open the Airtable view> to look through every feature."},
+		{"in channel", responseInChannel, 1000, "Found 1000 items, which is too many to show in Slack. Try a narrower search, or <https://airtable.com/appBase/tblTable/viwView|open the Airtable view> to look through every feature."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &slackResponse{ResponseType: tt.responseType, Text: "Results", Attachments: []attachment{{Title: "Feature"}}}
+			got := oversizedResponse(res, tt.count)
+			if !strings.Contains(got.Text, tt.wantText) {
+				t.Errorf("text = %q, want %q", got.Text, tt.wantText)
+			}
+			if got.ResponseType != tt.responseType {
+				t.Errorf("response type = %q, want %q", got.ResponseType, tt.responseType)
+			}
+			if got.ReplaceOriginal != "true" {
+				t.Errorf("replace original = %q, want it to replace the searching message", got.ReplaceOriginal)
+			}
+			if len(got.Attachments) != 0 {
+				t.Errorf("kept %d attachments, want only the link", len(got.Attachments))
+			}
+		})
+	}
+}
+
+func TestViewURL(t *testing.T) {
+	defer func(b, tb, v string) {
+		airtableBaseID, airtableTableID, airtableViewID = b, tb, v
+	}(airtableBaseID, airtableTableID, airtableViewID)
+
+	tests := []struct {
+		base, table, view string
+		want              string
+	}{
+		{"appBase", "tblTable", "viwView", "https://airtable.com/appBase/tblTable/viwView"},
+		{"appOther", "tblFeatures", "viwGrid", "https://airtable.com/appOther/tblFeatures/viwGrid"},
+	}
+	for _, tt := range tests {
+		airtableBaseID, airtableTableID, airtableViewID = tt.base, tt.table, tt.view
+		got := viewURL()
+		if got != tt.want {
+			t.Errorf("viewURL() = %q, want %q", got, tt.want)
+		}
+		if strings.Contains(got, "?") {
+			t.Errorf("viewURL() = %q, want no query string since Airtable ignores filters in view links", got)
+		}
+	}
+}
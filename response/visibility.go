@@ -0,0 +1,89 @@
+package response
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Response types Slack accepts. Ephemeral messages are only shown to the
+// user who searched, while in channel messages are shown to everyone.
+const (
+	responseEphemeral = "ephemeral"
+	responseInChannel = "in_channel"
+)
+
+// Struct for a rule deciding who can see a response based on how many
+// results it contains. Max is -1 when the range has no upper bound.
+type visibilityRule struct {
+	Min          int
+	Max          int
+	ResponseType string
+}
+
+// Function to parse the visibility rules from a comma-separated env
+// variable of "range=type" pairs, where a range is a single count such
+// as "0", a span such as "2-5", or an open span such as "6+", and the type
+// is either "ephemeral" or "in_channel". Unparsable rules are dropped.
+func parseVisibility(s string) []visibilityRule {
+	var rules []visibilityRule
+	for _, v := range parseList(s) {
+		i := strings.Index(v, "=")
+		if i < 0 {
+			continue
+		}
+		r, t := strings.TrimSpace(v[:i]), strings.ToLower(strings.TrimSpace(v[i+1:]))
+		if t != responseEphemeral && t != responseInChannel {
+			continue
+		}
+
+		var rule visibilityRule
+		var err error
+		switch {
+		case strings.HasSuffix(r, "+"):
+			rule.Min, err = strconv.Atoi(strings.TrimSuffix(r, "+"))
+			rule.Max = -1
+		case strings.Contains(r, "-"):
+			j := strings.Index(r, "-")
+			rule.Min, err = strconv.Atoi(r[:j])
+			if err == nil {
+				rule.Max, err = strconv.Atoi(r[j+1:])
+			}
+		default:
+			rule.Min, err = strconv.Atoi(r)
+			rule.Max = rule.Min
+		}
+		if err != nil {
+			continue
+		}
+		rule.ResponseType = t
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Function to find the response type for a response with the number of
+// results passed in. The first matching rule wins, and responses are
+// ephemeral when no rule matches.
+func resultVisibility(count int) string {
+	for _, r := range visibilityRules {
+		if count >= r.Min && (r.Max < 0 || count <= r.Max) {
+			return r.ResponseType
+		}
+	}
+	return responseEphemeral
+}
+
+// Function to set who can see a response based on how many results it
+// contains, or in the channel when the user chose to share the results.
+// The original message is only visible to the user who searched, so it
+// is left in place rather than replaced when the results are shown to
+// the channel.
+func applyVisibility(res *slackResponse, count int, search searchRequest) {
+	res.ResponseType = resultVisibility(count)
+	if search.Shared {
+		res.ResponseType = responseInChannel
+	}
+	if res.ResponseType == responseInChannel {
+		res.ReplaceOriginal = strconv.FormatBool(false)
+	}
+}
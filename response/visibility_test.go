@@ -0,0 +1,93 @@
+package response
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVisibility(t *testing.T) {
+	got := parseVisibility("0=ephemeral, 1=IN_CHANNEL, 2-5=ephemeral, 6+=in_channel, x=in_channel, 3=public, 7")
+	want := []visibilityRule{
+		{Min: 0, Max: 0, ResponseType: responseEphemeral},
+		{Min: 1, Max: 1, ResponseType: responseInChannel},
+		{Min: 2, Max: 5, ResponseType: responseEphemeral},
+		{Min: 6, Max: -1, ResponseType: responseInChannel},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVisibility() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResultVisibility(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       string
+		count       int
+		shared      bool
+		want        string
+		wantReplace string
+	}{
+		{"no results", "0=ephemeral,1=in_channel,2-5=ephemeral,6+=in_channel", 0, false, responseEphemeral, "true"},
+		{"single result", "0=ephemeral,1=in_channel,2-5=ephemeral,6+=in_channel", 1, false, responseInChannel, "false"},
+		{"start of a span", "0=ephemeral,1=in_channel,2-5=ephemeral,6+=in_channel", 2, false, responseEphemeral, "true"},
+		{"end of a span", "0=ephemeral,1=in_channel,2-5=ephemeral,6+=in_channel", 5, false, responseEphemeral, "true"},
+		{"open span", "0=ephemeral,1=in_channel,2-5=ephemeral,6+=in_channel", 60, false, responseInChannel, "false"},
+		{"first matching rule wins", "1+=in_channel,1=ephemeral", 1, false, responseInChannel, "false"},
+		{"no matching rule", "1=in_channel", 3, false, responseEphemeral, "true"},
+		{"no rules", "", 1, false, responseEphemeral, "true"},
+		{"shared results", "", 3, true, responseInChannel, "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{"RESULT_VISIBILITY": tt.rules})()
+			res := &slackResponse{ReplaceOriginal: "true"}
+			applyVisibility(res, tt.count, searchRequest{Shared: tt.shared})
+			if res.ResponseType != tt.want || res.ReplaceOriginal != tt.wantReplace {
+				t.Errorf("applyVisibility(%d) = %s replacing %s, want %s replacing %s", tt.count, res.ResponseType, res.ReplaceOriginal, tt.want, tt.wantReplace)
+			}
+		})
+	}
+}
+
+func TestShareResults(t *testing.T) {
+	tests := []struct {
+		name        string
+		shared      bool
+		want        string
+		wantReplace string
+		wantButton  bool
+	}{
+		{"ephemeral results offer to share", false, responseEphemeral, "true", true},
+		{"shared results are posted to the channel", true, responseInChannel, "false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer useEnv(t, map[string]string{
+				"SLACK_SHARE_BUTTON": "true",
+				"RESULT_VISIBILITY":  "",
+				"QUERY_CACHE_TTL":    "",
+			})()
+			defer useFakeAirtable(newFakeAirtable(map[string]map[string]interface{}{"recSso": {"Feature": "SSO"}}))()
+			slack := newFakeSlack()
+			defer slack.Close()
+
+			if err := respond(t, queueMessage{Query: "sso", ResponseUrl: slack.URL, RequestID: "req1", Shared: tt.shared}); err != nil {
+				t.Fatalf("Response() error = %v", err)
+			}
+			posted := slack.posted()
+			if len(posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(posted))
+			}
+			res := posted[0]
+			if res.ResponseType != tt.want || res.ReplaceOriginal != tt.wantReplace {
+				t.Errorf("posted %s replacing %s, want %s replacing %s", res.ResponseType, res.ReplaceOriginal, tt.want, tt.wantReplace)
+			}
+			shareValue := fmt.Sprintf(`"action_id":%q,"text":{"type":"plain_text","text":"Share to channel"},"value":"sso"`, shareActionID)
+			if got := strings.Contains(slack.text(), shareValue); got != tt.wantButton {
+				t.Errorf("share button for the query = %t, want %t", got, tt.wantButton)
+			}
+		})
+	}
+}